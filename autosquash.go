@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// autosquashTarget is the commit a fixup! or squash! message points at, for
+// git rebase --autosquash.
+type autosquashTarget struct {
+	Kind    string // "fixup" or "squash"
+	SHA     string
+	Subject string
+}
+
+// resolveAutosquash checks that rev names a commit and looks up its subject.
+func resolveAutosquash(kind, rev string) (autosquashTarget, error) {
+	sha, _, err := resolveRev(rev)
+	if err != nil {
+		return autosquashTarget{}, err
+	}
+	subject, err := runGit("log", "-1", "--format=%s", sha)
+	if err != nil {
+		return autosquashTarget{}, fmt.Errorf("git log failed: %w", err)
+	}
+	return autosquashTarget{Kind: kind, SHA: sha, Subject: subject}, nil
+}
+
+// marker is the subject line autosquash matches against the target.
+func (t autosquashTarget) marker() string {
+	return t.Kind + "! " + t.Subject
+}
+
+// message puts the marker line in front of body, if there is one.
+func (t autosquashTarget) message(body string) string {
+	if body == "" {
+		return t.marker()
+	}
+	return t.marker() + "\n\n" + body
+}
+
+// commit runs git commit --fixup or --squash, which write the marker line
+// themselves, so only the rest of msg is passed on. git rejects -F together
+// with --fixup, so a fixup body goes through -m.
+func (t autosquashTarget) commit(msg string, args ...string) error {
+	body := strings.TrimSpace(strings.TrimPrefix(msg, t.marker()))
+	target := "--" + t.Kind + "=" + t.SHA
+	if t.Kind == "squash" {
+		return commitWithMessage(body, append([]string{target}, args...)...)
+	}
+	cmd := []string{"commit", target}
+	if body != "" {
+		cmd = append(cmd, "-m", body)
+	}
+	return signingError(runCommit(slices.Concat(cmd, commitSignArgs, args)))
+}
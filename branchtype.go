@@ -0,0 +1,38 @@
+package main
+
+import (
+	"maps"
+	"strings"
+)
+
+// defaultBranchTypes maps common branch prefixes to the commit type they
+// imply. The branch_types config entry adds to or overrides it; mapping a
+// prefix to "" turns it off.
+var defaultBranchTypes = map[string]string{
+	"feat/":     "feat",
+	"feature/":  "feat",
+	"fix/":      "fix",
+	"bugfix/":   "fix",
+	"hotfix/":   "fix",
+	"chore/":    "chore",
+	"docs/":     "docs",
+	"refactor/": "refactor",
+	"perf/":     "perf",
+	"test/":     "test",
+	"ci/":       "ci",
+	"build/":    "build",
+}
+
+// typeFromBranch returns the type for the longest prefix of branch found in
+// the default map merged with overrides, or "" when none matches.
+func typeFromBranch(branch string, overrides map[string]string) string {
+	prefixes := maps.Clone(defaultBranchTypes)
+	maps.Copy(prefixes, overrides)
+	var best, kind string
+	for prefix, t := range prefixes {
+		if strings.HasPrefix(branch, prefix) && len(prefix) > len(best) {
+			best, kind = prefix, t
+		}
+	}
+	return kind
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+var (
+	// goDeclRe matches an exported top-level Go declaration on a removed or
+	// added diff line: the receiver type of a method, the name, and the rest
+	// of the line, which holds the signature.
+	goDeclRe = regexp.MustCompile(`^[-+](?:func (?:\((?:\w+ )?\*?([A-Z]\w*)(?:\[[^\]]*\])?\) )?|type |var |const )([A-Z]\w*)(.*)$`)
+	// goPackageRe matches the package clause on any diff line.
+	goPackageRe = regexp.MustCompile(`(?m)^[-+ ]?package (\w+)`)
+)
+
+// breakingChanges looks for Go API changes that break importers: exported
+// functions, methods, types, variables, and constants that were removed or
+// whose signature changed. A declaration that moves to another file of the
+// same package is not a removal. Tests, internal packages, and package main
+// have no importers and are skipped.
+func breakingChanges(diff string) []string {
+	type decl struct{ dir, name string }
+	removed, added := map[decl]string{}, map[decl]string{}
+	var order []decl
+	root, _ := runGit("rev-parse", "--show-toplevel")
+	for _, f := range gitctx.SplitFiles(diff) {
+		if !strings.HasSuffix(f.Path, ".go") || strings.HasSuffix(f.Path, "_test.go") || isInternalPath(f.Path) || goPackage(root, f) == "main" {
+			continue
+		}
+		for _, line := range strings.Split(f.Text, "\n") {
+			m := goDeclRe.FindStringSubmatch(line)
+			if m == nil || strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ ") {
+				continue
+			}
+			kind, _, _ := strings.Cut(line[1:], " ")
+			name := m[2]
+			if m[1] != "" {
+				kind, name = "method", m[1]+"."+name
+			}
+			d := decl{path.Dir(f.Path), kind + " " + name}
+			sig := strings.Join(strings.Fields(strings.TrimSuffix(strings.TrimSpace(m[3]), "{")), " ")
+			if line[0] == '-' {
+				if _, ok := removed[d]; !ok {
+					order = append(order, d)
+				}
+				removed[d] = sig
+			} else {
+				added[d] = sig
+			}
+		}
+	}
+	var found []string
+	for _, d := range order {
+		switch sig, still := added[d]; {
+		case !still:
+			found = append(found, "removed "+d.name)
+		case sig != removed[d]:
+			found = append(found, "changed the signature of "+d.name)
+		}
+	}
+	return found
+}
+
+func isInternalPath(p string) bool {
+	return slices.Contains(strings.Split(path.Dir(p), "/"), "internal")
+}
+
+// goPackage returns the package a changed Go file belongs to, from the diff
+// or else from the file in the working tree below root.
+func goPackage(root string, f gitctx.FileDiff) string {
+	if m := goPackageRe.FindStringSubmatch(f.Text); m != nil {
+		return m[1]
+	}
+	file, err := os.Open(filepath.Join(root, filepath.FromSlash(f.Path)))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		if name, ok := strings.CutPrefix(sc.Text(), "package "); ok {
+			return strings.TrimSpace(name)
+		}
+	}
+	return ""
+}
+
+// markBreaking makes sure a message for a change with found breaking
+// changes carries the "!" marker and, with footer, a BREAKING CHANGE footer,
+// listing found when the model wrote none. Messages without a type prefix
+// are left alone.
+func markBreaking(msg string, found []string, footer bool) string {
+	if len(found) == 0 {
+		return msg
+	}
+	subject, rest := generator.SplitMessage(fixBreakingFooters(msg))
+	loc := typePrefixRe.FindStringSubmatchIndex(subject)
+	if loc == nil {
+		return msg
+	}
+	if loc[6] < 0 {
+		colon := loc[1] - 1
+		subject = subject[:colon] + "!" + subject[colon:]
+	}
+	if footer && !breakingFooterRe.MatchString(rest) {
+		rest = strings.TrimRight(rest, "\n") + fmt.Sprintf("\n\nBREAKING CHANGE: %s", strings.Join(found, "; "))
+	}
+	return generator.JoinMessage(subject, rest)
+}
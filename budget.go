@@ -0,0 +1,171 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// lowSignalFiles are files whose diffs rarely help describe a change.
+var lowSignalFiles = []string{"go.sum", "package-lock.json", "yarn.lock", "pnpm-lock.yaml", "Cargo.lock", "poetry.lock", "composer.lock", "Gemfile.lock"}
+
+func isLowSignal(p string) bool {
+	base := path.Base(p)
+	return slices.Contains(lowSignalFiles, base) || strings.Contains(base, ".min.") ||
+		strings.HasPrefix(p, "vendor/") || strings.Contains(p, "/vendor/") || strings.HasSuffix(base, ".pb.go")
+}
+
+// budgetHunk is one "@@" hunk of a file diff.
+type budgetHunk struct {
+	Text   string
+	File   int  // index of the file it belongs to
+	Low    bool // belongs to a low-signal file
+	Keep   bool
+	Header bool // the file header before the first hunk
+}
+
+// splitHunks splits one file's diff into its header and its "@@" hunks.
+func splitHunks(text string) (header string, hunks []string) {
+	for _, line := range strings.SplitAfter(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			hunks = append(hunks, line)
+		case len(hunks) == 0:
+			header += line
+		default:
+			hunks[len(hunks)-1] += line
+		}
+	}
+	return header, hunks
+}
+
+// limitFileTokens caps every file's part of the diff at about limit tokens,
+// so that one huge file can't crowd out the rest. A file over the limit keeps
+// its header, every hunk's "@@" line (the line ranges and enclosing
+// function), and as many hunk lines from the top as fit. It reports how many
+// files were cut.
+func limitFileTokens(diff string, limit int) (string, int) {
+	if limit <= 0 {
+		return diff, 0
+	}
+	var b strings.Builder
+	cut := 0
+	for _, f := range gitctx.SplitFiles(diff) {
+		if estimateTokens(f.Text) <= limit {
+			b.WriteString(f.Text)
+			continue
+		}
+		header, hunks := splitHunks(f.Text)
+		b.WriteString(header)
+		used, omitted, full := estimateTokens(header), 0, false
+		for _, h := range hunks {
+			for i, line := range strings.SplitAfter(h, "\n") {
+				if line == "" {
+					continue
+				}
+				if i > 0 && (full || used+estimateTokens(line) > limit) {
+					full = true
+					omitted++
+					continue
+				}
+				b.WriteString(line)
+				used += estimateTokens(line)
+			}
+		}
+		fmt.Fprintf(&b, "[%d lines of this file omitted]\n", omitted)
+		cut++
+	}
+	return b.String(), cut
+}
+
+// fitTokenBudget trims the least useful parts of the prompt until the
+// estimated token count of the system and user prompts fits budget: first the
+// oldest recent-commit lines, then diff hunks of low-signal files such as
+// lockfiles, then the largest remaining hunks. Once hunks have to go, the
+// prompt lists every changed file with its line counts (opts.DiffStat) next
+// to the hunks that are left, and headers of files left without hunks are
+// dropped, since the list still names them. It reports how many pieces were
+// dropped.
+func fitTokenBudget(opts generator.Options, gc gitctx.CommitContext, budget int) (generator.Options, gitctx.CommitContext, int) {
+	estimate := func(gc gitctx.CommitContext) int {
+		return estimateTokens(generator.SystemPrompt(opts) + generator.UserPrompt(opts, gc))
+	}
+	over := estimate(gc) - budget
+	if budget <= 0 || over <= 0 {
+		return opts, gc, 0
+	}
+	dropped := 0
+
+	logLines := strings.Split(strings.TrimSpace(gc.Log), "\n")
+	for over > 0 && len(logLines) > 0 && logLines[0] != "" {
+		last := logLines[len(logLines)-1] // git log lists newest first
+		logLines = logLines[:len(logLines)-1]
+		over -= estimateTokens(last + "\n")
+		dropped++
+	}
+	gc.Log = strings.Join(logLines, "\n")
+
+	var hunks []budgetHunk
+	var remaining []int // hunks kept per file
+	for i, f := range gitctx.SplitFiles(gc.Diff) {
+		low := isLowSignal(f.Path)
+		header, fileHunks := splitHunks(f.Text)
+		hunks = append(hunks, budgetHunk{Text: header, File: i, Low: low, Keep: true, Header: true})
+		for _, h := range fileHunks {
+			hunks = append(hunks, budgetHunk{Text: h, File: i, Low: low, Keep: true})
+		}
+		remaining = append(remaining, len(fileHunks))
+	}
+	order := make([]int, 0, len(hunks))
+	headers := map[int]int{} // file to its header's index in hunks
+	for i, h := range hunks {
+		if h.Header {
+			headers[h.File] = i
+		} else {
+			order = append(order, i)
+		}
+	}
+	if over <= 0 || len(order) == 0 {
+		return opts, gc, dropped
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		if hunks[a].Low != hunks[b].Low {
+			if hunks[a].Low {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(len(hunks[b].Text), len(hunks[a].Text))
+	})
+	opts.DiffStat = gitctx.StatSummary(gc.NameStatus, gc.Diff)
+	over = estimate(gc) - budget
+	omitted := 0
+	for _, i := range order {
+		if over <= 0 {
+			break
+		}
+		hunks[i].Keep = false
+		over -= estimateTokens(hunks[i].Text)
+		omitted++
+		f := hunks[i].File
+		remaining[f]--
+		if remaining[f] == 0 {
+			hunks[headers[f]].Keep = false
+			over -= estimateTokens(hunks[headers[f]].Text)
+		}
+	}
+	var b strings.Builder
+	for _, h := range hunks {
+		if h.Keep {
+			b.WriteString(h.Text)
+		}
+	}
+	fmt.Fprintf(&b, "[%d hunks omitted to fit the token budget]\n", omitted)
+	gc.Diff = b.String()
+	return opts, gc, dropped + omitted
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// messageCacheTTL is how long a generated message is reused for an identical
+// prompt.
+const messageCacheTTL = 7 * 24 * time.Hour
+
+// messageCacheKey identifies a generation by everything that affects the
+// output: the model and the exact system and user prompts. Changing the
+// prompt template, style options, or model therefore never hits a stale entry.
+func messageCacheKey(model, system, prompt string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + system + "\x00" + prompt))
+	return hex.EncodeToString(sum[:16])
+}
+
+// generationCacheKey is messageCacheKey for a generation with opts on gc.
+// Templates and model options shape the output without appearing verbatim
+// in the system prompt, so they are part of the key too, and so are the
+// checks the message had to pass, such as "verify": a message cached
+// without them isn't reused for a run that asks for them.
+func generationCacheKey(opts generator.Options, gc gitctx.CommitContext, checks ...string) string {
+	return messageCacheKey(opts.Model, generator.SystemPrompt(opts)+fmt.Sprint(opts.Templates, opts.ModelOptions, checks), generator.UserPrompt(opts, gc))
+}
+
+// noCache turns the message cache off for the run (--no-cache): nothing is
+// read from it or written to it.
+var noCache bool
+
+func messageCacheDir() string {
+	dir, _ := os.UserCacheDir()
+	return filepath.Join(dir, "commit", "messages")
+}
+
+func messageCachePath(key string) string {
+	return filepath.Join(messageCacheDir(), key+".json")
+}
+
+// loadCachedMessage returns the message stored under key if it is fresh.
+func loadCachedMessage(key string) (generator.Suggestion, bool) {
+	if noCache {
+		return generator.Suggestion{}, false
+	}
+	path := messageCachePath(key)
+	fi, err := os.Stat(path)
+	if err != nil || time.Since(fi.ModTime()) >= messageCacheTTL {
+		return generator.Suggestion{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return generator.Suggestion{}, false
+	}
+	var sg generator.Suggestion
+	if json.Unmarshal(data, &sg) != nil || sg.Message == "" {
+		return generator.Suggestion{}, false
+	}
+	return sg, true
+}
+
+// storeCachedMessage saves sg under key, replacing any existing entry.
+func storeCachedMessage(key string, sg generator.Suggestion) error {
+	if noCache {
+		return nil
+	}
+	path := messageCachePath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(sg)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// runCache implements `commit cache [clear]`: it reports how many messages
+// and summaries are cached, or removes them all.
+func runCache(args []string) {
+	dir := messageCacheDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		fatalf("%v", err)
+	}
+	switch {
+	case len(args) == 0:
+		fmt.Printf("%d cached messages in %s\n", len(entries), dir)
+	case len(args) == 1 && args[0] == "clear":
+		if err := os.RemoveAll(dir); err != nil {
+			fatalf("%v", err)
+		}
+		fmt.Println(success(fmt.Sprintf("Removed %d cached messages.", len(entries))))
+	default:
+		fatalf("usage: commit cache [clear]")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// runCtx is the context git commands and model requests run under. main
+// replaces it with interruptContext, so Ctrl-C stops whatever is in flight.
+var runCtx = context.Background()
+
+// queryTimeout bounds each git query (--timeout). Commands that may wait on
+// the user, such as git commit running an editor or hooks, aren't bounded.
+var queryTimeout time.Duration
+
+// interruptGrace is how long an interrupted run gets to wind down through its
+// normal error path before it is stopped outright.
+const interruptGrace = 2 * time.Second
+
+// interruptContext returns a context that Ctrl-C cancels. Cancelling stops
+// running git commands and model requests; a run that is still going
+// interruptGrace later, e.g. one waiting at a prompt, exits with the usual
+// 130. A second Ctrl-C exits at once.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		signal.Stop(sig)
+		debugf("Interrupted; cancelling")
+		cancel()
+		time.Sleep(interruptGrace)
+		os.Exit(130)
+	}()
+	return ctx
+}
+
+// withTimeout bounds ctx by d; zero or less means no limit beyond ctx's own.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// changelogSection is a group of a changelog, with the commit types that go
+// in it, in the order the sections are written.
+type changelogSection struct {
+	Title string
+	Types []string
+}
+
+var changelogSections = []changelogSection{
+	{"Breaking changes", nil},
+	{"Features", []string{"feat"}},
+	{"Bug fixes", []string{"fix"}},
+	{"Performance", []string{"perf"}},
+	{"Reverts", []string{"revert"}},
+	{"Documentation", []string{"docs"}},
+	{"Refactoring", []string{"refactor"}},
+	{"Tests", []string{"test"}},
+	{"Build and CI", []string{"build", "ci"}},
+	{"Chores", []string{"chore", "style"}},
+	{"Other changes", nil},
+}
+
+const changelogSystemPrompt = `You write the summaries of a changelog's sections from the commits listed in each.
+For every section given, reply with its "### " heading exactly as given, then one short paragraph on what the changes mean for users of the project, in plain prose.
+Don't list the commits again, and don't mention anything they don't show.
+Reply with nothing else: no code fences, no preamble.`
+
+// changelogEntry is one commit of a changelog.
+type changelogEntry struct {
+	SHA, Scope, Description string
+	Breaking                bool
+	Section                 string
+}
+
+// runChangelog implements `commit changelog`: it groups the commits from
+// --from to --to by Conventional Commits type into a CHANGELOG.md section.
+func runChangelog(args []string, provider, model string) {
+	fs := flag.NewFlagSet("changelog", flag.ExitOnError)
+	from := fs.String("from", "", "Start after this revision (default: the latest tag before --to, else the first commit)")
+	to := fs.String("to", "HEAD", "End at this revision")
+	version := fs.String("version", "", "Heading of the section (default: the tag at --to, else Unreleased)")
+	summarize := fs.Bool("summarize", false, "Have the model write a summary paragraph for each section")
+	output := fs.String("output", "", "Insert the section at the top of this file, such as CHANGELOG.md, instead of printing it")
+	fs.Parse(args)
+
+	if *from == "" {
+		*from, _ = runGit("describe", "--tags", "--abbrev=0", *to+"^")
+	}
+	if *version == "" {
+		if *version, _ = runGit("describe", "--tags", "--exact-match", *to); *version == "" {
+			*version = "Unreleased"
+		}
+	}
+	span := *to
+	if *from != "" {
+		span = *from + ".." + *to
+	}
+	log, err := runGit("log", "--no-merges", "--format=%h%x1f%B%x1e", span)
+	if err != nil {
+		fatalf("git log %s failed: %v", span, err)
+	}
+	var entries []changelogEntry
+	for _, record := range strings.Split(log, "\x1e") {
+		if sha, msg, ok := strings.Cut(strings.TrimSpace(record), "\x1f"); ok {
+			entries = append(entries, parseChangelogEntry(sha, strings.TrimSpace(msg)))
+		}
+	}
+	if len(entries) == 0 {
+		fatalf("No commits in %s.", span)
+	}
+
+	date := time.Now()
+	if t, err := runGit("log", "-1", "--format=%cI", *to); err == nil {
+		if parsed, err := time.Parse(time.RFC3339, t); err == nil {
+			date = parsed
+		}
+	}
+	var summaries map[string]string
+	if *summarize {
+		summaries = summarizeChangelog(provider, model, entries)
+	}
+	section := formatChangelog(*version, date, entries, summaries)
+
+	if *output == "" {
+		fmt.Print(section)
+		return
+	}
+	if err := insertChangelog(*output, section); err != nil {
+		fatalf("Writing %s: %v", *output, err)
+	}
+	infof("Added %s to %s.", *version, *output)
+}
+
+// parseChangelogEntry reads a commit's type, scope, and description from its
+// subject, and whether it's breaking from a "!" or a BREAKING CHANGE footer.
+func parseChangelogEntry(sha, msg string) changelogEntry {
+	subject, body := generator.SplitMessage(msg)
+	e := changelogEntry{SHA: sha, Description: subject, Section: "Other changes"}
+	m := typePrefixRe.FindStringSubmatch(subject)
+	if m == nil {
+		return e
+	}
+	kind := strings.ToLower(m[1])
+	for _, s := range changelogSections {
+		if slices.Contains(s.Types, kind) {
+			e.Section = s.Title
+		}
+	}
+	e.Scope = strings.Trim(m[2], "()")
+	e.Description = strings.TrimSpace(subject[len(m[0]):])
+	if e.Breaking = m[3] == "!" || breakingFooterRe.MatchString(body); e.Breaking {
+		e.Section = "Breaking changes"
+	}
+	return e
+}
+
+// formatChangelog writes the markdown section: a heading with the version
+// and date, then a heading and a list per section with commits in it.
+func formatChangelog(version string, date time.Time, entries []changelogEntry, summaries map[string]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s (%s)\n", version, date.Format(time.DateOnly))
+	for _, s := range changelogSections {
+		var items []string
+		for _, e := range entries {
+			if e.Section != s.Title {
+				continue
+			}
+			item := "- "
+			if e.Scope != "" {
+				item += "**" + e.Scope + ":** "
+			}
+			items = append(items, item+e.Description+" ("+e.SHA+")")
+		}
+		if len(items) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", s.Title)
+		if summary := summaries[s.Title]; summary != "" {
+			b.WriteString(summary + "\n\n")
+		}
+		b.WriteString(strings.Join(items, "\n") + "\n")
+	}
+	return b.String()
+}
+
+// summarizeChangelog asks the model for one paragraph per section that has
+// entries, in a single request, and returns them by section title. A
+// failed request only costs the summaries.
+func summarizeChangelog(provider, model string, entries []changelogEntry) map[string]string {
+	model, _, err := generator.ResolveModel(provider, model, model != "")
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := checkModel(runCtx, model); err != nil {
+		errorf("%v", err)
+		os.Exit(noModelExit())
+	}
+	var prompt strings.Builder
+	for _, s := range changelogSections {
+		var subjects []string
+		for _, e := range entries {
+			if e.Section == s.Title {
+				subjects = append(subjects, "- "+e.Description)
+			}
+		}
+		if len(subjects) > 0 {
+			fmt.Fprintf(&prompt, "### %s\n%s\n\n", s.Title, strings.Join(subjects, "\n"))
+		}
+	}
+	infof("Summarizing %d commits...", len(entries))
+	opts := generator.Options{Model: model, Timeout: queryTimeout}
+	answer, err := newGenerator(runCtx, model).Ask(runCtx, opts, changelogSystemPrompt, strings.TrimSpace(prompt.String()))
+	if err != nil {
+		warnf("Summarizing failed, leaving the summaries out: %v", err)
+		return nil
+	}
+	summaries := map[string]string{}
+	title := ""
+	for _, line := range strings.Split(answer, "\n") {
+		if t, ok := strings.CutPrefix(line, "### "); ok {
+			title = strings.TrimSpace(t)
+			continue
+		}
+		if line = strings.TrimSpace(line); line != "" && title != "" {
+			summaries[title] = strings.TrimSpace(summaries[title] + " " + line)
+		}
+	}
+	return summaries
+}
+
+// insertChangelog puts section at the top of the changelog in name, below
+// its "# " title, creating the file with a "# Changelog" title if needed.
+func insertChangelog(name, section string) error {
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		data, err = []byte("# Changelog\n"), nil
+	}
+	if err != nil {
+		return err
+	}
+	text := string(data)
+	head, rest := "", text
+	if strings.HasPrefix(text, "# ") {
+		title, after, _ := strings.Cut(text, "\n")
+		head, rest = title+"\n\n", strings.TrimLeft(after, "\n")
+	}
+	if rest != "" {
+		section += "\n"
+	}
+	return os.WriteFile(name, []byte(head+section+rest), 0644)
+}
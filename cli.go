@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// command is a subcommand as --help, the shell completions, and the man page
+// list it; main dispatches them. cobra adds completion and help.
+type command struct {
+	Name    string
+	Args    string // synopsis after the name
+	Summary string
+}
+
+var commands = []command{
+	{"init", "", "Choose a provider, API key, model, and style, then try them"},
+	{"generate", "[flags]", "Generate a message for the current changes (the default)"},
+	{"watch", "[--watch-file FILE] [flags]", "Keep a message for the current changes up to date as files change"},
+	{"pr", "[--base BRANCH] [--create [--draft]]", "Write a pull request title and description for the branch"},
+	{"rewrite", "[--base BRANCH] [--apply]", "Write new messages for every commit on the branch and reword them with git rebase -i"},
+	{"changelog", "[--from REV] [--to REV] [--summarize] [--output FILE]", "Write a CHANGELOG.md section from the commits in a range, grouped by type"},
+	{"serve", "[--addr HOST:PORT]", "Serve messages over a local JSON API for editor plugins (POST /generate)"},
+	{"exemplar", "add|remove|list [COMMIT...]", "Keep the commits whose messages are shown to the model as examples of the repository's voice"},
+	{"lint", "[--fix] [--message MSG | FILE | -]", "Check a commit message against the generation rules"},
+	{"install-hook", "[--force]", "Generate messages in git commit through a prepare-commit-msg hook"},
+	{"uninstall-hook", "", "Remove the hook and restore the one it replaced"},
+	{"models", "[provider|all]", "List available models"},
+	{"stats", "", "Latency, accept and edit rates, and spend per model and repository from the history log"},
+	{"cache", "[clear]", "Count the cached messages, or delete them all"},
+	{"doctor", "[--live]", "Check git, repository, and API key setup"},
+	{"config", "[path]", "Print the config file"},
+	{"man", "", "Print the man page"},
+}
+
+// rootCommand is the parsed command line, for flagSet.
+var rootCommand *cobra.Command
+
+// parseCommandLine parses args with the flags defined on flag.CommandLine
+// and returns the command picked, "" for the default run, and the arguments
+// after it. Flags before a command are commit's; the rest go to the command,
+// which parses its own, except generate and watch, which take commit's. ok
+// is false once help or a completion script has been printed instead.
+func parseCommandLine(args []string) (command string, rest []string, ok bool) {
+	root := &cobra.Command{
+		Use:              "commit [flags] [command]",
+		Short:            "Generate git commit messages with AI",
+		Long:             "Without a command, commit describes the staged changes, or all changes when nothing is staged, and then commits, copies, or prints the message as configured.",
+		Args:             cobra.NoArgs,
+		TraverseChildren: true,
+		Run:              func(_ *cobra.Command, a []string) { rest, ok = a, true },
+	}
+	root.PersistentFlags().AddGoFlagSet(flag.CommandLine)
+	root.SetArgs(args)
+	for _, c := range commands {
+		root.AddCommand(&cobra.Command{
+			Use:                strings.TrimSpace(c.Name + " " + c.Args),
+			Short:              c.Summary,
+			DisableFlagParsing: c.Name != "generate" && c.Name != "watch",
+			Run:                func(_ *cobra.Command, a []string) { command, rest, ok = c.Name, a, true },
+		})
+	}
+	rootCommand = root
+	if err := root.Execute(); err != nil {
+		os.Exit(2)
+	}
+	return command, rest, ok
+}
+
+// cliFlag is a top-level flag as the man page needs it.
+type cliFlag struct {
+	Name    string
+	Value   string // placeholder for the flag's value; "" for a boolean
+	Summary string // first line of the usage
+	Usage   string
+}
+
+func cliFlags() []cliFlag {
+	var flags []cliFlag
+	flag.VisitAll(func(f *flag.Flag) {
+		value, usage := flag.UnquoteUsage(f)
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			value = ""
+		}
+		summary, _, _ := strings.Cut(usage, "\n")
+		flags = append(flags, cliFlag{Name: f.Name, Value: value, Summary: summary, Usage: usage})
+	})
+	return flags
+}
+
+// dashes is a flag's spelling on the command line: -a, --all.
+func (f cliFlag) dashes() string {
+	if len(f.Name) == 1 {
+		return "-" + f.Name
+	}
+	return "--" + f.Name
+}
+
+// runMan implements `commit man`: a man page in roff, e.g. for
+// `commit man > /usr/local/share/man/man1/commit.1`.
+func runMan() {
+	roff := strings.NewReplacer(`\`, `\e`, "-", `\-`, "\n.", "\n\\&.", "\n'", "\n\\&'")
+	w := os.Stdout
+	fmt.Fprintln(w, `.TH COMMIT 1 "" "commit" "User Commands"`)
+	fmt.Fprintln(w, ".SH NAME\ncommit \\- generate git commit messages with AI")
+	fmt.Fprintln(w, ".SH SYNOPSIS\n.B commit\n[\\fIflags\\fR] [\\fIcommand\\fR]")
+	fmt.Fprintln(w, ".SH DESCRIPTION\nWithout a command, commit describes the staged changes, or all changes when nothing is staged, and then commits, copies, or prints the message as configured.")
+	fmt.Fprintln(w, ".SH COMMANDS")
+	for _, c := range rootCommand.Commands() {
+		if c.IsAvailableCommand() {
+			fmt.Fprintf(w, ".TP\n.B %s\n%s\n", roff.Replace(c.Use), roff.Replace(c.Short))
+		}
+	}
+	fmt.Fprintln(w, ".SH OPTIONS")
+	for _, f := range cliFlags() {
+		fmt.Fprintf(w, ".TP\n.B %s", roff.Replace(f.dashes()))
+		if f.Value != "" {
+			fmt.Fprintf(w, " \\fI%s\\fR", roff.Replace(f.Value))
+		}
+		fmt.Fprintf(w, "\n%s\n", roff.Replace(f.Usage))
+	}
+	fmt.Fprintln(w, ".SH FILES\n.TP\n.I ~/.config/commit/config.toml\nPreferences and defaults for the flags, under $XDG_CONFIG_HOME when that is set; commit config path prints where.")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// closeKeywords are the words GitHub and GitLab accept for closing an issue
+// from a commit message.
+var closeKeywords = []string{"close", "closes", "closed", "fix", "fixes", "fixed", "resolve", "resolves", "resolved"}
+
+var (
+	// noteIssueRe matches issue references such as "#123" or "GH-123" in --note text.
+	noteIssueRe = regexp.MustCompile(`(?i)(?:^|[^\w&])(?:#|gh-)(\d+)\b`)
+	// closeLineRe matches a closing line such as "Fixes #123" or "Closes: #123".
+	closeLineRe = regexp.MustCompile(`(?i)^(?:` + strings.Join(closeKeywords, "|") + `):? +#(\d+)$`)
+)
+
+// parseCloseKeyword validates a --close-keyword value, keeping its case.
+func parseCloseKeyword(s string) (string, error) {
+	if !slices.Contains(closeKeywords, strings.ToLower(s)) {
+		return "", fmt.Errorf("invalid close keyword %q (want one of %v)", s, closeKeywords)
+	}
+	return s, nil
+}
+
+// issueRefs collects the issue numbers named by the branch (as for the github
+// tracker) and by the notes, in order and without repeats.
+func issueRefs(branch string, notes []string) []string {
+	var refs []string
+	if n := ticketFromBranch("github", branch); n != "" {
+		refs = append(refs, n)
+	}
+	for _, note := range notes {
+		for _, m := range noteIssueRe.FindAllStringSubmatch(note, -1) {
+			if !slices.Contains(refs, m[1]) {
+				refs = append(refs, m[1])
+			}
+		}
+	}
+	return refs
+}
+
+// withCloseTrailers adds a "<keyword> #N" line for every ref that msg doesn't
+// already close.
+func withCloseTrailers(msg, keyword string, refs []string) string {
+	closed := map[string]bool{}
+	for _, line := range strings.Split(msg, "\n") {
+		if m := closeLineRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			closed[m[1]] = true
+		}
+	}
+	for _, n := range refs {
+		if !closed[n] {
+			msg = appendTrailerLine(msg, keyword+" #"+n)
+			closed[n] = true
+		}
+	}
+	return msg
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiDim    = "\033[2m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiCyan   = "\033[36m"
+)
+
+// colorEnabled is set once at startup. Colors are only ever applied to text
+// printed to the terminal, never to the message that gets committed or copied.
+var colorEnabled bool
+
+var typePrefixRe = regexp.MustCompile(`^([a-zA-Z]+)(\([^)]*\))?(!)?:`)
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+func setupColor(noColor bool) {
+	colorEnabled = !noColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout) && enableVirtualTerminal(os.Stdout)
+}
+
+func paint(code, s string) string {
+	if !colorEnabled || s == "" {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+func header(s string) string  { return paint(ansiBold, s) }
+func success(s string) string { return paint(ansiGreen, s) }
+func warn(s string) string    { return paint(ansiYellow, s) }
+func failure(s string) string { return paint(ansiRed, s) }
+
+// colorMessage highlights the type label of the subject and dims the body.
+func colorMessage(msg string) string {
+	if !colorEnabled {
+		return msg
+	}
+	subject, body, hasBody := strings.Cut(msg, "\n")
+	if loc := typePrefixRe.FindStringIndex(subject); loc != nil {
+		subject = paint(ansiCyan+ansiBold, subject[:loc[1]]) + paint(ansiBold, subject[loc[1]:])
+	} else {
+		subject = paint(ansiBold, subject)
+	}
+	if !hasBody {
+		return subject
+	}
+	return subject + "\n" + paint(ansiDim, body)
+}
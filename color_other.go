@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// enableVirtualTerminal is a no-op outside Windows; terminals handle ANSI
+// escapes natively.
+func enableVirtualTerminal(f *os.File) bool { return true }
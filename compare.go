@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// modelPrices are list prices in USD per million input and output tokens,
+// used for the rough cost column of --compare-models. Local models are free;
+// models missing here show no cost.
+var modelPrices = map[string][2]float64{
+	"googleai/gemini-2.5-pro":              {1.25, 10},
+	"googleai/gemini-2.5-flash":            {0.30, 2.50},
+	"googleai/gemini-2.5-flash-lite":       {0.10, 0.40},
+	"openai/gpt-4.1":                       {2, 8},
+	"openai/gpt-4.1-mini":                  {0.40, 1.60},
+	"openai/gpt-4.1-nano":                  {0.10, 0.40},
+	"openai/gpt-4o":                        {2.50, 10},
+	"openai/gpt-4o-mini":                   {0.15, 0.60},
+	"anthropic/claude-opus-4-1-20250805":   {15, 75},
+	"anthropic/claude-sonnet-4-5-20250929": {3, 15},
+	"anthropic/claude-haiku-4-5-20251001":  {1, 5},
+}
+
+// estimateCost returns the rough USD cost of a call, and false when the
+// model's price is unknown.
+func estimateCost(model string, inTokens, outTokens int) (float64, bool) {
+	if generator.ProviderOf(model) == "ollama" {
+		return 0, true
+	}
+	p, ok := modelPrices[model]
+	if !ok {
+		return 0, false
+	}
+	return (float64(inTokens)*p[0] + float64(outTokens)*p[1]) / 1e6, true
+}
+
+// comparison is one model's result in --compare-models.
+type comparison struct {
+	Model     string   `json:"model"`
+	Message   string   `json:"message,omitempty"`
+	ElapsedMs int64    `json:"elapsed_ms"`
+	CostUSD   *float64 `json:"cost_usd,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Timings   timings  `json:"timings"`
+}
+
+// timings breaks a run down by phase. Gathering the git data happens once
+// and is shared by every model; generation includes retries and the type
+// classification call, if any.
+type timings struct {
+	GatherMs   int64 `json:"git_gather_ms"`
+	GenerateMs int64 `json:"generate_ms"`
+}
+
+// runCompare generates a message for the same prompt with every model, at
+// most concurrency at a time, and prints the results as a table or JSON. A
+// failing model is reported in its row and doesn't stop the others.
+func runCompare(ctx context.Context, models []string, opts generator.Options, post postProcess, gc gitctx.CommitContext, gathered time.Duration, concurrency int, asJSON bool) {
+	inTokens := estimateTokens(generator.SystemPrompt(opts) + generator.UserPrompt(opts, gc))
+	results := make([]comparison, len(models))
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, m := range models {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			r := comparison{Model: m}
+			start := time.Now()
+			o := opts
+			o.Model = m
+			// A model without its API key fails in its row; making a
+			// generator for it would panic.
+			var sg generator.Suggestion
+			err := checkModel(ctx, m)
+			if err == nil {
+				sg, err = newGenerator(ctx, m).Generate(ctx, o, gc)
+			}
+			r.ElapsedMs = time.Since(start).Milliseconds()
+			r.Timings = timings{GatherMs: gathered.Milliseconds(), GenerateMs: r.ElapsedMs}
+			if err != nil {
+				r.Error = err.Error()
+			} else {
+				r.Message = post.apply(sg.Message)
+				if cost, ok := estimateCost(m, inTokens, estimateTokens(sg.Message)); ok {
+					r.CostUSD = &cost
+				}
+			}
+			results[i] = r
+		}()
+	}
+	wg.Wait()
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+		return
+	}
+	fmt.Println(header(fmt.Sprintf("%-45s %10s %10s", "MODEL", "LATENCY", "COST")))
+	for _, r := range results {
+		cost := "-"
+		if r.CostUSD != nil {
+			cost = fmt.Sprintf("$%.5f", *r.CostUSD)
+		}
+		fmt.Printf("%-45s %10s %10s\n", r.Model, time.Duration(r.ElapsedMs)*time.Millisecond, cost)
+		if r.Error != "" {
+			fmt.Println("  " + failure("error: "+r.Error))
+		} else {
+			fmt.Println("  " + strings.ReplaceAll(colorMessage(r.Message), "\n", "\n  "))
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	// Without a config.toml the JSON file of earlier versions is read.
+	legacy := `{"style": "simple", "action": "clipboard", "token_budget": 8000, "temperature": 0.2, "scopes": {"api/**": "api"}, "clipboard": false}`
+	if err := os.MkdirAll(filepath.Dir(legacyConfigPath()), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacyConfigPath(), []byte(legacy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c := loadConfig()
+	if c.Style != "simple" || c.TokenBudget != 8000 || c.Scopes["api/**"] != "api" || c.Clipboard == nil || *c.Clipboard {
+		t.Fatalf("config from config.json = %+v", c)
+	}
+
+	// Saving moves it to config.toml, which wins from then on.
+	saveConfig(c)
+	data, err := os.ReadFile(configPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`style = "simple"`, "token_budget = 8000\n", "temperature = 0.2\n", "clipboard = false\n", "[scopes]\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("config.toml lacks %q:\n%s", want, data)
+		}
+	}
+	if got := loadConfig(); !reflect.DeepEqual(got, c) {
+		t.Errorf("config from config.toml = %+v, want %+v", got, c)
+	}
+
+	toml := "model = \"openai/gpt-4o-mini\"\nmax_subject_length = 72\n\n[api_key_env]\nopenai = \"WORK_OPENAI_KEY\"\n"
+	if err := os.WriteFile(configPath(), []byte(toml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c = loadConfig()
+	if c.Model != "openai/gpt-4o-mini" || c.MaxSubjectLength != 72 || c.APIKeyEnv["openai"] != "WORK_OPENAI_KEY" || c.Style != "" {
+		t.Errorf("config from config.toml = %+v", c)
+	}
+}
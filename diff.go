@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+var diffAlgorithms = []string{"histogram", "patience", "minimal", "myers"}
+
+func parseDiffAlgorithm(s string) (string, error) {
+	if !slices.Contains(diffAlgorithms, s) {
+		return "", fmt.Errorf("invalid diff algorithm %q (want one of %v)", s, diffAlgorithms)
+	}
+	return s, nil
+}
+
+// hasConflicts reports whether the working tree has unresolved merge
+// conflicts, either as unmerged paths (git diff --name-only
+// --diff-filter=U, rather than git status, whose headings are translated)
+// or as conflict markers being added.
+func hasConflicts(unmerged, diff string) bool {
+	if strings.TrimSpace(unmerged) != "" {
+		return true
+	}
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "+<<<<<<< ") || strings.HasPrefix(line, "+>>>>>>> ") {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff sources accepted by --range besides revision ranges.
+const (
+	RangeWorktree = "worktree" // staged and unstaged changes against HEAD
+	RangeStaged   = "staged"   // the index only
+)
+
+// diffRange is a resolved diff source.
+type diffRange struct {
+	Spec    string
+	Args    []string // git command producing the diff
+	History bool     // the changes are already committed, so there is nothing to commit
+}
+
+// resolveRange maps a --range value to the git command for its diff; main
+// picks staged or worktree when --range isn't given. Anything
+// other than worktree and staged is passed to git diff as a revision or
+// range (HEAD~3..HEAD, main..., v1.2.0).
+func resolveRange(spec, algorithm string) (diffRange, error) {
+	algo := "--diff-algorithm=" + algorithm
+	switch spec {
+	case "", RangeWorktree:
+		return diffRange{Spec: RangeWorktree, Args: []string{"diff", algo, "HEAD"}}, nil
+	case RangeStaged:
+		return diffRange{Spec: RangeStaged, Args: []string{"diff", "--staged", algo}}, nil
+	}
+	if strings.HasPrefix(spec, "-") {
+		return diffRange{}, fmt.Errorf("invalid range %q", spec)
+	}
+	return diffRange{Spec: spec, Args: []string{"diff", algo, spec}, History: true}, nil
+}
+
+// hasHunks reports whether a diff contains any content hunks, as opposed to
+// only file headers (which is what -w leaves for whitespace-only files).
+func hasHunks(diff string) bool {
+	return strings.HasPrefix(diff, "@@") || strings.Contains(diff, "\n@@")
+}
+
+// limitDiffFiles keeps the full diff of the max files with the most changed
+// lines (ties broken by path) and replaces the rest with a name-only list.
+// Kept files stay in their original order.
+func limitDiffFiles(diff string, max int) string {
+	files := gitctx.SplitFiles(diff)
+	if max <= 0 || len(files) <= max {
+		return diff
+	}
+	ranked := slices.Clone(files)
+	slices.SortStableFunc(ranked, func(a, b gitctx.FileDiff) int {
+		if a.Changed != b.Changed {
+			return b.Changed - a.Changed
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+	keep := map[string]bool{}
+	for _, f := range ranked[:max] {
+		keep[f.Path] = true
+	}
+
+	var kept, omitted strings.Builder
+	for _, f := range files {
+		if keep[f.Path] {
+			kept.WriteString(f.Text)
+		} else {
+			fmt.Fprintf(&omitted, "\n  %s (%d lines changed)", f.Path, f.Changed)
+		}
+	}
+	return strings.TrimRight(kept.String(), "\n") + "\n\n" + generator.OmittedFilesTitle + omitted.String()
+}
+
+// changedLines counts the added and deleted lines of a diff.
+func changedLines(diff string) int {
+	n := 0
+	for _, f := range gitctx.SplitFiles(diff) {
+		n += f.Changed
+	}
+	return n
+}
+
+// statFooter lists every changed file with its line counts, aligned, followed
+// by the totals. It is computed from the diff, never by the model, for
+// --append-stats.
+func statFooter(nameStatus, diff string) string {
+	byPath := map[string]gitctx.FileDiff{}
+	for _, f := range gitctx.SplitFiles(diff) {
+		byPath[f.Path] = f
+	}
+	changes := gitctx.ParseNameStatus(nameStatus)
+	width := 0
+	for _, c := range changes {
+		width = max(width, utf8.RuneCountInString(c.Path))
+	}
+	var b strings.Builder
+	var added, deleted int
+	for _, c := range changes {
+		f := byPath[c.Path]
+		added += f.Added
+		deleted += f.Deleted
+		fmt.Fprintf(&b, "%s%s | +%d -%d\n", c.Path, strings.Repeat(" ", width-utf8.RuneCountInString(c.Path)), f.Added, f.Deleted)
+	}
+	b.WriteString(gitctx.Shortstat(len(changes), added, deleted))
+	return b.String()
+}
+
+// isContentLine reports whether a diff line is part of a hunk's content
+// rather than a file or hunk header.
+func isContentLine(line string) bool {
+	if strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "--- ") {
+		return false
+	}
+	return line[0] == '+' || line[0] == '-' || line[0] == ' '
+}
+
+// omitLongLines replaces diff lines longer than max bytes, typically minified
+// code or embedded base64, with a placeholder that keeps the line's +/-/space
+// prefix. Headers are never touched. A max of 0 or less disables it.
+func omitLongLines(diff string, max int) string {
+	if max <= 0 {
+		return diff
+	}
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		if len(line) <= max || !isContentLine(line) {
+			continue
+		}
+		lines[i] = fmt.Sprintf("%s[long line omitted, %d chars]", line[:1], utf8.RuneCountInString(line)-1)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// fileDiff is the diff of a new file with n lines.
+func fileDiff(path string, n int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "diff --git a/%s b/%s\nnew file mode 100644\n--- /dev/null\n+++ b/%s\n@@ -0,0 +1,%d @@\n", path, path, path, n)
+	for i := range n {
+		fmt.Fprintf(&b, "+line %d\n", i)
+	}
+	return b.String()
+}
+
+func TestLimitDiffFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string // path:lines
+		max     int
+		kept    []string
+		omitted []string
+	}{
+		{"largest kept", []string{"a.go:2", "b.go:9", "c.go:5"}, 2, []string{"b.go", "c.go"}, []string{"a.go (2 lines changed)"}},
+		{"ties broken by path", []string{"z.go:3", "m.go:3", "a.go:3", "q.go:3"}, 2, []string{"a.go", "m.go"}, []string{"z.go (3 lines changed)", "q.go (3 lines changed)"}},
+		{"tie after a larger file", []string{"c.go:1", "b.go:1", "a.go:7"}, 2, []string{"a.go", "b.go"}, []string{"c.go (1 lines changed)"}},
+		{"under the limit", []string{"a.go:1", "b.go:2"}, 2, []string{"a.go", "b.go"}, nil},
+		{"no limit", []string{"a.go:1", "b.go:2"}, 0, []string{"a.go", "b.go"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var diff strings.Builder
+			for _, f := range tt.files {
+				path, n, _ := strings.Cut(f, ":")
+				lines, _ := strconv.Atoi(n)
+				diff.WriteString(fileDiff(path, lines))
+			}
+			got := limitDiffFiles(diff.String(), tt.max)
+			for _, p := range tt.kept {
+				if !strings.Contains(got, "diff --git a/"+p+" ") {
+					t.Errorf("the diff of %s was left out:\n%s", p, got)
+				}
+			}
+			if tt.omitted == nil {
+				if got != diff.String() {
+					t.Errorf("diff changed under the limit:\n%s", got)
+				}
+				return
+			}
+			list, ok := strings.CutPrefix(got[strings.Index(got, generator.OmittedFilesTitle):], generator.OmittedFilesTitle)
+			if !ok {
+				t.Fatalf("no list of omitted files:\n%s", got)
+			}
+			var names []string
+			for _, l := range strings.Split(strings.TrimSpace(list), "\n") {
+				names = append(names, strings.TrimSpace(l))
+			}
+			if strings.Join(names, "|") != strings.Join(tt.omitted, "|") {
+				t.Errorf("omitted files = %q, want %q", names, tt.omitted)
+			}
+			for _, p := range tt.omitted {
+				path, _, _ := strings.Cut(p, " ")
+				if strings.Contains(got, "diff --git a/"+path+" ") {
+					t.Errorf("the diff of omitted %s is still there:\n%s", path, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHasConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		unmerged string
+		diff     string
+		want     bool
+	}{
+		{"unmerged paths", "src/a.go\n", "", true},
+		{"added markers", "", "@@ -1 +1,5 @@\n+<<<<<<< HEAD\n+ours\n+=======\n+theirs\n+>>>>>>> topic\n", true},
+		{"markers being removed", "", "@@ -1,5 +1 @@\n-<<<<<<< HEAD\n-ours\n-=======\n-theirs\n->>>>>>> topic\n+ours\n", false},
+		{"marker-like context", "", "@@ -1,2 +1,3 @@\n <<<<<<< not a conflict\n+x\n", false},
+		{"clean", "", "@@ -1 +1 @@\n-old\n+new\n", false},
+	}
+	for _, tt := range tests {
+		if got := hasConflicts(tt.unmerged, tt.diff); got != tt.want {
+			t.Errorf("%s: hasConflicts = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
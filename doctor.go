@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+type check struct {
+	name string
+	ok   bool
+	info string // shown on success
+	hint string // shown on failure
+}
+
+// runDoctor implements `commit doctor [--live]`.
+func runDoctor(args []string, model string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	live := fs.Bool("live", false, "Also make a tiny test call to the model")
+	fs.Parse(args)
+
+	provider := generator.ProviderOf(model)
+	var checks []check
+
+	gitPath, err := exec.LookPath("git")
+	checks = append(checks, check{
+		name: "git is installed",
+		ok:   err == nil,
+		info: gitPath,
+		hint: "install git and make sure it is on your PATH; without it commit can only describe changes (with go-git), not commit them",
+	})
+
+	_, err = runGit("rev-parse", "--is-inside-work-tree")
+	where := repo.Top
+	if repo.Worktree() {
+		where += ", a worktree of " + filepath.Dir(repo.CommonDir)
+	}
+	checks = append(checks, check{
+		name: "current directory is a git repository",
+		ok:   err == nil,
+		info: where,
+		hint: "cd into a repository or run git init",
+	})
+
+	keyName, key := generator.APIKeyFor(provider)
+	if provider == "ollama" {
+		key = generator.OllamaAddress()
+		checks = append(checks, check{
+			name: "Ollama server is reachable",
+			ok:   generator.OllamaReachable(),
+			info: key,
+			hint: "start it with ollama serve, or set OLLAMA_HOST",
+		})
+		if checks[len(checks)-1].ok {
+			c := check{name: "model " + model + " is pulled", ok: true}
+			if err := checkOllama(runCtx, model); err != nil {
+				c.ok, c.hint = false, err.Error()
+			}
+			checks = append(checks, c)
+		}
+	} else {
+		checks = append(checks, check{
+			name: fmt.Sprintf("API key for %s is set", provider),
+			ok:   key != "",
+			info: keyName,
+			hint: fmt.Sprintf("export one of %v (see README), or run commit init", generator.ProviderKeyEnv[provider]),
+		})
+	}
+
+	if *live && key != "" {
+		checks = append(checks, pingModel(model))
+	}
+
+	failed := false
+	for _, c := range checks {
+		if c.ok {
+			line := success("✓") + " " + c.name
+			if c.info != "" {
+				line += " (" + c.info + ")"
+			}
+			fmt.Println(line)
+		} else {
+			failed = true
+			fmt.Printf("%s %s\n    → %s\n", failure("✗"), c.name, c.hint)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func pingModel(model string) check {
+	ctx, cancel := context.WithTimeout(runCtx, 20*time.Second)
+	defer cancel()
+
+	c := check{name: "model " + model + " responds"}
+	g := newGenerator(ctx, model)
+	start := time.Now()
+	_, err := g.Ask(ctx, generator.Options{}, "", "Reply with OK.")
+	if err != nil {
+		c.hint = fmt.Sprintf("test call failed: %v", err)
+		return c
+	}
+	c.ok = true
+	c.info = time.Since(start).Round(time.Millisecond).String()
+	return c
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// dumpPath is where --print-prompt-and-response records each model exchange;
+// empty disables it. dumpMu serializes writes from parallel generations.
+var (
+	dumpPath string
+	dumpMu   sync.Mutex
+)
+
+// resolveDumpPath maps the flag value to a file: "auto" picks a timestamped
+// file under the cache directory.
+func resolveDumpPath(value string) string {
+	if value != "auto" {
+		return value
+	}
+	dir, _ := os.UserCacheDir()
+	return filepath.Join(dir, "commit", "dumps", time.Now().Format("20060102-150405")+".txt")
+}
+
+// recordExchange appends the exact prompts sent and the raw response
+// received to dumpPath. Failures are logged, never fatal.
+func recordExchange(system, prompt, response string) {
+	if dumpPath == "" {
+		return
+	}
+	dumpMu.Lock()
+	defer dumpMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(dumpPath), 0700); err != nil {
+		warnf("Could not write prompt dump: %v", err)
+		return
+	}
+	f, err := os.OpenFile(dumpPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		warnf("Could not write prompt dump: %v", err)
+		return
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "=== %s ===\n--- system prompt ---\n%s\n--- user prompt ---\n%s\n--- response ---\n%s\n\n",
+		time.Now().Format(time.RFC3339), system, prompt, response)
+}
+
+// printDryRun implements --dry-run: the prompts exactly as they would be
+// sent, after redaction and trimming, and what calls requests of them would
+// cost.
+func printDryRun(model string, opts generator.Options, gc gitctx.CommitContext, calls int) {
+	system, prompt := generator.SystemPrompt(opts), generator.UserPrompt(opts, gc)
+	fmt.Printf("%s\n%s\n\n%s\n%s\n", header("System prompt:"), system, header("User prompt:"), prompt)
+	u := usageJSON{
+		InputTokens:  estimateTokens(system+prompt) * calls,
+		OutputTokens: cmp.Or(opts.MaxTokens, expectedOutputTokens) * calls,
+		Estimated:    true,
+	}
+	if cost, ok := estimateCost(model, u.InputTokens, u.OutputTokens); ok {
+		u.CostUSD = &cost
+	}
+	requests := "1 request"
+	if calls > 1 {
+		requests = fmt.Sprintf("%d requests", calls)
+	}
+	infof("Dry run: %s to %s would use %s; nothing was sent.", requests, model, u)
+}
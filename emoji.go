@@ -0,0 +1,71 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// gitmojis maps Conventional Commits types to their usual gitmoji, the
+// default set for --emoji.
+var gitmojis = map[string]string{
+	"feat":     "✨",
+	"fix":      "🐛",
+	"docs":     "📝",
+	"style":    "🎨",
+	"refactor": "♻️",
+	"perf":     "⚡️",
+	"test":     "✅",
+	"build":    "📦️",
+	"ci":       "👷",
+	"chore":    "🔧",
+	"revert":   "⏪️",
+}
+
+// emojiMap merges the config's emoji map over gitmojis. An empty value turns
+// a type's emoji off. Types that no other setting knows about are reported,
+// since they are usually typos.
+func emojiMap(overrides map[string]string, knownTypes []string) (emoji map[string]string, unknown []string) {
+	emoji = maps.Clone(gitmojis)
+	for t, e := range overrides {
+		t = strings.ToLower(t)
+		if _, ok := gitmojis[t]; !ok && !slices.Contains(knownTypes, t) {
+			unknown = append(unknown, t)
+		}
+		emoji[t] = e
+	}
+	slices.Sort(unknown)
+	return emoji, unknown
+}
+
+// withEmoji puts the emoji for the subject's type in front of it. Subjects
+// without a type prefix, or with one that has no emoji, are left alone.
+func withEmoji(subject string, emoji map[string]string) string {
+	m := typePrefixRe.FindStringSubmatch(subject)
+	if m == nil {
+		return subject
+	}
+	if e := emoji[strings.ToLower(m[1])]; e != "" {
+		return e + " " + subject
+	}
+	return subject
+}
+
+// trimEmoji splits a leading emoji that --emoji would have put there, one of
+// gitmojis or of emoji and with or without its variation selector, off
+// subject, so the type prefix after it can be checked. Subjects that don't
+// start with one come back whole in rest.
+func trimEmoji(subject string, emoji map[string]string) (prefix, rest string) {
+	known := slices.Concat(slices.Collect(maps.Values(gitmojis)), slices.Collect(maps.Values(emoji)))
+	for _, e := range known {
+		known = append(known, strings.TrimSuffix(e, "\ufe0f"))
+	}
+	// The longest first, so an emoji isn't cut off before its selector.
+	slices.SortFunc(known, func(a, b string) int { return len(b) - len(a) })
+	for _, e := range known {
+		if e != "" && strings.HasPrefix(subject, e+" ") {
+			return subject[:len(e)+1], subject[len(e)+1:]
+		}
+	}
+	return "", subject
+}
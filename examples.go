@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// loadExamples reads a JSON array of examples, keeping at most max of them.
+func loadExamples(path string, max int) ([]generator.Example, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var examples []generator.Example
+	if err := json.Unmarshal(data, &examples); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, e := range examples {
+		if strings.TrimSpace(e.Message) == "" {
+			return nil, fmt.Errorf("%s: example %d has no message", path, i+1)
+		}
+	}
+	if max >= 0 && len(examples) > max {
+		examples = examples[:max]
+	}
+	return examples, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// commitIgnoreName is the per-repository list of paths left out of the
+// prompt, one pattern per line, kept in the repository root.
+const commitIgnoreName = ".commitignore"
+
+// excludePatterns is the config's exclude_paths plus the repository's
+// .commitignore, set once in main.
+var excludePatterns []string
+
+// loadCommitIgnore reads the .commitignore in the repository root. Blank
+// lines and lines starting with '#' are skipped.
+func loadCommitIgnore() ([]string, error) {
+	root, err := runGit("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, nil
+	}
+	f, err := os.Open(filepath.Join(root, commitIgnoreName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var patterns []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, sc.Err()
+}
+
+// statusPathRe finds the path in a file line of git status, after the
+// "modified:" style label that untracked files don't have.
+var statusPathRe = regexp.MustCompile(`^\t(?:[a-z ]+:\s+)?(.+)$`)
+
+// excludeFiles drops the files matching patterns from the diffs, the file
+// list, and git status, so lockfiles and generated code don't drown out the
+// change. It returns the paths dropped. When every changed file matches,
+// nothing is dropped: describing them beats describing nothing.
+func excludeFiles(gc gitctx.CommitContext, patterns []string) (gitctx.CommitContext, []string) {
+	if len(patterns) == 0 {
+		return gc, nil
+	}
+	excluded := func(p string) bool { return isSensitive(p, patterns) }
+	var dropped []string
+	kept := 0
+	for _, c := range gitctx.ParseNameStatus(gc.NameStatus) {
+		if excluded(c.Path) {
+			dropped = append(dropped, c.Path)
+		} else {
+			kept++
+		}
+	}
+	if len(dropped) == 0 || kept == 0 {
+		return gc, nil
+	}
+
+	strip := func(diff string) string {
+		var b strings.Builder
+		for _, f := range gitctx.SplitFiles(diff) {
+			if f.Path == "" || !excluded(f.Path) {
+				b.WriteString(f.Text)
+			}
+		}
+		return strings.TrimRight(b.String(), "\n")
+	}
+	gc.Diff, gc.DiffNoWS = strip(gc.Diff), strip(gc.DiffNoWS)
+
+	var lines []string
+	for _, line := range strings.Split(gc.NameStatus, "\n") {
+		fields := strings.Split(line, "\t")
+		if !excluded(fields[len(fields)-1]) {
+			lines = append(lines, line)
+		}
+	}
+	gc.NameStatus = strings.Join(lines, "\n")
+
+	lines = lines[:0]
+	for _, line := range strings.Split(gc.Status, "\n") {
+		if m := statusPathRe.FindStringSubmatch(line); m != nil {
+			old, p, _ := strings.Cut(m[1], " -> ")
+			if excluded(old) || p != "" && excluded(p) {
+				continue
+			}
+		}
+		lines = append(lines, line)
+	}
+	gc.Status = strings.Join(lines, "\n")
+
+	slices.Sort(dropped)
+	return gc, dropped
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// exemplarKey is the multi-valued git config key `commit exemplar` keeps the
+// repository's exemplar commits in, one full SHA each.
+const exemplarKey = gitConfigSection + ".exemplar"
+
+// runExemplar implements `commit exemplar add|remove|list`: it keeps the
+// commits whose messages are shown to the model as examples of the
+// repository's voice, in the repository's own git config.
+func runExemplar(args []string, cfg Config) {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "add":
+		if len(args) < 2 {
+			fatalf("Usage: commit exemplar add <commit>...")
+		}
+		for _, rev := range args[1:] {
+			sha := resolveCommit(rev)
+			if slices.Contains(cfg.Exemplars, sha) {
+				infof("%s is already an exemplar.", sha[:12])
+				continue
+			}
+			if _, err := runGit("config", "--local", "--add", exemplarKey, sha); err != nil {
+				gitFatalf("git config failed: %v", err)
+			}
+			cfg.Exemplars = append(cfg.Exemplars, sha)
+			fmt.Println(success("Added " + sha[:12] + " " + commitSubject(sha)))
+		}
+	case "remove", "rm":
+		if len(args) < 2 {
+			fatalf("Usage: commit exemplar remove <commit>...")
+		}
+		for _, rev := range args[1:] {
+			// A listed commit may be gone after a rebase, so the list is
+			// matched by prefix before rev is resolved.
+			i := slices.IndexFunc(cfg.Exemplars, func(sha string) bool { return strings.HasPrefix(sha, rev) })
+			var sha string
+			if i >= 0 && len(rev) >= 4 {
+				sha = cfg.Exemplars[i]
+			} else {
+				sha = resolveCommit(rev)
+			}
+			if _, err := runGit("config", "--local", "--unset-all", exemplarKey, "^"+sha+"$"); err != nil {
+				warnf("%s isn't an exemplar in this repository's git config (exemplars in .commitrc are removed by editing it).", shortSHA(sha))
+				continue
+			}
+			fmt.Println(success("Removed " + shortSHA(sha) + " " + commitSubject(sha)))
+		}
+	case "list", "ls":
+		if len(cfg.Exemplars) == 0 {
+			fmt.Println("No exemplars yet. Add a commit whose message is a good example with: commit exemplar add <commit>")
+			return
+		}
+		for _, sha := range cfg.Exemplars {
+			if subject := commitSubject(sha); subject != "" {
+				fmt.Printf("%s %s\n", shortSHA(sha), subject)
+			} else {
+				fmt.Printf("%s %s\n", shortSHA(sha), warn("(not found; rewritten or not fetched?)"))
+			}
+		}
+	default:
+		fatalf("Unknown exemplar command %q (want add, remove, or list)", args[0])
+	}
+}
+
+// resolveCommit turns rev into the full SHA of a commit, or ends the run.
+func resolveCommit(rev string) string {
+	sha, err := runGit("rev-parse", "--verify", "-q", rev+"^{commit}")
+	if err != nil || sha == "" {
+		errorf("%s isn't a commit in this repository.", rev)
+		os.Exit(1)
+	}
+	return sha
+}
+
+func commitSubject(sha string) string {
+	subject, _ := runGit("log", "-1", "--format=%s", sha, "--")
+	return subject
+}
+
+func shortSHA(sha string) string {
+	return sha[:min(len(sha), 12)]
+}
+
+// exemplarExamples turns exemplar commits into few-shot examples: each
+// message with the commit's files and line counts in place of its diff,
+// which is usually too long to repeat in every prompt. Commits that can't
+// be read, such as ones lost to a rebase, are skipped.
+func exemplarExamples(shas []string) []generator.Example {
+	var examples []generator.Example
+	seen := map[string]bool{}
+	for _, sha := range shas {
+		if seen[sha] {
+			continue
+		}
+		seen[sha] = true
+		msg, err := runGit("log", "-1", "--format=%B", sha, "--")
+		if err != nil {
+			warnf("Skipping exemplar %s: %v", shortSHA(sha), err)
+			continue
+		}
+		nameStatus, _ := runGit("show", "--format=", "-M", "--name-status", sha)
+		diff, _ := runGit("show", "--format=", "-M", sha)
+		examples = append(examples, generator.Example{Diff: gitctx.StatSummary(nameStatus, diff), Message: msg})
+	}
+	return examples
+}
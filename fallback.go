@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// withFallback runs generate with g and, when it still fails after its
+// retries, with each model of chain in turn, e.g. a bigger model and then a
+// local one. The suggestion records the model that produced it. An
+// interrupted run doesn't fall back. A model of chain that checkModel finds
+// unusable, such as one without its API key, is skipped with a warning.
+func withFallback(ctx context.Context, g *generator.Generator, opts generator.Options, chain []string, generate func(*generator.Generator, generator.Options) (generator.Suggestion, error)) (generator.Suggestion, error) {
+	sg, err := generate(g, opts)
+	for _, m := range chain {
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+		if cerr := checkModel(ctx, m); cerr != nil {
+			warnf("Skipping fallback model %s: %v", m, cerr)
+			continue
+		}
+		warnf("Generation with %s failed: %v; falling back to %s.", opts.Model, err, m)
+		opts.Model = m
+		sg, err = generate(newGenerator(ctx, m), opts)
+	}
+	sg.Model = opts.Model
+	return sg, err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// readFileList reads newline-separated paths for --files-from, from stdin
+// when name is "-". Blank lines are skipped. The paths are relative to the
+// working directory and are returned relative to the top level, where git
+// runs.
+func readFileList(name string) ([]string, error) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, line := range strings.Split(gitctx.NormalizeNewlines(string(data)), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paths = append(paths, topRelative(line))
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%s lists no files", name)
+	}
+	return paths, nil
+}
+
+// changedFiles lists the files diffArgs changes, relative to the top level
+// as git prints them, with the untracked files of a work tree description.
+// Renames count as both paths.
+func changedFiles(diffArgs []string) ([]string, error) {
+	out, err := runGit(append(slices.Clone(diffArgs), "--name-only", "--no-renames")...)
+	if err != nil {
+		return nil, err
+	}
+	changed := strings.Split(out, "\n")
+	if untracked != gitctx.UntrackedNone {
+		_, paths := gitctx.SplitPathspec(diffArgs)
+		files, err := gitctx.Repo{Git: runGit}.UntrackedFiles(paths)
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, files...)
+	}
+	return changed, nil
+}
+
+// topRelative turns a path relative to the working directory into one
+// relative to the top level.
+func topRelative(p string) string {
+	return path.Clean(repo.Prefix + filepath.ToSlash(p))
+}
+
+// checkFileList makes sure every listed path, relative to the top level, is
+// among the files diffArgs changes. A missing path that isn't a
+// deletion is reported as not existing.
+func checkFileList(paths, diffArgs []string) error {
+	changed, err := changedFiles(diffArgs)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if slices.Contains(changed, p) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(repo.Top, filepath.FromSlash(p))); err != nil {
+			return fmt.Errorf("%s does not exist", p)
+		}
+		return fmt.Errorf("%s has no changes", p)
+	}
+	return nil
+}
+
+// unlistedChanges returns the files diffArgs changes that aren't in paths.
+func unlistedChanges(paths, diffArgs []string) []string {
+	changed, err := changedFiles(diffArgs)
+	if err != nil {
+		return nil
+	}
+	listed := map[string]bool{}
+	for _, p := range paths {
+		listed[p] = true
+	}
+	var extra []string
+	for _, c := range changed {
+		if c != "" && !listed[c] {
+			extra = append(extra, c)
+		}
+	}
+	return extra
+}
@@ -0,0 +1,45 @@
+package main
+
+import "strings"
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ", ") }
+
+// Type names the value in --help.
+func (l *stringList) Type() string { return "string" }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// optionalString is a flag that works bare, like a bool flag, or with a
+// value given as --name=value. Given reports whether it was used at all.
+type optionalString struct {
+	Given bool
+	Value string
+}
+
+func (o *optionalString) String() string { return o.Value }
+
+func (o *optionalString) Set(v string) error {
+	o.Given = true
+	if v != "true" {
+		o.Value = v
+	}
+	return nil
+}
+
+func (o *optionalString) IsBoolFlag() bool { return true }
+
+// Type names the value in --help.
+func (o *optionalString) Type() string { return "string" }
+
+// flagSet reports whether the named flag was given on the command line, as
+// opposed to holding its default.
+func flagSet(name string) bool {
+	f := rootCommand.PersistentFlags().Lookup(name)
+	return f != nil && f.Changed
+}
@@ -0,0 +1,86 @@
+package generator
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// Built-in diff buckets, in the order their sections appear in the prompt.
+// Buckets named in the config's bucket map come after them, by name.
+var defaultBuckets = []string{"source", "tests", "docs", "config"}
+
+var (
+	testDirs    = []string{"test", "tests", "__tests__", "spec", "testdata"}
+	configExts  = []string{".json", ".yaml", ".yml", ".toml", ".ini", ".cfg", ".conf", ".env", ".properties", ".xml"}
+	configFiles = []string{"go.mod", "go.sum", "Makefile", "Dockerfile", "package-lock.json", "yarn.lock", "Cargo.lock", "pnpm-lock.yaml"}
+)
+
+// DefaultBucket classifies a path by naming conventions: test files and
+// directories, documentation, build and configuration files, and source code
+// for everything else.
+func DefaultBucket(p string) string {
+	base := path.Base(p)
+	dirs := strings.Split(path.Dir(p), "/")
+	switch {
+	case strings.Contains(base, "_test.") || strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") || strings.HasPrefix(base, "test_"):
+		return "tests"
+	case slices.ContainsFunc(dirs, func(d string) bool { return slices.Contains(testDirs, d) }):
+		return "tests"
+	case slices.Contains(DocExts, strings.ToLower(path.Ext(p))) || dirs[0] == "docs":
+		return "docs"
+	case slices.Contains(configExts, strings.ToLower(path.Ext(p))) || slices.Contains(configFiles, base) || strings.HasPrefix(base, ".") || dirs[0] == ".github":
+		return "config"
+	}
+	return "source"
+}
+
+// BucketFor picks the bucket of a path: the longest matching pattern of the
+// config's bucket map (pattern -> bucket), else DefaultBucket.
+func BucketFor(bucketMap map[string]string, p string) string {
+	best := ""
+	for pattern := range bucketMap {
+		if len(pattern) > len(best) && gitctx.MatchPath(pattern, p) {
+			best = pattern
+		}
+	}
+	if best != "" {
+		return bucketMap[best]
+	}
+	return DefaultBucket(p)
+}
+
+// bucketSections splits a diff into one prompt section per bucket, each
+// titled with the bucket name. Files listed under OmittedFilesTitle without
+// a diff stay in a section of their own at the end.
+func bucketSections(diff string, bucketMap map[string]string) []promptSection {
+	diff, omitted, _ := strings.Cut(diff, "\n\n"+OmittedFilesTitle)
+	byBucket := map[string]string{}
+	for _, f := range gitctx.SplitFiles(diff) {
+		b := BucketFor(bucketMap, f.Path)
+		byBucket[b] += f.Text
+	}
+	names := slices.Clone(defaultBuckets)
+	var custom []string
+	for b := range byBucket {
+		if !slices.Contains(names, b) {
+			custom = append(custom, b)
+		}
+	}
+	slices.Sort(custom)
+
+	var sections []promptSection
+	for _, b := range append(names, custom...) {
+		if byBucket[b] != "" {
+			sections = append(sections, promptSection{"Diff (" + b + "):", byBucket[b]})
+		}
+	}
+	if omitted != "" {
+		sections = append(sections, promptSection{OmittedFilesTitle, omitted})
+	}
+	return sections
+}
+
+var DocExts = []string{".md", ".rst", ".txt", ".adoc"}
@@ -0,0 +1,313 @@
+// Package generator writes commit messages for a gitctx.CommitContext with a
+// model from one of the registered providers.
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// const DefaultModel = "googleai/gemini-3-flash-preview"
+// const DefaultModel = "googleai/gemini-3.1-pro-preview"
+const DefaultModel = "googleai/gemini-3.1-flash-lite-preview"
+
+// Options controls how a commit message is generated.
+type Options struct {
+	Style          Style
+	Mood           Mood
+	Safety         Safety
+	Explain        bool   // also ask the model for a short rationale
+	Structured     bool   // ask for the message's parts as JSON, see StructuredMessage
+	SystemPrompt   string // replaces the built-in prompt when set
+	StyleTemplate  string // replaces the style's format rules, see --style-template
+	SubjectOnly    bool   // generate only a subject line and append KeepBody to it
+	KeepBody       string // existing body and footers, with their leading newlines
+	SingleLine     bool   // force a subject-only message regardless of style
+	Body           bool   // ask for a bullet-point body below the subject
+	BodyWidth      int    // column the body should be wrapped at
+	SubjectCase    SubjectCase
+	SubjectLimit   int               // longest subject to ask for; 0 means DefaultSubjectLimit
+	Notes          []string          // author-provided context the diff doesn't convey
+	WhitespaceOnly bool              // the diff only changes whitespace or formatting
+	Examples       []Example         // few-shot examples placed before the diff
+	MaxTokens      int               // rough size budget for the whole message
+	StatOnly       bool              // send file names and line counts instead of the diff
+	Ticket         string            // title and summary of the ticket named by the branch
+	Templates      map[string]string // body templates keyed by type, see classifyType
+	Type           string            // type from the branch or picked by classifyType; Template is its body
+	Template       string
+	Scopes         []string      // candidate scopes of the changed files, see --scope-from
+	Model          string        // name of the model generated with, for logging
+	MaxRetries     int           // extra attempts per model after a failed or empty answer
+	PromptAppend   string        // user instructions added to the system prompt
+	ModelOptions   []ModelOption // provider settings from --model-option
+	StyleGuide     string        // team commit conventions, see --style-guide
+	WIP            bool          // a work-in-progress checkpoint, see --wip
+	Empty          bool          // a commit without changes, see --allow-empty
+	OldSubject     string        // subject being replaced, see --reword-last
+	MultiType      bool          // keep secondary changes as "Also <type>:" body lines
+	NoLog          bool          // leave recent commits out of the prompt
+	DiffSummary    string        // stands in for the diff, see --mapreduce
+	ReleaseTool    ReleaseTool   // release tool whose commit rules apply, see --release-tool
+	DiffStat       string        // every file's line counts when only some hunks fit, see --token-budget
+	OnChunk        func(string)  // receives the answer as it arrives, see --stream
+	Timeout        time.Duration // limit on each model request, see --timeout
+	Compact        bool          // short rules and less context for small models, see --prompt-profile
+	BreakingCheck  bool          // ask for breaking change markers, see --no-breaking-check
+	Breaking       []string      // public API breaks found in the diff
+	// BucketDiff splits the diff into labeled sections by kind of file;
+	// Buckets overrides the classification, see BucketFor.
+	BucketDiff bool
+	Buckets    map[string]string
+	Language   string // BCP 47 tag of the language to write in, see --lang
+	// HistoryStyle lists the conventions of the repository's recent
+	// commits, to be matched where the rules leave a choice.
+	HistoryStyle string
+}
+
+// Suggestion is a generated commit message. Rationale is only filled in when
+// an explanation was requested and is never part of the message itself.
+type Suggestion struct {
+	Message   string `json:"message"`
+	Rationale string `json:"rationale,omitempty"`
+	Attempts  int    `json:"-"` // model calls it took, including retries
+	Model     string `json:"-"` // model that produced it, shown in interactive mode
+	Usage     Usage  `json:"-"` // tokens of the calls it took, including retries
+}
+
+// Usage is how many tokens generations took, as the provider reported them.
+// It is zero when the provider reports nothing.
+type Usage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// usageOf returns the token usage a provider reported with res.
+func usageOf(res *ai.ModelResponse) Usage {
+	if res == nil || res.Usage == nil {
+		return Usage{}
+	}
+	return Usage{res.Usage.InputTokens, res.Usage.OutputTokens}
+}
+
+// ErrEmptyMessage is returned when the model keeps answering with nothing.
+var ErrEmptyMessage = errors.New("model returned an empty commit message")
+
+// Generator generates commit messages with one model.
+type Generator struct {
+	g *genkit.Genkit
+	// Debugf and Warnf, when set, receive diagnostics and retry warnings.
+	Debugf, Warnf func(format string, args ...any)
+	// OnExchange, when set, receives the prompts and the raw answer of each
+	// generation.
+	OnExchange func(system, prompt, response string)
+}
+
+// New sets up genkit with the plugin for model's provider and makes
+// model the default. Unknown providers fall back to googleai, as unqualified
+// names always have.
+func New(ctx context.Context, model string) *Generator {
+	factory, ok := LookupProvider(ProviderOf(model))
+	if !ok {
+		factory, _ = LookupProvider("googleai")
+	}
+	plugin, _ := factory()
+	g := genkit.Init(ctx, genkit.WithPlugins(plugin), genkit.WithDefaultModel(model))
+	if d, ok := plugin.(modelDefiner); ok {
+		d.defineModel(g, model)
+	}
+	return &Generator{g: g}
+}
+
+func (gen *Generator) debugf(format string, args ...any) {
+	if gen.Debugf != nil {
+		gen.Debugf(format, args...)
+	}
+}
+
+func (gen *Generator) warnf(format string, args ...any) {
+	if gen.Warnf != nil {
+		gen.Warnf(format, args...)
+	}
+}
+
+func (gen *Generator) record(system, prompt, response string) {
+	gen.debugf("raw response:\n%s", response)
+	if gen.OnExchange != nil {
+		gen.OnExchange(system, prompt, response)
+	}
+}
+
+// Generate asks the model for a commit message. Failed calls and empty
+// answers are retried up to opts.MaxRetries times with jittered backoff.
+func (gen *Generator) Generate(ctx context.Context, opts Options, gc gitctx.CommitContext) (Suggestion, error) {
+	// With templates the type is settled first so its template can shape
+	// the body. Failing to classify just means no template.
+	// A type already known from the branch skips the classification.
+	if len(opts.Templates) > 0 && opts.Template == "" && opts.Style != StyleSimple && !opts.SingleLine && !opts.SubjectOnly {
+		kind := opts.Type
+		if kind == "" {
+			var err error
+			if kind, err = gen.classifyType(ctx, opts, gc); err != nil {
+				gen.debugf("Could not classify the change type: %v", err)
+			}
+		}
+		if t, ok := opts.Templates[kind]; ok {
+			opts.Type, opts.Template = kind, t
+		}
+	}
+	var used Usage
+	for attempt := 1; ; attempt++ {
+		sg, err := gen.generateOnce(ctx, opts, gc)
+		used.InputTokens += sg.Usage.InputTokens
+		used.OutputTokens += sg.Usage.OutputTokens
+		sg.Usage = used
+		if err == nil && sg.Message == "" {
+			err = ErrEmptyMessage
+		}
+		if err == nil {
+			gen.debugf("model=%s attempts=%d", opts.Model, attempt)
+			sg.Attempts = attempt
+			if opts.SubjectOnly {
+				subject, _ := SplitMessage(sg.Message)
+				sg.Message = JoinMessage(strings.TrimSpace(subject), opts.KeepBody)
+			}
+			return sg, nil
+		}
+		if attempt > opts.MaxRetries || !retryable(err) {
+			gen.debugf("model=%s attempts=%d error=%q", opts.Model, attempt, err)
+			return Suggestion{}, err
+		}
+		delay := backoffDelay(attempt - 1)
+		gen.warnf("model=%s attempt=%d error=%q retrying in %s", opts.Model, attempt, err, delay.Round(time.Millisecond))
+		if err := sleepCtx(ctx, delay); err != nil {
+			return Suggestion{}, err
+		}
+	}
+}
+
+func (gen *Generator) generateOnce(ctx context.Context, opts Options, gc gitctx.CommitContext) (Suggestion, error) {
+	system, prompt := SystemPrompt(opts), UserPrompt(opts, gc)
+	gen.debugf("system prompt:\n%s", system)
+	gen.debugf("user prompt:\n%s", prompt)
+	genOpts := []ai.GenerateOption{
+		ai.WithSystem("%s", system),
+		ai.WithPrompt("%s", prompt),
+	}
+	if cfg := GenerationConfig(opts); cfg != nil {
+		genOpts = append(genOpts, ai.WithConfig(cfg))
+	}
+	ctx, cancel := withTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	if opts.Explain {
+		out, res, err := genkit.GenerateData[Suggestion](ctx, gen.g, genOpts...)
+		if IsBlocked(res, err) {
+			return Suggestion{}, ErrBlocked
+		}
+		if err != nil {
+			return Suggestion{}, modelError(ctx, opts, err)
+		}
+		gen.record(system, prompt, res.Text())
+		return Suggestion{
+			Message:   strings.TrimSpace(out.Message),
+			Rationale: strings.TrimSpace(out.Rationale),
+			Usage:     usageOf(res),
+		}, nil
+	}
+
+	if opts.Structured {
+		return gen.generateStructured(ctx, opts, system, prompt)
+	}
+	if opts.OnChunk != nil {
+		genOpts = append(genOpts, ai.WithStreaming(func(_ context.Context, chunk *ai.ModelResponseChunk) error {
+			opts.OnChunk(chunk.Text())
+			return nil
+		}))
+	}
+	res, err := genkit.Generate(ctx, gen.g, genOpts...)
+	if IsBlocked(res, err) {
+		return Suggestion{}, ErrBlocked
+	}
+	if err != nil {
+		return Suggestion{}, modelError(ctx, opts, err)
+	}
+	gen.record(system, prompt, res.Text())
+	return Suggestion{Message: strings.TrimSpace(res.Text()), Usage: usageOf(res)}, nil
+}
+
+// classifyType asks the model only for the Conventional Commits type of the
+// change, choosing among the types that have a template or the usual ones.
+func (gen *Generator) classifyType(ctx context.Context, opts Options, gc gitctx.CommitContext) (string, error) {
+	types := slices.Clone(CommitTypes)
+	for t := range opts.Templates {
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	slices.Sort(types)
+	system := "You classify git changes.\nReply with ONLY the Conventional Commits type that best fits the change, one of: " + strings.Join(types, ", ") + "."
+	answer, err := gen.Ask(ctx, opts, system, UserPrompt(opts, gc))
+	if err != nil {
+		return "", err
+	}
+	kind := strings.ToLower(strings.Trim(answer, "`.:"))
+	if !slices.Contains(types, kind) {
+		return "", fmt.Errorf("model answered %q, not a known type", kind)
+	}
+	return kind, nil
+}
+
+// Ask sends one system and user prompt to the model with opts' safety
+// settings, model options, and timeout, and returns the trimmed answer. It is
+// the building block of the helper requests around a generation, such as
+// shortening a subject or checking a message against the diff.
+func (gen *Generator) Ask(ctx context.Context, opts Options, system, prompt string) (string, error) {
+	var genOpts []ai.GenerateOption
+	if system != "" {
+		genOpts = append(genOpts, ai.WithSystem("%s", system))
+	}
+	genOpts = append(genOpts, ai.WithPrompt("%s", prompt))
+	if cfg := GenerationConfig(opts); cfg != nil {
+		genOpts = append(genOpts, ai.WithConfig(cfg))
+	}
+	ctx, cancel := withTimeout(ctx, opts.Timeout)
+	defer cancel()
+	res, err := genkit.Generate(ctx, gen.g, genOpts...)
+	if IsBlocked(res, err) {
+		return "", ErrBlocked
+	}
+	if err != nil {
+		return "", modelError(ctx, opts, err)
+	}
+	return strings.TrimSpace(res.Text()), nil
+}
+
+// withTimeout bounds ctx by d; zero or less means no limit beyond ctx's own.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// modelError reports a model request that ran out of time or was interrupted
+// as such, whatever the provider wrapped the cause in, so it isn't retried.
+func modelError(ctx context.Context, opts Options, err error) error {
+	switch ctx.Err() {
+	case nil:
+		return err
+	case context.DeadlineExceeded:
+		return fmt.Errorf("no answer from %s within %s (raise --timeout): %w", opts.Model, opts.Timeout, context.DeadlineExceeded)
+	default:
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,40 @@
+package generator
+
+import (
+	"fmt"
+	"slices"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// ParseLanguage checks a BCP 47 language tag such as "ja" or "pt-BR" and
+// returns it in canonical form.
+func ParseLanguage(s string) (string, error) {
+	tag, err := language.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid language %q (want a tag such as ja or pt-BR)", s)
+	}
+	return tag.String(), nil
+}
+
+// wideLanguages are written mostly in characters that take two columns.
+var wideLanguages = []language.Base{language.MustParseBase("ja"), language.MustParseBase("zh"), language.MustParseBase("ko")}
+
+// languagePrompt asks for a message in the language tagged lang. English,
+// the language of the built-in prompts, needs no instruction.
+func languagePrompt(lang string) string {
+	tag, err := language.Parse(lang)
+	if lang == "" || err != nil {
+		return ""
+	}
+	base, _ := tag.Base()
+	if base.String() == "en" {
+		return ""
+	}
+	s := fmt.Sprintf("\nWrite the subject description and the body in %s (%s). Keep the type, the scope, trailers such as BREAKING CHANGE:, and code identifiers in English.", display.English.Tags().Name(tag), tag)
+	if slices.Contains(wideLanguages, base) {
+		s += " Each character counts as two toward any length limit."
+	}
+	return s
+}
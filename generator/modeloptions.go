@@ -0,0 +1,193 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// modelOptionKinds lists the generation settings each provider is known to
+// accept, with the JSON kind of their value. Keys are the provider's own
+// spelling: the config is decoded into the SDK's request struct, so Gemini
+// uses camelCase and OpenAI-compatible providers snake_case. Dotted keys set
+// nested fields.
+var modelOptionKinds = map[string]map[string]string{
+	"googleai": {
+		"temperature":                    "number",
+		"topP":                           "number",
+		"topK":                           "number",
+		"maxOutputTokens":                "number",
+		"seed":                           "number",
+		"presencePenalty":                "number",
+		"frequencyPenalty":               "number",
+		"stopSequences":                  "array",
+		"thinkingConfig.thinkingBudget":  "number",
+		"thinkingConfig.includeThoughts": "bool",
+	},
+	"openai": {
+		"temperature":           "number",
+		"top_p":                 "number",
+		"max_tokens":            "number",
+		"max_completion_tokens": "number",
+		"seed":                  "number",
+		"presence_penalty":      "number",
+		"frequency_penalty":     "number",
+		"stop":                  "array",
+		"reasoning_effort":      "string",
+	},
+}
+
+func init() {
+	// The Anthropic plugin speaks the OpenAI-compatible API.
+	modelOptionKinds["anthropic"] = modelOptionKinds["openai"]
+}
+
+// samplingKeys are each provider's spellings of temperature, top-p, and the
+// output token limit, the settings with flags of their own.
+var samplingKeys = map[string][3]string{
+	"googleai":  {"temperature", "topP", "maxOutputTokens"},
+	"openai":    {"temperature", "top_p", "max_completion_tokens"},
+	"anthropic": {"temperature", "top_p", "max_tokens"},
+}
+
+// Sampling holds the settings of --temperature, --top-p, and
+// --max-output-tokens; nil and 0 leave the model's default.
+type Sampling struct {
+	Temperature, TopP *float64
+	MaxOutputTokens   int
+}
+
+// Validate rejects values no provider accepts.
+func (s Sampling) Validate() error {
+	switch {
+	case s.Temperature != nil && (*s.Temperature < 0 || *s.Temperature > 2):
+		return fmt.Errorf("temperature %v is out of range (want 0 to 2)", *s.Temperature)
+	case s.TopP != nil && (*s.TopP <= 0 || *s.TopP > 1):
+		return fmt.Errorf("top-p %v is out of range (want more than 0, up to 1)", *s.TopP)
+	case s.MaxOutputTokens < 0:
+		return fmt.Errorf("max output tokens %d is negative", s.MaxOutputTokens)
+	}
+	return nil
+}
+
+// IsZero reports whether s sets nothing.
+func (s Sampling) IsZero() bool {
+	return s.Temperature == nil && s.TopP == nil && s.MaxOutputTokens == 0
+}
+
+// ModelOptions spells s in provider's keys. They go before the
+// --model-option ones, which win on the same key. ok is false for a
+// provider that takes no settings, such as ollama.
+func (s Sampling) ModelOptions(provider string) (options []ModelOption, ok bool) {
+	keys, ok := samplingKeys[provider]
+	if !ok {
+		return nil, false
+	}
+	if s.Temperature != nil {
+		options = append(options, ModelOption{Key: keys[0], Value: *s.Temperature})
+	}
+	if s.TopP != nil {
+		options = append(options, ModelOption{Key: keys[1], Value: *s.TopP})
+	}
+	if s.MaxOutputTokens > 0 {
+		options = append(options, ModelOption{Key: keys[2], Value: float64(s.MaxOutputTokens)})
+	}
+	return options, true
+}
+
+// ModelOption is one parsed --model-option key=value.
+type ModelOption struct {
+	Key   string
+	Value any
+}
+
+// ParseModelOption splits key=value and decodes the value as JSON when it is
+// valid JSON (numbers, true/false, arrays, objects), or keeps it as a string.
+func ParseModelOption(s string) (ModelOption, error) {
+	key, raw, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return ModelOption{}, fmt.Errorf("invalid model option %q (want key=value)", s)
+	}
+	var v any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		v = raw
+	}
+	return ModelOption{Key: key, Value: v}, nil
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "string"
+}
+
+// CheckModelOptions rejects known keys with a value of the wrong kind and
+// returns the keys that aren't known for the provider, which are passed
+// through as is.
+func CheckModelOptions(provider string, options []ModelOption) (unknown []string, err error) {
+	kinds, ok := modelOptionKinds[provider]
+	if !ok && len(options) > 0 {
+		return nil, fmt.Errorf("the %s provider doesn't accept model options", provider)
+	}
+	for _, o := range options {
+		want, known := kinds[o.Key]
+		if !known {
+			unknown = append(unknown, o.Key)
+			continue
+		}
+		if got := jsonKind(o.Value); got != want {
+			return nil, fmt.Errorf("model option %s wants a %s, got %s %v", o.Key, want, got, o.Value)
+		}
+	}
+	return unknown, nil
+}
+
+// GenerationConfig combines the safety settings with the model options into
+// the request config, or returns nil when there is nothing to set.
+func GenerationConfig(opts Options) any {
+	safety := safetyConfig(opts.Safety)
+	if len(opts.ModelOptions) == 0 {
+		if safety == nil {
+			return nil
+		}
+		return safety
+	}
+	cfg := map[string]any{}
+	if safety != nil {
+		data, _ := json.Marshal(safety)
+		json.Unmarshal(data, &cfg)
+	}
+	for _, o := range opts.ModelOptions {
+		setPath(cfg, strings.Split(o.Key, "."), o.Value)
+	}
+	return cfg
+}
+
+// setPath sets m[a][b]... = v, creating nested maps as needed.
+func setPath(m map[string]any, path []string, v any) {
+	for _, k := range path[:len(path)-1] {
+		next, ok := m[k].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[k] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = v
+}
+
+// KnownModelOptions lists the documented keys for a provider, for help text.
+func KnownModelOptions(provider string) []string {
+	return slices.Sorted(maps.Keys(modelOptionKinds[provider]))
+}
@@ -0,0 +1,349 @@
+package generator
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// DefaultSubjectLimit is the subject length the prompts ask for unless
+// Options.SubjectLimit says otherwise.
+const DefaultSubjectLimit = 50
+
+// SystemPrompt builds the system prompt: the style's rules plus whatever
+// opts asks for on top.
+func SystemPrompt(opts Options) string {
+	system := opts.SystemPrompt
+	limit := cmp.Or(opts.SubjectLimit, DefaultSubjectLimit)
+	switch {
+	case system != "":
+	case opts.StyleTemplate != "":
+		system = basePrompt(opts.Mood) + styleTemplatePrompt(opts.StyleTemplate, limit)
+	case opts.Compact:
+		system = compactSystemPrompt(opts.Style, opts.Mood, limit)
+	default:
+		system = systemPromptForStyle(opts.Style, opts.Mood, limit)
+	}
+	switch {
+	case opts.SingleLine:
+		system += "\nReturn exactly one line: the subject. No body."
+	case opts.Template != "":
+		system += templatePrompt(opts.Type, opts.Template)
+	case opts.Body:
+		system += fmt.Sprintf("\nAfter the subject add a blank line, then a body of \"- \" bullet points explaining what changed and why. Wrap the body at %d columns.", opts.BodyWidth)
+	}
+	if opts.Type != "" && opts.Template == "" {
+		system += typePrompt(opts.Type)
+	}
+	system += casePrompt(opts.SubjectCase)
+	system += languagePrompt(opts.Language)
+	if opts.Style != StyleSimple {
+		system += scopePrompt(opts.Scopes)
+	}
+	if opts.MaxTokens > 0 {
+		system += fmt.Sprintf("\nKeep the whole message under %d tokens (about %d characters).", opts.MaxTokens, opts.MaxTokens*4)
+	}
+	if opts.WhitespaceOnly {
+		system += "\nEvery change in this diff is whitespace or formatting only. Describe it as such (use the style: type where types apply); do not claim behavior changes."
+	}
+	if opts.DiffStat != "" && !opts.StatOnly {
+		system += "\nOnly some hunks of the diff are shown; the file list covers the whole change. Describe the change as a whole, not just the hunks shown."
+	}
+	if opts.StatOnly {
+		system += "\nOnly file names, change types, and line counts are available, not the code. Infer the intent from them and keep the message general rather than guessing details."
+	}
+	if opts.SubjectOnly {
+		system += "\nReturn ONLY a single subject line. Any body the author already wrote is kept as is."
+	}
+	if opts.WIP {
+		system += "\nThis is a work-in-progress checkpoint commit: say in a few words what is in progress, without a type prefix."
+	}
+	if opts.MultiType && !opts.SingleLine {
+		system += multiTypePrompt
+	}
+	if opts.Empty {
+		system += "\nThis commit has no file changes (an empty commit, e.g. to trigger a pipeline). Describe its purpose from the author notes and the branch; do not invent code changes."
+	}
+	if !opts.WIP {
+		system += releaseToolPrompt(opts.ReleaseTool)
+		if opts.BreakingCheck {
+			system += breakingPrompt(opts.Breaking, opts.ReleaseTool == "" && !opts.Compact)
+		}
+	}
+	if !opts.Compact {
+		system += historyStylePrompt(opts.HistoryStyle)
+	}
+	system += styleGuidePrompt(opts.StyleGuide)
+	system += promptAppendix(opts.PromptAppend)
+	if opts.Explain {
+		system += explainPrompt
+	} else if opts.Structured {
+		system += structuredPrompt
+	}
+	return system
+}
+
+// basePrompt is the start of every built-in system prompt; the style or a
+// --style-template adds the format rules.
+func basePrompt(mood Mood) string {
+	return "Be extremely concise. Sacrifice grammar for the sake of concision.\nYou are a semantic git commit message generator.\n" + moodPrompt(mood) + "\nConsider the branch context when choosing message type.\nReturn ONLY the commit message, nothing else."
+}
+
+func systemPromptForStyle(style Style, mood Mood, limit int) string {
+	base := basePrompt(mood)
+
+	switch style {
+	case StyleSimple:
+		return base + fmt.Sprintf("\nDo NOT use any prefix like fix:, feat:, etc. Just write the message directly.\nKeep it under %d chars.", limit)
+	case StyleDetailed:
+		return base + fmt.Sprintf("\nFollow Conventional Commits format.\nReturn exactly two lines: first line is the short title (under %d chars), second line is a brief description (under 100 chars).\nNo blank line between them.", limit)
+	case StyleAngular:
+		return base + "\nFollow the Angular commit convention: type(scope): summary, where type is one of " + strings.Join(AngularTypes, ", ") + " and scope names the affected package or area.\nWrite the summary in lowercase without a trailing period." + fmt.Sprintf("\nKeep subject under %d chars.", limit)
+	default: // conventional
+		return base + fmt.Sprintf("\nFollow Conventional Commits format.\nKeep subject under %d chars.", limit)
+	}
+}
+
+// compactSystemPrompt is the system prompt of the compact profile. Small
+// models follow a few plain rules better than a long list of them.
+func compactSystemPrompt(style Style, mood Mood, limit int) string {
+	var format string
+	switch style {
+	case StyleSimple:
+		format = fmt.Sprintf("One line under %d characters, with no type prefix.", limit)
+	case StyleDetailed:
+		format = fmt.Sprintf("Two lines: \"type: summary\" under %d characters, then one sentence on why.", limit)
+	case StyleAngular:
+		format = fmt.Sprintf("One line: \"type(scope): summary\" under %d characters, lowercase, no period. type is one of ", limit) + strings.Join(AngularTypes, ", ") + "."
+	default:
+		format = fmt.Sprintf("One line: \"type: summary\" under %d characters, e.g. \"fix: handle empty input\". type is feat, fix, docs, refactor, test, or chore.", limit)
+	}
+	return "Write a git commit message for the changes below.\n" + format + "\n" + moodPrompt(mood) + "\nReply with the commit message only."
+}
+
+func moodPrompt(m Mood) string {
+	switch m {
+	case MoodPast:
+		return "Use past tense (e.g. \"added\", \"fixed\")."
+	case MoodPresent:
+		return "Use present tense, third person (e.g. \"adds\", \"fixes\")."
+	}
+	return "Use imperative mood."
+}
+
+// casePrompt is the prompt guidance matching a subject case.
+func casePrompt(c SubjectCase) string {
+	switch c {
+	case CaseLower:
+		return "\nStart the subject description with a lowercase letter."
+	case CaseSentence:
+		return "\nStart the subject description with a capital letter."
+	}
+	return ""
+}
+
+// typePrompt pins the Conventional Commits type.
+func typePrompt(kind string) string {
+	return fmt.Sprintf("\nUse the type %q; the branch name says this is that kind of change.", kind)
+}
+
+// templatePrompt asks for a message of the given type whose body follows
+// template, section by section.
+func templatePrompt(kind, template string) string {
+	return fmt.Sprintf("\nUse the type %q. After the subject add a blank line, then a body that follows this template exactly, keeping its headings and filling in each section briefly:\n%s", kind, strings.TrimSpace(template))
+}
+
+// scopePrompt tells the model which scope(s) the changed files map to.
+func scopePrompt(scopes []string) string {
+	switch len(scopes) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("\nUse the scope %q, e.g. type(%s): description.", scopes[0], scopes[0])
+	}
+	return fmt.Sprintf("\nThe changes span these scopes: %s. Use the one that best describes the change as type(scope), or omit the scope if none dominates.", strings.Join(scopes, ", "))
+}
+
+// styleTemplatePrompt replaces a style's format rules with the template.
+func styleTemplatePrompt(tmpl string, limit int) string {
+	return "\nFormat the message exactly like this template. Replace {type} with the kind of change (feat, fix, docs, refactor, ...), {scope} with the affected area, {subject} with a short summary under " + strconv.Itoa(limit) + " chars, and {body} with a few lines on what changed and why. Keep all other text as written; leave out a line whose placeholders have nothing to say.\n--- BEGIN TEMPLATE ---\n" + tmpl + "\n--- END TEMPLATE ---"
+}
+
+// historyStylePrompt asks for messages that fit in with the repository's
+// history. It comes before a style guide, which wins where they disagree.
+func historyStylePrompt(style string) string {
+	if style == "" {
+		return ""
+	}
+	return "\n\nThis project's recent commits follow these conventions; match them where the rules above leave a choice:\n" + style
+}
+
+// styleGuidePrompt puts the guide in the system prompt, delimited so the
+// model treats it as rules.
+func styleGuidePrompt(guide string) string {
+	if guide == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n\nThe team documents these commit message conventions. Follow them closely.\n--- BEGIN STYLE GUIDE ---\n%s\n--- END STYLE GUIDE ---", guide)
+}
+
+// releaseToolPrompt spells out the rules the tool parses commits by. Only
+// feat and fix bump the version, and a breaking change must be marked
+// exactly, so guessing wrong ships the wrong release.
+func releaseToolPrompt(t ReleaseTool) string {
+	if t == "" {
+		return ""
+	}
+	prompt := fmt.Sprintf("\nThis repository releases with %s, which reads the version bump from this message:", t)
+	prompt += "\n- Use feat only for a new user-facing feature (minor release) and fix only for a user-facing bug fix (patch release). Refactors, tests, docs, CI, and build changes use their own types, which release nothing."
+	switch t {
+	case ReleasePlease:
+		prompt += "\n- For a breaking change, put \"!\" before the colon (feat!: or feat(scope)!:) and end the message with a footer paragraph \"BREAKING CHANGE: <what breaks and how to migrate>\", spelled exactly so."
+	case SemanticRelease:
+		prompt += "\n- For a breaking change, end the message with a footer paragraph \"BREAKING CHANGE: <what breaks and how to migrate>\", spelled exactly so; a \"!\" in the subject alone is not enough."
+	}
+	return prompt + "\n- Never add a BREAKING CHANGE footer unless existing users must change something."
+}
+
+// breakingPrompt asks the model to mark breaking changes the Conventional
+// Commits way, and lists the ones the diff shows. general is false when the
+// rule is already in the prompt, as with --release-tool.
+func breakingPrompt(found []string, general bool) string {
+	var prompt string
+	if general {
+		prompt = "\nIf existing users must change their code or configuration because of this change, put \"!\" before the colon (feat!: or feat(scope)!:) and end the message with a footer paragraph \"BREAKING CHANGE: <what breaks and how to migrate>\". Otherwise add neither."
+	}
+	if len(found) > 0 {
+		prompt += "\nThis change breaks the public API (" + strings.Join(found, "; ") + "), so mark it as a breaking change and explain the migration in the BREAKING CHANGE footer."
+	}
+	return prompt
+}
+
+// multiTypePrompt asks for secondary changes to be kept in the body under a
+// fixed marker instead of being dropped for the subject's single type.
+const multiTypePrompt = "\nIf the change mixes kinds of work (e.g. a fix plus an incidental refactor), describe the main change in the subject and add one body line \"Also <type>: <what>\" for each secondary change (e.g. \"Also refactor: extract the retry helper\") instead of leaving it out."
+
+// promptAppendix wraps extra user instructions in explicit markers so the
+// model treats them as rules rather than as part of the diff context.
+func promptAppendix(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "\n\nAdditional instructions from the user. Follow them; where they conflict with the rules above, they win.\n--- BEGIN USER INSTRUCTIONS ---\n" + text + "\n--- END USER INSTRUCTIONS ---"
+}
+
+const explainPrompt = "\nRespond with JSON: put the commit message in \"message\" and one short sentence explaining the chosen type and scope in \"rationale\"."
+
+// promptSection is one titled block of the user prompt.
+type promptSection struct {
+	Title string
+	Body  string
+}
+
+// UserPrompt builds the user prompt from the repository state. Sections
+// always appear in the same order and their whitespace is normalized, so
+// identical repository states produce byte-identical prompts regardless of
+// how the git output was gathered.
+func UserPrompt(opts Options, gc gitctx.CommitContext) string {
+	notes := make([]string, len(opts.Notes))
+	for i, n := range opts.Notes {
+		notes[i] = "- " + strings.TrimSpace(n)
+	}
+	log := gc.Log
+	if opts.NoLog || opts.Compact {
+		log = ""
+	}
+	if gc.Initial {
+		log = "None: this is the first commit of the repository, so describe what it starts with."
+	}
+	diff := gc.Diff
+	if gc.Submodules != "" {
+		diff = gitctx.DropSubmoduleDiffs(diff)
+	}
+	diff = gitctx.DropDescribedDiffs(diff, gc.Binaries)
+	diffSections := []promptSection{{"Diff:", diff}}
+	switch {
+	case opts.StatOnly:
+		diffSections = []promptSection{{"Changed files (status, path, lines added and removed; the diff itself is not shared):", gitctx.StatSummary(gc.NameStatus, gc.Diff)}}
+	case opts.DiffSummary != "":
+		diffSections = []promptSection{{"Summary of the changes, file by file (the diff itself is too large to include):", opts.DiffSummary}}
+	case opts.DiffStat != "":
+		diffSections = []promptSection{
+			{"Changed files (status, path, lines added and removed):", opts.DiffStat},
+			{"Selected hunks (the rest of the diff is left out to fit the prompt budget):", diff},
+		}
+	case opts.BucketDiff:
+		diffSections = bucketSections(diff, opts.Buckets)
+	}
+	sections := slices.Concat([]promptSection{
+		{"Generate a commit message for the following git status:", gc.Status},
+		{"Current branch:", gc.Branch},
+		{"Ticket for this branch:", opts.Ticket},
+		{"Recent commits:", log},
+		{"Renamed or copied files (similarity %, old -> new):", gitctx.RenameSummary(gc.NameStatus)},
+		{"Submodule changes (pointer updates, not code in this repository):", gc.Submodules},
+		{"Binary files (their content isn't shown; describe them by kind, path, and size):", gc.Binaries},
+	}, diffSections, []promptSection{
+		{"Author notes (context from the author that the diff may not show; take it into account):", strings.Join(notes, "\n")},
+		{"Existing message body (kept as is, do not repeat it):", opts.KeepBody},
+		{"Current subject of this commit (being reworded; keep what is accurate, fix what is not):", opts.OldSubject},
+	})
+
+	var b strings.Builder
+	b.WriteString(examplesPrompt(opts.Examples))
+	for i, s := range sections {
+		body := normalizeSection(s.Body)
+		// The status heading introduces the prompt, so it is kept even when
+		// there is nothing to report; the other sections are optional.
+		if body == "" && i > 0 {
+			continue
+		}
+		if b.Len() > 0 && i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(s.Title + "\n" + body + "\n")
+	}
+	return b.String()
+}
+
+// normalizeSection unifies line endings and drops leading and trailing blank
+// lines. Whitespace inside the text is left alone because it can be part of a
+// diff.
+func normalizeSection(s string) string {
+	s = gitctx.NormalizeNewlines(s)
+	lines := strings.Split(s, "\n")
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// examplesPrompt renders examples in tagged blocks so they can't be mistaken
+// for the diff being described.
+func examplesPrompt(examples []Example) string {
+	if len(examples) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Examples of commit messages in this project's style. They are for reference only; do not describe them.\n")
+	for _, e := range examples {
+		b.WriteString("<example>\n")
+		if d := strings.TrimSpace(e.Diff); d != "" {
+			b.WriteString("Diff:\n" + d + "\n")
+		}
+		b.WriteString("Message:\n" + strings.TrimSpace(e.Message) + "\n</example>\n")
+	}
+	b.WriteString("End of examples.\n\n")
+	return b.String()
+}
+
+// OmittedFilesTitle introduces the files a diff trimmed for size lists by
+// name only, see --max-files.
+const OmittedFilesTitle = "Other changed files (diff omitted):"
@@ -0,0 +1,96 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+func TestUserPromptNormalized(t *testing.T) {
+	opts := Options{Ticket: "ABC-12", Notes: []string{"keeps the old flag working"}}
+	want := UserPrompt(opts, gitctx.CommitContext{
+		Status:     "On branch main\nChanges to be committed:\n\tmodified:   a.go",
+		Branch:     "main",
+		Log:        "1a2b3c4 feat: add a",
+		Diff:       "diff --git a/a.go b/a.go\n@@ -1 +1 @@\n-old\n+new",
+		NameStatus: "M\ta.go",
+	})
+
+	tests := []struct {
+		name string
+		gc   gitctx.CommitContext
+	}{
+		{"blank lines around sections", gitctx.CommitContext{
+			Status:     "\n\nOn branch main\nChanges to be committed:\n\tmodified:   a.go\n\n",
+			Branch:     "main\n",
+			Log:        "\n1a2b3c4 feat: add a\n",
+			Diff:       "diff --git a/a.go b/a.go\n@@ -1 +1 @@\n-old\n+new\n\n\n",
+			NameStatus: "M\ta.go\n",
+		}},
+		{"CRLF line endings", gitctx.CommitContext{
+			Status:     "On branch main\r\nChanges to be committed:\r\n\tmodified:   a.go\r\n",
+			Branch:     "main\r\n",
+			Log:        "1a2b3c4 feat: add a\r\n",
+			Diff:       "diff --git a/a.go b/a.go\r\n@@ -1 +1 @@\r\n-old\r\n+new\r\n",
+			NameStatus: "M\ta.go\r\n",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UserPrompt(opts, tt.gc); got != want {
+				t.Errorf("UserPrompt differs from the canonical prompt:\ngot:\n%q\nwant:\n%q", got, want)
+			}
+		})
+	}
+}
+
+func TestUserPromptSectionOrder(t *testing.T) {
+	gc := gitctx.CommitContext{
+		Status:     "On branch main",
+		Branch:     "main",
+		Log:        "1a2b3c4 feat: add a",
+		Diff:       "diff --git a/b.go b/c.go\n@@ -1 +1 @@\n-old\n+new",
+		NameStatus: "R090\tb.go\tc.go",
+	}
+	opts := Options{Ticket: "ABC-12", Notes: []string{"a note"}, KeepBody: "Kept body.", OldSubject: "fix: old subject"}
+	prompt := UserPrompt(opts, gc)
+
+	titles := []string{
+		"Generate a commit message for the following git status:",
+		"Current branch:",
+		"Ticket for this branch:",
+		"Recent commits:",
+		"Renamed or copied files",
+		"Diff:",
+		"Author notes",
+		"Existing message body",
+		"Current subject of this commit",
+	}
+	last := -1
+	for _, title := range titles {
+		i := strings.Index(prompt, "\n"+title)
+		if title == titles[0] {
+			i = strings.Index(prompt, title)
+		}
+		if i < 0 {
+			t.Fatalf("prompt lacks %q:\n%s", title, prompt)
+		}
+		if i < last {
+			t.Errorf("%q comes before the section preceding it:\n%s", title, prompt)
+		}
+		last = i
+	}
+
+	// Empty sections are left out, except the status heading that opens
+	// the prompt.
+	bare := UserPrompt(Options{}, gitctx.CommitContext{Diff: gc.Diff})
+	if !strings.HasPrefix(bare, titles[0]+"\n\n") {
+		t.Errorf("prompt without a status doesn't open with its heading:\n%s", bare)
+	}
+	for _, title := range titles[1:] {
+		if title != "Diff:" && strings.Contains(bare, title) {
+			t.Errorf("prompt has the empty section %q:\n%s", title, bare)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package generator
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/firebase/genkit/go/core/api"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/firebase/genkit/go/plugins/compat_oai/anthropic"
+	"github.com/firebase/genkit/go/plugins/compat_oai/openai"
+	"github.com/firebase/genkit/go/plugins/googlegenai"
+	"github.com/firebase/genkit/go/plugins/ollama"
+)
+
+// ProviderAuto picks the first provider with an API key in the environment,
+// then a local Ollama server.
+const ProviderAuto = "auto"
+
+// ProviderFactory returns a fresh genkit plugin for a provider, together with
+// the model used when --model isn't given.
+type ProviderFactory func() (plugin api.Plugin, defaultModel string)
+
+type registeredProvider struct {
+	name    string
+	factory ProviderFactory
+}
+
+// providerRegistry holds the providers in registration order, which is also
+// the order --provider auto tries them in.
+var providerRegistry []registeredProvider
+
+// RegisterProvider makes a provider available to --provider and --model
+// <name>/<model>. Custom builds can add a file that calls it from an init
+// function (and lists the provider's key variables in ProviderKeyEnv so
+// --provider auto can pick it). Registering a name again replaces its
+// factory and keeps its place in the order.
+func RegisterProvider(name string, factory ProviderFactory) {
+	for i, p := range providerRegistry {
+		if p.name == name {
+			providerRegistry[i].factory = factory
+			return
+		}
+	}
+	providerRegistry = append(providerRegistry, registeredProvider{name, factory})
+}
+
+func LookupProvider(name string) (ProviderFactory, bool) {
+	for _, p := range providerRegistry {
+		if p.name == name {
+			return p.factory, true
+		}
+	}
+	return nil, false
+}
+
+func ProviderNames() []string {
+	var names []string
+	for _, p := range providerRegistry {
+		names = append(names, p.name)
+	}
+	return names
+}
+
+// modelDefiner is implemented by plugins that can't list their models, so
+// the chosen one has to be defined after genkit.Init.
+type modelDefiner interface {
+	defineModel(g *genkit.Genkit, model string)
+}
+
+// ollamaPlugin defines the requested model on the Ollama plugin, which only
+// knows about models it has been told about.
+type ollamaPlugin struct{ *ollama.Ollama }
+
+func (o ollamaPlugin) defineModel(g *genkit.Genkit, model string) {
+	o.DefineModel(g, ollama.ModelDefinition{Name: strings.TrimPrefix(model, "ollama/"), Type: "chat"}, nil)
+}
+
+// Built-in providers. Ollama registers last because it needs no key and is
+// detected by reaching the server.
+func init() {
+	RegisterProvider("googleai", func() (api.Plugin, string) { return &googlegenai.GoogleAI{}, DefaultModel })
+	RegisterProvider("openai", func() (api.Plugin, string) { return &openai.OpenAI{}, "openai/gpt-4.1-mini" })
+	RegisterProvider("anthropic", func() (api.Plugin, string) {
+		return &anthropic.Anthropic{}, "anthropic/claude-haiku-4-5-20251001"
+	})
+	RegisterProvider("ollama", func() (api.Plugin, string) {
+		return ollamaPlugin{&ollama.Ollama{ServerAddress: OllamaAddress(), Timeout: 120}}, "ollama/llama3.2"
+	})
+}
+
+// providerAvailable reports whether --provider auto may pick a provider: it
+// has an API key in the environment, or it is Ollama and the server is up.
+func providerAvailable(name string) bool {
+	if _, key := APIKeyFor(name); key != "" {
+		return true
+	}
+	return name == "ollama" && OllamaReachable()
+}
+
+// OllamaAddress is the Ollama server to use, from OLLAMA_HOST if set.
+func OllamaAddress() string {
+	addr := os.Getenv("OLLAMA_HOST")
+	if addr == "" {
+		return "http://localhost:11434"
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return strings.TrimRight(addr, "/")
+}
+
+func OllamaReachable() bool {
+	client := &http.Client{Timeout: 500 * time.Millisecond}
+	resp, err := client.Get(OllamaAddress() + "/api/tags")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// ResolveModel turns the --provider and --model flags into a qualified model
+// name. modelSet reports whether --model was given explicitly; otherwise the
+// provider's default model is used. auto reports whether the provider was
+// picked by --provider auto.
+func ResolveModel(provider, model string, modelSet bool) (qualified string, auto bool, err error) {
+	if provider == ProviderAuto {
+		if modelSet && strings.Contains(model, "/") {
+			return model, false, nil
+		}
+		provider = ""
+		for _, p := range ProviderNames() {
+			if providerAvailable(p) {
+				provider = p
+				break
+			}
+		}
+		if provider == "" {
+			return "", true, fmt.Errorf("no provider found: set one of GEMINI_API_KEY, OPENAI_API_KEY, or ANTHROPIC_API_KEY, or start Ollama")
+		}
+		auto = true
+	}
+	factory, ok := LookupProvider(provider)
+	if !ok {
+		return "", false, fmt.Errorf("unknown provider %q (known: auto, %s)", provider, strings.Join(ProviderNames(), ", "))
+	}
+	if !modelSet {
+		_, def := factory()
+		return def, auto, nil
+	}
+	if strings.Contains(model, "/") {
+		if p := ProviderOf(model); p != provider {
+			return "", auto, fmt.Errorf("model %s does not belong to provider %s", model, provider)
+		}
+		return model, auto, nil
+	}
+	return provider + "/" + model, auto, nil
+}
+
+// ProviderKeyEnv lists the environment variables each provider reads its API
+// key from, in order of precedence.
+var ProviderKeyEnv = map[string][]string{
+	"googleai":  {"GEMINI_API_KEY", "GOOGLE_API_KEY"},
+	"openai":    {"OPENAI_API_KEY"},
+	"anthropic": {"ANTHROPIC_API_KEY"},
+}
+
+// ProviderBaseURLEnv is the environment variable each provider's client
+// reads its API base URL from, for gateways such as LiteLLM.
+var ProviderBaseURLEnv = map[string]string{
+	"googleai":  "GOOGLE_GEMINI_BASE_URL",
+	"openai":    "OPENAI_BASE_URL",
+	"anthropic": "ANTHROPIC_BASE_URL",
+	"ollama":    "OLLAMA_HOST",
+}
+
+func APIKeyFor(provider string) (name, value string) {
+	for _, env := range ProviderKeyEnv[provider] {
+		if v := os.Getenv(env); v != "" {
+			return env, v
+		}
+	}
+	return "", ""
+}
+
+func ProviderOf(model string) string {
+	provider, _, _ := strings.Cut(model, "/")
+	return provider
+}
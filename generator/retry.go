@@ -0,0 +1,54 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Backoff bounds for retried model calls. The delay before retry n is drawn
+// uniformly from [0, min(retryMaxDelay, retryBaseDelay*2^n)) ("full jitter"),
+// so concurrent runs hitting the same rate limit don't retry in lockstep.
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 10 * time.Second
+)
+
+func backoffDelay(retry int) time.Duration {
+	ceiling := retryMaxDelay
+	if retry < 16 {
+		ceiling = min(retryMaxDelay, retryBaseDelay<<retry)
+	}
+	return rand.N(ceiling)
+}
+
+// permanentErrorMarkers identify provider errors that another attempt won't
+// fix, such as a bad API key or an unknown model.
+var permanentErrorMarkers = []string{"UNAUTHENTICATED", "PERMISSION_DENIED", "INVALID_ARGUMENT", "NOT_FOUND", "invalid_api_key", "authentication_error"}
+
+// retryable reports whether a failed model call is worth repeating.
+func retryable(err error) bool {
+	if errors.Is(err, ErrBlocked) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	for _, m := range permanentErrorMarkers {
+		if strings.Contains(err.Error(), m) {
+			return false
+		}
+	}
+	return true
+}
+
+// sleepCtx waits for d or until ctx is done.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
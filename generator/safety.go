@@ -0,0 +1,65 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"google.golang.org/genai"
+)
+
+type Safety string
+
+const (
+	SafetyOff     Safety = "off"     // never block content
+	SafetyDefault Safety = "default" // provider defaults
+	SafetyStrict  Safety = "strict"  // block low probability and above
+)
+
+var ErrBlocked = errors.New("the provider's safety filters blocked this diff; if the content is legitimate, retry with --safety off")
+
+var harmCategories = []genai.HarmCategory{
+	genai.HarmCategoryHateSpeech,
+	genai.HarmCategoryDangerousContent,
+	genai.HarmCategoryHarassment,
+	genai.HarmCategorySexuallyExplicit,
+}
+
+func ParseSafety(s string) (Safety, error) {
+	switch Safety(s) {
+	case SafetyOff, SafetyDefault, SafetyStrict:
+		return Safety(s), nil
+	}
+	return "", fmt.Errorf("invalid safety level %q (want off, default, or strict)", s)
+}
+
+// safetyConfig maps a safety level to the Gemini generation config. It
+// returns nil for the default level so the provider applies its own settings.
+func safetyConfig(level Safety) *genai.GenerateContentConfig {
+	var threshold genai.HarmBlockThreshold
+	switch level {
+	case SafetyOff:
+		threshold = genai.HarmBlockThresholdOff
+	case SafetyStrict:
+		threshold = genai.HarmBlockThresholdBlockLowAndAbove
+	default:
+		return nil
+	}
+	cfg := &genai.GenerateContentConfig{}
+	for _, c := range harmCategories {
+		cfg.SafetySettings = append(cfg.SafetySettings, &genai.SafetySetting{Category: c, Threshold: threshold})
+	}
+	return cfg
+}
+
+// IsBlocked reports whether a generation failed because of content filtering.
+// A blocked Gemini candidate carries no content, which genkit reports as an
+// error rather than a finish reason.
+func IsBlocked(res *ai.ModelResponse, err error) bool {
+	if err != nil {
+		msg := strings.ToLower(err.Error())
+		return strings.Contains(msg, "safety") || strings.Contains(msg, "blocked") || strings.Contains(msg, "no valid candidates")
+	}
+	return res != nil && res.FinishReason == ai.FinishReasonBlocked
+}
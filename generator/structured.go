@@ -0,0 +1,138 @@
+package generator
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// StructuredMessage is the answer asked for with Options.Structured: the
+// parts of a commit message as JSON, put together by Render, so a model that
+// wraps its answer in fences or commentary can't leak them into the message.
+type StructuredMessage struct {
+	Type     string   `json:"type"`
+	Scope    string   `json:"scope,omitempty"`
+	Subject  string   `json:"subject"`
+	Body     string   `json:"body,omitempty"`
+	Breaking bool     `json:"breaking,omitempty"`
+	Footers  []string `json:"footers,omitempty"`
+}
+
+// ErrInvalidStructure is returned when the model's answer isn't the JSON
+// asked for, even after a repair attempt; it is retried like an empty one.
+var ErrInvalidStructure = errors.New("model didn't answer with a commit message in the requested JSON structure")
+
+const structuredPrompt = "\nRespond with JSON only, split into the parts of the message: \"type\" and \"scope\" where the format uses them (empty otherwise), \"subject\" for the rest of the subject line as the format writes it, \"body\" for the description (empty for none), \"breaking\" when the change breaks compatibility, and \"footers\" for trailer lines such as \"BREAKING CHANGE: ...\"."
+
+// repairPrompt asks again for the JSON after an answer that wasn't.
+const repairPrompt = "Your previous answer was not the requested JSON (%v). It was:\n\n%s\n\nAnswer again with only the JSON object, without code fences or any other text."
+
+// Render puts the parts back together as the message: "type(scope)!:
+// subject" when there is a type, then the body and the footers, each after
+// a blank line.
+func (m StructuredMessage) Render() string {
+	subject := strings.TrimSpace(m.Subject)
+	if kind := strings.TrimSpace(m.Type); kind != "" {
+		prefix := kind
+		if scope := strings.Trim(strings.TrimSpace(m.Scope), "()"); scope != "" {
+			prefix += "(" + scope + ")"
+		}
+		if m.Breaking {
+			prefix += "!"
+		}
+		// A subject that already starts with the prefix isn't given another.
+		if !strings.HasPrefix(subject, prefix+":") {
+			subject = prefix + ": " + subject
+		}
+	}
+	parts := []string{subject}
+	if body := strings.TrimSpace(m.Body); body != "" {
+		parts = append(parts, body)
+	}
+	var footers []string
+	for _, f := range m.Footers {
+		if f = strings.TrimSpace(f); f != "" {
+			footers = append(footers, f)
+		}
+	}
+	if len(footers) > 0 {
+		parts = append(parts, strings.Join(footers, "\n"))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// parseStructured reads a StructuredMessage from an answer, tolerating what
+// models add around JSON: code fences and text before or after the object.
+func parseStructured(text string) (StructuredMessage, error) {
+	var m StructuredMessage
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return m, errors.New("no JSON object in the answer")
+	}
+	if err := json.Unmarshal([]byte(text[start:end+1]), &m); err != nil {
+		return m, err
+	}
+	if strings.TrimSpace(m.Subject) == "" {
+		return m, errors.New("the subject is empty")
+	}
+	return m, nil
+}
+
+// generateStructured asks for a StructuredMessage with genkit's structured
+// output, which providers that support it enforce. An answer that doesn't
+// match gets one repair call with the answer and the problem quoted back.
+func (gen *Generator) generateStructured(ctx context.Context, opts Options, system, prompt string) (Suggestion, error) {
+	generate := func(prompt string, extra ...ai.GenerateOption) (*ai.ModelResponse, error) {
+		genOpts := []ai.GenerateOption{ai.WithSystem("%s", system), ai.WithPrompt("%s", prompt)}
+		if cfg := GenerationConfig(opts); cfg != nil {
+			genOpts = append(genOpts, ai.WithConfig(cfg))
+		}
+		return genkit.Generate(ctx, gen.g, append(genOpts, extra...)...)
+	}
+	res, err := generate(prompt, ai.WithOutputType(StructuredMessage{}))
+	if IsBlocked(res, err) {
+		return Suggestion{}, ErrBlocked
+	}
+	var answer string
+	var used Usage
+	var problem error
+	switch {
+	case err != nil && !strings.Contains(err.Error(), "matching expected schema"):
+		return Suggestion{}, modelError(ctx, opts, err)
+	case err != nil:
+		// genkit drops the answer along with the error, so the repair call
+		// below can only cite the problem.
+		problem = err
+	default:
+		answer, used = res.Text(), usageOf(res)
+		gen.record(system, prompt, answer)
+		var m StructuredMessage
+		if m, problem = parseStructured(answer); problem == nil {
+			return Suggestion{Message: m.Render(), Usage: used}, nil
+		}
+	}
+
+	gen.debugf("Repairing a structured answer: %v", problem)
+	repair := prompt + "\n\n" + fmt.Sprintf(repairPrompt, problem, cmp.Or(strings.TrimSpace(answer), "(not available)"))
+	res, err = generate(repair)
+	if IsBlocked(res, err) {
+		return Suggestion{}, ErrBlocked
+	}
+	if err != nil {
+		return Suggestion{}, modelError(ctx, opts, err)
+	}
+	used.InputTokens += usageOf(res).InputTokens
+	used.OutputTokens += usageOf(res).OutputTokens
+	gen.record(system, repair, res.Text())
+	m, err := parseStructured(res.Text())
+	if err != nil {
+		return Suggestion{Usage: used}, fmt.Errorf("%w: %v", ErrInvalidStructure, err)
+	}
+	return Suggestion{Message: m.Render(), Usage: used}, nil
+}
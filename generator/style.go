@@ -0,0 +1,111 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Style is the overall format of a message.
+type Style string
+
+const (
+	StyleConventional Style = "conventional" // fix: message
+	StyleSimple       Style = "simple"       // message
+	StyleDetailed     Style = "detailed"     // git commit -m "title" -m "description"
+	StyleGitmoji      Style = "gitmoji"      // ✨ feat: message
+	StyleAngular      Style = "angular"      // fix(scope): message, with Angular's types
+)
+
+// ParseStyle accepts the style names plus the spellings people use for
+// them: conventional-commits for conventional and plain for simple.
+func ParseStyle(s string) (Style, error) {
+	switch strings.ToLower(s) {
+	case "conventional-commits":
+		return StyleConventional, nil
+	case "plain":
+		return StyleSimple, nil
+	}
+	switch st := Style(strings.ToLower(s)); st {
+	case StyleConventional, StyleSimple, StyleDetailed, StyleGitmoji, StyleAngular:
+		return st, nil
+	}
+	return "", fmt.Errorf("invalid style %q (want conventional, simple, detailed, gitmoji, or angular)", s)
+}
+
+// AngularTypes are the types the Angular commit convention allows.
+var AngularTypes = []string{"build", "ci", "docs", "feat", "fix", "perf", "refactor", "test"}
+
+// CommitTypes are the Conventional Commits types accepted by lint when
+// the config file does not list its own.
+var CommitTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+
+// Mood is the grammatical mood of the subject.
+type Mood string
+
+const (
+	MoodImperative Mood = "imperative" // add validation
+	MoodPast       Mood = "past"       // added validation
+	MoodPresent    Mood = "present"    // adds validation
+)
+
+func ParseMood(s string) (Mood, error) {
+	switch Mood(s) {
+	case MoodImperative, MoodPast, MoodPresent:
+		return Mood(s), nil
+	}
+	return "", fmt.Errorf("invalid mood %q (want imperative, past, or present)", s)
+}
+
+// SubjectCase is how the first word of the subject, after any type prefix,
+// is capitalized.
+type SubjectCase string
+
+const (
+	CaseLower    SubjectCase = "lower"    // feat: add validation
+	CaseSentence SubjectCase = "sentence" // feat: Add validation
+	CasePreserve SubjectCase = "preserve" // leave as generated
+)
+
+func ParseSubjectCase(s string) (SubjectCase, error) {
+	switch SubjectCase(s) {
+	case CaseLower, CaseSentence, CasePreserve:
+		return SubjectCase(s), nil
+	}
+	return "", fmt.Errorf("invalid subject case %q (want lower, sentence, or preserve)", s)
+}
+
+// ReleaseTool is an automated release tool whose version bumps are driven by
+// commit messages, see --release-tool.
+type ReleaseTool string
+
+const (
+	ReleasePlease   ReleaseTool = "release-please"
+	SemanticRelease ReleaseTool = "semantic-release"
+)
+
+func ParseReleaseTool(s string) (ReleaseTool, error) {
+	switch ReleaseTool(s) {
+	case ReleasePlease, SemanticRelease:
+		return ReleaseTool(s), nil
+	}
+	return "", fmt.Errorf("invalid release tool %q (want release-please or semantic-release)", s)
+}
+
+// Example is a curated diff → message pair used as a few-shot example.
+type Example struct {
+	Diff    string `json:"diff"`
+	Message string `json:"message"`
+}
+
+// SplitMessage separates the subject line from the rest of the message. rest
+// keeps its leading newline so JoinMessage(SplitMessage(m)) == m.
+func SplitMessage(msg string) (subject, rest string) {
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		return msg[:i], msg[i:]
+	}
+	return msg, ""
+}
+
+func JoinMessage(subject, rest string) string {
+	return subject + rest
+}
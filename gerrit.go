@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+var changeIDRe = regexp.MustCompile(`(?m)^Change-Id: (I[0-9a-f]{40})\s*$`)
+
+// trailerLineRe matches a "Key: value" trailer line such as "Refs: #12".
+var trailerLineRe = regexp.MustCompile(`^[A-Za-z0-9-]+: .+$`)
+
+// existingChangeID returns the Change-Id trailer found in msg, if any.
+func existingChangeID(msg string) string {
+	if m := changeIDRe.FindStringSubmatch(msg); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// newChangeID computes a Change-Id the way Gerrit's commit-msg hook does: an
+// "I" followed by the SHA-1 of the tree, parent, time, and message. Missing
+// pieces (no HEAD yet, nothing to write) only make it less reproducible.
+func newChangeID(msg string) string {
+	tree, _ := runGit("write-tree")
+	parent, _ := runGit("rev-parse", "--verify", "-q", "HEAD")
+	h := sha1.New()
+	fmt.Fprintf(h, "tree %s\nparent %s\ntime %d\n\n%s", strings.TrimSpace(tree), strings.TrimSpace(parent), time.Now().UnixNano(), msg)
+	return fmt.Sprintf("I%x", h.Sum(nil))
+}
+
+// withChangeID appends a Change-Id trailer to msg. An ID already in msg or in
+// previous (the message being amended) is kept so the change stays linked to
+// the same Gerrit review.
+func withChangeID(msg, previous string) string {
+	if existingChangeID(msg) != "" {
+		return msg
+	}
+	id := existingChangeID(previous)
+	if id == "" {
+		id = newChangeID(msg)
+	}
+	return appendTrailer(msg, "Change-Id", id)
+}
+
+// appendTrailer adds "key: value" to the trailer block at the end of msg, or
+// starts one after a blank line.
+func appendTrailer(msg, key, value string) string {
+	return appendTrailerLine(msg, key+": "+value)
+}
+
+// appendTrailerLine is appendTrailer for a preformatted line. Issue closing
+// lines ("Fixes #12") count as part of the trailer block.
+func appendTrailerLine(msg, line string) string {
+	msg = strings.TrimRight(msg, "\n ")
+	subject, rest := generator.SplitMessage(msg)
+	if rest == "" {
+		return subject + "\n\n" + line
+	}
+	paragraphs := strings.Split(rest, "\n\n")
+	last := paragraphs[len(paragraphs)-1]
+	isTrailers := last != ""
+	for _, l := range strings.Split(strings.TrimPrefix(last, "\n"), "\n") {
+		if !trailerLineRe.MatchString(l) && !closeLineRe.MatchString(l) {
+			isTrailers = false
+		}
+	}
+	if isTrailers && len(paragraphs) > 1 {
+		return msg + "\n" + line
+	}
+	return msg + "\n\n" + line
+}
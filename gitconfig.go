@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// gitConfigSection is the git config section read by withGitConfig, e.g.
+// `git config --local commit-ai.model gemini-2.5-pro`.
+const gitConfigSection = "commit-ai"
+
+// withGitConfig overlays the commit-ai.* values from git config (repository,
+// then global and system, as git resolves them) on the config file. Keys use
+// git's dashed spelling of the config file keys: style, action, clip-format,
+// mood, provider, model, prompt-url, prompt-append, style-guide, history,
+// pre-commit-command, tracker, and tracker-url, and exemplar, which can be
+// given many times.
+func withGitConfig(c Config) Config {
+	out, err := runGit("config", "--get-regexp", `^`+gitConfigSection+`\.`)
+	if err != nil {
+		return c // exit status 1 just means no keys are set
+	}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		key = strings.TrimPrefix(key, gitConfigSection+".")
+		switch key {
+		case "style":
+			c.Style = generator.Style(value)
+		case "action":
+			c.Action = Action(value)
+		case "clip-format":
+			c.ClipFormat = ClipFormat(value)
+		case "mood":
+			c.Mood = generator.Mood(value)
+		case "provider":
+			c.Provider = value
+		case "model":
+			c.Model = value
+		case "prompt-url":
+			c.PromptURL = value
+		case "prompt-append":
+			c.PromptAppend = value
+		case "style-guide":
+			c.StyleGuide = value
+		case "history":
+			c.History = gitBool(value)
+		case "pre-commit-command":
+			c.PreCommit = value
+		case "tracker":
+			c.Tracker = value
+		case "tracker-url":
+			c.TrackerURL = value
+		case "exemplar":
+			c.Exemplars = append(c.Exemplars, value)
+		default:
+			debugf("Ignoring unknown git config key %s.%s", gitConfigSection, key)
+		}
+	}
+	return c
+}
+
+// gitBool interprets a value the way git does for boolean options; a key
+// without a value counts as true.
+func gitBool(v string) bool {
+	switch strings.ToLower(v) {
+	case "", "true", "yes", "on", "1":
+		return true
+	}
+	return false
+}
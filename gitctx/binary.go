@@ -0,0 +1,172 @@
+package gitctx
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// binaryChange is a binary file change found in a diff, which has no
+// content to show. Sizes are in bytes, and -1 when unknown.
+type binaryChange struct {
+	Path, OldPath    string
+	Added, Deleted   bool
+	OldSize, NewSize int64
+}
+
+// isBinaryDiff reports whether a file's diff is git's "Binary files ...
+// differ" line, or a --binary patch, rather than hunks.
+func isBinaryDiff(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "Binary files ") && strings.HasSuffix(line, " differ") || line == "GIT binary patch" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBinaryChanges finds the binary files in a diff, in its order, with
+// their sizes unknown.
+func parseBinaryChanges(diff string) []binaryChange {
+	var changes []binaryChange
+	for _, f := range SplitFiles(diff) {
+		if !isBinaryDiff(f.Text) {
+			continue
+		}
+		c := binaryChange{Path: f.Path, OldSize: -1, NewSize: -1}
+		for _, line := range strings.Split(f.Text, "\n") {
+			switch {
+			case strings.HasPrefix(line, "new file mode "):
+				c.Added = true
+			case strings.HasPrefix(line, "deleted file mode "):
+				c.Deleted = true
+			case strings.HasPrefix(line, "rename from "):
+				c.OldPath = strings.TrimPrefix(line, "rename from ")
+			case strings.HasPrefix(line, "copy from "):
+				c.OldPath = strings.TrimPrefix(line, "copy from ")
+			}
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// addStatSizes fills in the sizes of changes from --stat output, whose
+// "Bin 10 -> 20 bytes" lines come in the same order as the diff. When the
+// lines and the changes don't pair up, the sizes stay unknown.
+func addStatSizes(changes []binaryChange, stat string) {
+	type sizes struct{ old, new int64 }
+	var found []sizes
+	for _, line := range strings.Split(stat, "\n") {
+		_, bin, ok := strings.Cut(line, "| Bin ")
+		if !ok {
+			continue
+		}
+		// A rename without changes is "| Bin" alone and has no diff.
+		var s sizes
+		if n, _ := fmt.Sscanf(bin, "%d -> %d bytes", &s.old, &s.new); n == 2 {
+			found = append(found, s)
+		}
+	}
+	if len(found) != len(changes) {
+		return
+	}
+	for i, s := range found {
+		changes[i].OldSize, changes[i].NewSize = s.old, s.new
+	}
+}
+
+// binaryKinds names what a binary file is by its extension.
+var binaryKinds = map[string]string{
+	".png": "image", ".jpg": "image", ".jpeg": "image", ".gif": "image", ".webp": "image", ".bmp": "image", ".ico": "image", ".tif": "image", ".tiff": "image", ".avif": "image", ".heic": "image", ".psd": "image",
+	".ttf": "font", ".otf": "font", ".woff": "font", ".woff2": "font", ".eot": "font",
+	".zip": "archive", ".gz": "archive", ".tgz": "archive", ".tar": "archive", ".xz": "archive", ".bz2": "archive", ".7z": "archive", ".rar": "archive", ".jar": "archive",
+	".mp3": "audio", ".wav": "audio", ".ogg": "audio", ".flac": "audio", ".m4a": "audio",
+	".mp4": "video", ".mov": "video", ".webm": "video", ".avi": "video", ".mkv": "video",
+	".pdf": "document", ".doc": "document", ".docx": "document", ".xls": "spreadsheet", ".xlsx": "spreadsheet", ".ppt": "presentation", ".pptx": "presentation",
+	".exe": "executable", ".dll": "library", ".so": "library", ".dylib": "library", ".a": "library", ".wasm": "WebAssembly module",
+	".db": "database", ".sqlite": "database", ".sqlite3": "database",
+}
+
+// byteSize formats n bytes the way ls -h does, in B, KB, or MB.
+func byteSize(n int64) string {
+	switch {
+	case n < 1024:
+		return strconv.FormatInt(n, 10) + " B"
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	}
+}
+
+// describeBinaries turns binary changes into one readable line each, such
+// as "update binary image assets/logo.png: 10.2 KB -> 22.5 KB (+12.3 KB)".
+func describeBinaries(changes []binaryChange) string {
+	var lines []string
+	for _, c := range changes {
+		kind := "binary file"
+		if k := binaryKinds[strings.ToLower(path.Ext(c.Path))]; k != "" {
+			kind = "binary " + k
+		}
+		known := c.OldSize >= 0 && c.NewSize >= 0
+		var line string
+		switch {
+		case c.Added:
+			line = fmt.Sprintf("add %s %s", kind, c.Path)
+			if known {
+				line += " (" + byteSize(c.NewSize) + ")"
+			}
+		case c.Deleted:
+			line = fmt.Sprintf("remove %s %s", kind, c.Path)
+			if known {
+				line += " (was " + byteSize(c.OldSize) + ")"
+			}
+		default:
+			line = fmt.Sprintf("update %s %s", kind, c.Path)
+			if c.OldPath != "" {
+				line = fmt.Sprintf("update %s %s, moved from %s", kind, c.Path, c.OldPath)
+			}
+			if known {
+				sign, delta := "+", c.NewSize-c.OldSize
+				if delta < 0 {
+					sign, delta = "-", -delta
+				}
+				line += fmt.Sprintf(": %s -> %s (%s%s)", byteSize(c.OldSize), byteSize(c.NewSize), sign, byteSize(delta))
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isPureRename reports whether a file's diff is only the header of a rename
+// or copy with unchanged content and mode, which RenameSummary lists.
+func isPureRename(text string) bool {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for _, line := range lines[min(1, len(lines)):] {
+		switch {
+		case line == "similarity index 100%",
+			strings.HasPrefix(line, "rename from "), strings.HasPrefix(line, "rename to "),
+			strings.HasPrefix(line, "copy from "), strings.HasPrefix(line, "copy to "):
+		default:
+			return false
+		}
+	}
+	return len(lines) > 1
+}
+
+// DropDescribedDiffs removes the per-file diffs that say nothing beyond what
+// the prompt lists separately: binary files, when binaries describes them,
+// and renames and copies of unchanged files.
+func DropDescribedDiffs(diff, binaries string) string {
+	var b strings.Builder
+	for _, f := range SplitFiles(diff) {
+		if isPureRename(f.Text) || binaries != "" && isBinaryDiff(f.Text) {
+			continue
+		}
+		b.WriteString(f.Text)
+	}
+	return b.String()
+}
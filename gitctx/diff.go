@@ -0,0 +1,144 @@
+package gitctx
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// FileDiff is the part of a unified diff belonging to one file.
+type FileDiff struct {
+	Path    string
+	Text    string
+	Changed int // added plus deleted lines
+	Added   int
+	Deleted int
+}
+
+// SplitFiles splits a unified diff at its "diff --git" headers.
+func SplitFiles(diff string) []FileDiff {
+	var files []FileDiff
+	for _, part := range strings.SplitAfter(diff, "\n") {
+		if strings.HasPrefix(part, "diff --git ") || len(files) == 0 {
+			files = append(files, FileDiff{Path: diffHeaderPath(part)})
+		}
+		f := &files[len(files)-1]
+		f.Text += part
+		switch {
+		case strings.HasPrefix(part, "+") && !strings.HasPrefix(part, "+++ "):
+			f.Added++
+			f.Changed++
+		case strings.HasPrefix(part, "-") && !strings.HasPrefix(part, "--- "):
+			f.Deleted++
+			f.Changed++
+		}
+	}
+	return files
+}
+
+// diffHeaderPath extracts the new-side path from a "diff --git a/x b/x" line.
+func diffHeaderPath(header string) string {
+	header = strings.TrimSpace(strings.TrimPrefix(header, "diff --git "))
+	if i := strings.LastIndex(header, " b/"); i >= 0 {
+		return header[i+3:]
+	}
+	return header
+}
+
+// SplitPathspec separates git arguments at "--" into options and the
+// pathspec, which keeps its "--".
+func SplitPathspec(args []string) (opts, paths []string) {
+	if i := slices.Index(args, "--"); i >= 0 {
+		return args[:i:i], args[i:]
+	}
+	return args, nil
+}
+
+// FileChange is one entry of `git diff --name-status` output.
+type FileChange struct {
+	Status  string // A, M, D, R100, C075, ...
+	Path    string
+	OldPath string // source path for renames and copies
+}
+
+func ParseNameStatus(nameStatus string) []FileChange {
+	var changes []FileChange
+	for _, line := range strings.Split(nameStatus, "\n") {
+		fields := strings.Split(line, "\t")
+		switch len(fields) {
+		case 2:
+			changes = append(changes, FileChange{Status: fields[0], Path: fields[1]})
+		case 3:
+			changes = append(changes, FileChange{Status: fields[0], Path: fields[2], OldPath: fields[1]})
+		}
+	}
+	return changes
+}
+
+// StatSummary describes the changes without their content: one line per file
+// with its status and line counts, then a git --shortstat style total.
+func StatSummary(nameStatus, diff string) string {
+	byPath := map[string]FileDiff{}
+	for _, f := range SplitFiles(diff) {
+		byPath[f.Path] = f
+	}
+	var b strings.Builder
+	var added, deleted int
+	changes := ParseNameStatus(nameStatus)
+	for _, c := range changes {
+		f := byPath[c.Path]
+		added += f.Added
+		deleted += f.Deleted
+		name := c.Path
+		if c.OldPath != "" {
+			name = c.OldPath + " -> " + c.Path
+		}
+		fmt.Fprintf(&b, "%s\t%s\t+%d -%d\n", c.Status, name, f.Added, f.Deleted)
+	}
+	b.WriteString(Shortstat(len(changes), added, deleted))
+	return b.String()
+}
+
+// Shortstat formats totals the way git diff --shortstat does.
+func Shortstat(files, added, deleted int) string {
+	plural := func(n int, one, many string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s", n, one)
+		}
+		return fmt.Sprintf("%d %s", n, many)
+	}
+	return plural(files, "file changed", "files changed") + ", " + plural(added, "insertion(+)", "insertions(+)") + ", " + plural(deleted, "deletion(-)", "deletions(-)")
+}
+
+// RenameSummary extracts rename and copy entries from --name-status output as
+// "R100 old -> new" lines.
+func RenameSummary(nameStatus string) string {
+	var lines []string
+	for _, line := range strings.Split(nameStatus, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) == 3 && (strings.HasPrefix(fields[0], "R") || strings.HasPrefix(fields[0], "C")) {
+			lines = append(lines, fields[0]+" "+fields[1]+" -> "+fields[2])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MatchPath reports whether p matches a scope map pattern. A trailing
+// "/**" matches everything below a directory; other patterns use path.Match,
+// and a plain directory prefix matches its contents.
+func MatchPath(pattern, p string) bool {
+	if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return p == dir || strings.HasPrefix(p, dir+"/")
+	}
+	if ok, _ := path.Match(pattern, p); ok {
+		return true
+	}
+	return strings.HasPrefix(p, strings.TrimSuffix(pattern, "/")+"/")
+}
+
+// NormalizeNewlines converts CRLF line endings, which git for Windows and
+// files checked out with autocrlf produce, to LF.
+func NormalizeNewlines(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
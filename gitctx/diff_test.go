@@ -0,0 +1,19 @@
+package gitctx
+
+import "testing"
+
+func TestNormalizeNewlines(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"a\r\nb\r\n", "a\nb\n"},
+		{"a\nb", "a\nb"},
+		{"mixed\r\nand\nplain\r\n", "mixed\nand\nplain\n"},
+		{"lone\rcarriage return", "lone\rcarriage return"},
+		{"-old\r\n+new\r\n\r\n", "-old\n+new\n\n"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeNewlines(tt.in); got != tt.want {
+			t.Errorf("NormalizeNewlines(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
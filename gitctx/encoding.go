@@ -0,0 +1,65 @@
+package gitctx
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// sanitizeDiff makes a diff valid UTF-8 so it can't corrupt the prompt or the
+// request. The diff of a file that isn't UTF-8 is decoded from the encoding
+// its gitattributes declare (encoding or working-tree-encoding); without a
+// usable declaration, invalid bytes become U+FFFD.
+func (r Repo) sanitizeDiff(diff string) string {
+	if utf8.ValidString(diff) {
+		return diff
+	}
+	top, err := r.Git("rev-parse", "--show-toplevel")
+	if err != nil {
+		top = "."
+	}
+	var b strings.Builder
+	for _, f := range SplitFiles(diff) {
+		if !utf8.ValidString(f.Text) {
+			f.Text = r.decodeFileDiff(top, f.Path, f.Text)
+		}
+		b.WriteString(f.Text)
+	}
+	return b.String()
+}
+
+func (r Repo) decodeFileDiff(top, path, text string) string {
+	if name := r.declaredEncoding(top, path); name != "" {
+		if enc, err := ianaindex.IANA.Encoding(name); err == nil && enc != nil {
+			if s, err := enc.NewDecoder().String(text); err == nil && utf8.ValidString(s) {
+				r.debugf("Decoded the diff of %s from %s", path, name)
+				return s
+			}
+		}
+		r.debugf("Could not decode the diff of %s from %s", path, name)
+	}
+	r.debugf("The diff of %s is not valid UTF-8; replacing invalid bytes", path)
+	return strings.ToValidUTF8(text, "\uFFFD")
+}
+
+// declaredEncoding returns the encoding gitattributes set for path, or "".
+func (r Repo) declaredEncoding(top, path string) string {
+	out, err := r.Git("-C", top, "check-attr", "encoding", "working-tree-encoding", "--", path)
+	if err != nil {
+		return ""
+	}
+	// Lines look like "path: encoding: ISO-8859-1".
+	for _, line := range strings.Split(out, "\n") {
+		i := strings.LastIndex(line, ": ")
+		if i < 0 {
+			continue
+		}
+		switch v := line[i+2:]; v {
+		case "unspecified", "set", "unset":
+		default:
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package gitctx
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCollectLatin1(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes string
+		want       string
+	}{
+		{"declared", "*.txt encoding=ISO-8859-1\n", "+café au lait"},
+		{"undeclared", "", "+caf\uFFFD au lait"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, r := testRepo(t)
+			if tt.attributes != "" {
+				commitFile(t, dir, r, ".gitattributes", tt.attributes, "add attributes")
+			}
+			commitFile(t, dir, r, "menu.txt", "tea\n", "add menu")
+			writeFile(t, dir, "menu.txt", "tea\ncaf\xe9 au lait\n")
+
+			gc, err := r.Collect([]string{"diff", "HEAD"}, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, diff := range map[string]string{"Diff": gc.Diff, "DiffNoWS": gc.DiffNoWS} {
+				if !utf8.ValidString(diff) {
+					t.Errorf("%s isn't valid UTF-8:\n%q", name, diff)
+				}
+				if !strings.Contains(diff, tt.want) {
+					t.Errorf("%s lacks %q:\n%s", name, tt.want, diff)
+				}
+			}
+		})
+	}
+}
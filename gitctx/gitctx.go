@@ -0,0 +1,194 @@
+// Package gitctx collects what a commit message is written from: the diff
+// being described, the status, the branch, and recent history, read from git.
+package gitctx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// CommitContext is the repository state a commit message is generated from.
+type CommitContext struct {
+	Status     string
+	Branch     string
+	Log        string
+	Diff       string
+	NameStatus string // --name-status output, used to report renames and copies
+	DiffNoWS   string // the same diff with whitespace changes ignored (-w)
+	Submodules string // readable description of submodule pointer changes
+	Binaries   string // readable description of binary file changes, with sizes
+	Initial    bool   // HEAD is unborn: the change is the repository's first commit
+}
+
+// Runner runs git with args and returns its output, trimmed and with LF line
+// endings.
+type Runner func(args ...string) (string, error)
+
+// Git returns a Runner that runs the git executable under ctx.
+func Git(ctx context.Context) Runner {
+	return GitIn(ctx, "")
+}
+
+// GitIn is Git run in dir, such as a Layout's Top; "" is the working
+// directory.
+func GitIn(ctx context.Context, dir string) Runner {
+	return func(args ...string) (string, error) {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, Path(), args...)
+		cmd.Dir = dir
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		return strings.TrimSpace(NormalizeNewlines(string(out))), CommandError(err, stderr.String())
+	}
+}
+
+// Repo reads a commit's context from the repository Git runs in.
+type Repo struct {
+	Git    Runner
+	Debugf func(format string, args ...any) // optional, receives diagnostics
+	// Untracked adds the untracked files to what Collect describes; only
+	// set it for diffs of the work tree, which git add . would commit.
+	Untracked Untracked
+}
+
+func (r Repo) debugf(format string, args ...any) {
+	if r.Debugf != nil {
+		r.Debugf(format, args...)
+	}
+}
+
+// Collect gathers the prompt context in parallel. diffArgs selects the
+// change being described, e.g. "diff --staged" or "show --format= <sha>".
+// Rename and copy detection is always enabled so moved files show up as a
+// single rename rather than a full delete and add, and submodules are always
+// diffed as "Subproject commit" lines so they can be described (see
+// describeSubmodules) regardless of the diff.submodule setting. Binary files
+// are described by kind and size instead, see describeBinaries.
+// concurrency bounds how many git processes run at once.
+func (r Repo) Collect(diffArgs []string, concurrency int) (CommitContext, error) {
+	var gc CommitContext
+	var wg sync.WaitGroup
+	var statusErr, branchErr, logErr, diffErr, nameStatusErr, noWSErr error
+
+	// Options go before any "--" pathspec from --files-from.
+	args, paths := SplitPathspec(diffArgs)
+	// Before the first commit there is no HEAD to diff against, name, or
+	// log, so the diff is against the empty tree instead.
+	if gc.Initial = r.unbornHead(); gc.Initial {
+		tree, err := r.Git("hash-object", "-t", "tree", "--stdin")
+		if err != nil {
+			return gc, fmt.Errorf("git hash-object failed: %w", err)
+		}
+		args = slices.Clone(args)
+		for i, a := range args {
+			if a == "HEAD" {
+				args[i] = tree
+			}
+		}
+	}
+	diff := func(extra ...string) []string {
+		return slices.Concat(args, []string{"-M", "-C", "--submodule=short"}, extra, paths)
+	}
+
+	// sem bounds how many git processes run at once.
+	sem := make(chan struct{}, max(concurrency, 1))
+	run := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			f()
+		}()
+	}
+
+	run(func() { gc.Status, statusErr = r.Git("status") })
+	if gc.Initial {
+		run(func() { gc.Branch, branchErr = r.Git("symbolic-ref", "--short", "HEAD") })
+	} else {
+		run(func() { gc.Branch, branchErr = r.Git("rev-parse", "--abbrev-ref", "HEAD") })
+		// --use-mailmap keeps any identities in the history canonical even
+		// when log.mailmap is turned off.
+		run(func() { gc.Log, logErr = r.Git("log", "--use-mailmap", "-n", "10", "--oneline") })
+	}
+	run(func() { gc.Diff, diffErr = r.Git(diff()...) })
+	run(func() { gc.NameStatus, nameStatusErr = r.Git(diff("--name-status")...) })
+	run(func() { gc.DiffNoWS, noWSErr = r.Git(diff("-w")...) })
+
+	wg.Wait()
+
+	switch {
+	case statusErr != nil:
+		return gc, fmt.Errorf("git status failed: %w", statusErr)
+	case branchErr != nil:
+		return gc, fmt.Errorf("git branch failed: %w", branchErr)
+	case logErr != nil:
+		return gc, fmt.Errorf("git log failed: %w", logErr)
+	case diffErr != nil:
+		return gc, fmt.Errorf("git diff failed: %w", diffErr)
+	case nameStatusErr != nil:
+		return gc, fmt.Errorf("git diff --name-status failed: %w", nameStatusErr)
+	case noWSErr != nil:
+		return gc, fmt.Errorf("git diff -w failed: %w", noWSErr)
+	}
+	binaries := parseBinaryChanges(gc.Diff)
+	if len(binaries) > 0 {
+		// Only --stat has the sizes of binary files.
+		if stat, err := r.Git(diff("--stat=1000,1000")...); err == nil {
+			addStatSizes(binaries, stat)
+		} else {
+			r.debugf("Reading the sizes of binary files: %v", err)
+		}
+	}
+	if r.Untracked != UntrackedNone {
+		u, err := r.collectUntracked(paths)
+		if err != nil {
+			return gc, err
+		}
+		gc.Diff, gc.DiffNoWS = joinOutput(gc.Diff, u.Diff), joinOutput(gc.DiffNoWS, u.Diff)
+		gc.NameStatus = joinOutput(gc.NameStatus, u.NameStatus)
+		binaries = append(binaries, u.Binaries...)
+	}
+	gc.Diff, gc.DiffNoWS = r.sanitizeDiff(gc.Diff), r.sanitizeDiff(gc.DiffNoWS)
+	gc.Submodules = r.describeSubmodules(parseSubmoduleChanges(gc.Diff))
+	gc.Binaries = describeBinaries(binaries)
+
+	return gc, nil
+}
+
+// unbornHead reports whether HEAD names a branch without commits yet, as in
+// a repository just made with git init.
+func (r Repo) unbornHead() bool {
+	if _, err := r.Git("rev-parse", "--verify", "-q", "HEAD"); err == nil {
+		return false
+	}
+	_, err := r.Git("symbolic-ref", "-q", "HEAD")
+	return err == nil
+}
+
+// CommandError attaches git's own explanation (e.g. "fatal: not a git
+// repository") to a failed command's error.
+func CommandError(err error, stderr string) error {
+	if err == nil {
+		return nil
+	}
+	if msg := strings.TrimSpace(stderr); msg != "" {
+		return fmt.Errorf("%w: %s", err, msg)
+	}
+	return err
+}
+
+// Path resolves the git executable once. On Windows LookPath finds
+// git.exe via PATH and PATHEXT; if it isn't found, the bare name is used so
+// the eventual error comes from exec with a clear message.
+var Path = sync.OnceValue(func() string {
+	if p, err := exec.LookPath("git"); err == nil {
+		return p
+	}
+	return "git"
+})
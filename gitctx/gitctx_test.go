@@ -0,0 +1,237 @@
+package gitctx
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testRepo makes an empty repository on branch main in a temporary
+// directory, with the user's and system's git config kept out, and returns
+// its directory and a Repo that runs git in it.
+func testRepo(tb testing.TB) (string, Repo) {
+	tb.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		tb.Skip("git not found")
+	}
+	for k, v := range map[string]string{
+		"GIT_CONFIG_GLOBAL":   os.DevNull,
+		"GIT_CONFIG_NOSYSTEM": "1",
+		"GIT_AUTHOR_NAME":     "Test",
+		"GIT_AUTHOR_EMAIL":    "test@example.com",
+		"GIT_COMMITTER_NAME":  "Test",
+		"GIT_COMMITTER_EMAIL": "test@example.com",
+		"LC_ALL":              "C",
+	} {
+		tb.Setenv(k, v)
+	}
+	dir := tb.TempDir()
+	r := Repo{Git: GitIn(context.Background(), dir)}
+	mustGit(tb, r, "init", "-q", "-b", "main")
+	return dir, r
+}
+
+// mustGit runs git in r and fails the test if it fails.
+func mustGit(tb testing.TB, r Repo, args ...string) string {
+	tb.Helper()
+	out, err := r.Git(args...)
+	if err != nil {
+		tb.Fatalf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return out
+}
+
+func writeFile(tb testing.TB, dir, name, content string) {
+	tb.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// commitFile writes name and commits it with subject.
+func commitFile(tb testing.TB, dir string, r Repo, name, content, subject string) {
+	tb.Helper()
+	writeFile(tb, dir, name, content)
+	mustGit(tb, r, "add", name)
+	mustGit(tb, r, "commit", "-q", "-m", subject)
+}
+
+func TestCollect(t *testing.T) {
+	dir, r := testRepo(t)
+	commitFile(t, dir, r, "a.txt", "one\n", "add a")
+	commitFile(t, dir, r, "b.txt", "two\n", "add b")
+	commitFile(t, dir, r, "old.txt", "moved\nunchanged\nlines\n", "add old")
+
+	writeFile(t, dir, "a.txt", "one\nstaged\n")
+	mustGit(t, r, "add", "a.txt")
+	mustGit(t, r, "mv", "old.txt", "new.txt")
+	writeFile(t, dir, "b.txt", "two\nunstaged\n")
+
+	tests := []struct {
+		name       string
+		diffArgs   []string
+		nameStatus string
+		diffHas    []string
+		diffHasNot []string
+		statusHas  string
+	}{
+		{
+			name:       "staged",
+			diffArgs:   []string{"diff", "--staged"},
+			nameStatus: "M\ta.txt\nR100\told.txt\tnew.txt",
+			diffHas:    []string{"+staged", "rename from old.txt"},
+			diffHasNot: []string{"+unstaged"},
+			statusHas:  "Changes to be committed",
+		},
+		{
+			name:       "worktree",
+			diffArgs:   []string{"diff", "HEAD"},
+			nameStatus: "M\ta.txt\nM\tb.txt\nR100\told.txt\tnew.txt",
+			diffHas:    []string{"+staged", "+unstaged"},
+			statusHas:  "Changes not staged for commit",
+		},
+		{
+			name:       "pathspec",
+			diffArgs:   []string{"diff", "HEAD", "--", "b.txt"},
+			nameStatus: "M\tb.txt",
+			diffHas:    []string{"+unstaged"},
+			diffHasNot: []string{"+staged"},
+		},
+		{
+			name:       "commit",
+			diffArgs:   []string{"show", "--format=", "HEAD~1"},
+			nameStatus: "A\tb.txt",
+			diffHas:    []string{"+two"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gc, err := r.Collect(tt.diffArgs, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gc.Branch != "main" {
+				t.Errorf("Branch = %q, want main", gc.Branch)
+			}
+			if lines := strings.Split(gc.Log, "\n"); len(lines) != 3 || !strings.HasSuffix(lines[0], " add old") || !strings.HasSuffix(lines[2], " add a") {
+				t.Errorf("Log = %q, want the three commits, newest first", gc.Log)
+			}
+			if gc.NameStatus != tt.nameStatus {
+				t.Errorf("NameStatus = %q, want %q", gc.NameStatus, tt.nameStatus)
+			}
+			for _, s := range tt.diffHas {
+				if !strings.Contains(gc.Diff, s) {
+					t.Errorf("Diff lacks %q:\n%s", s, gc.Diff)
+				}
+			}
+			for _, s := range tt.diffHasNot {
+				if strings.Contains(gc.Diff, s) {
+					t.Errorf("Diff has %q:\n%s", s, gc.Diff)
+				}
+			}
+			if !strings.Contains(gc.Status, tt.statusHas) {
+				t.Errorf("Status lacks %q:\n%s", tt.statusHas, gc.Status)
+			}
+			if gc.Initial {
+				t.Error("Initial is set in a repository with commits")
+			}
+		})
+	}
+}
+
+func TestCollectWhitespace(t *testing.T) {
+	dir, r := testRepo(t)
+	commitFile(t, dir, r, "a.txt", "one\ntwo\n", "add a")
+	writeFile(t, dir, "a.txt", "one  \ntwo\n")
+
+	gc, err := r.Collect([]string{"diff", "HEAD"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(gc.Diff, "+one  ") {
+		t.Errorf("Diff lacks the whitespace change:\n%s", gc.Diff)
+	}
+	if strings.Contains(gc.DiffNoWS, "@@") {
+		t.Errorf("DiffNoWS has hunks for a whitespace-only change:\n%s", gc.DiffNoWS)
+	}
+}
+
+func TestCollectUnbornHead(t *testing.T) {
+	dir, r := testRepo(t)
+	writeFile(t, dir, "hello.txt", "hello\n")
+	mustGit(t, r, "add", "hello.txt")
+
+	for _, diffArgs := range [][]string{{"diff", "--staged"}, {"diff", "HEAD"}} {
+		t.Run(strings.Join(diffArgs, " "), func(t *testing.T) {
+			gc, err := r.Collect(diffArgs, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !gc.Initial {
+				t.Error("Initial isn't set before the first commit")
+			}
+			if gc.Branch != "main" {
+				t.Errorf("Branch = %q, want main", gc.Branch)
+			}
+			if gc.Log != "" {
+				t.Errorf("Log = %q, want none", gc.Log)
+			}
+			if gc.NameStatus != "A\thello.txt" {
+				t.Errorf("NameStatus = %q, want A\\thello.txt", gc.NameStatus)
+			}
+			if !strings.Contains(gc.Diff, "+hello") {
+				t.Errorf("Diff lacks the new file:\n%s", gc.Diff)
+			}
+			if !strings.Contains(gc.Status, "No commits yet") {
+				t.Errorf("Status = %q", gc.Status)
+			}
+		})
+	}
+}
+
+func TestCollectUntracked(t *testing.T) {
+	dir, r := testRepo(t)
+	commitFile(t, dir, r, "a.txt", "one\n", "add a")
+	writeFile(t, dir, "new.txt", "fresh\n")
+
+	r.Untracked = UntrackedContent
+	gc, err := r.Collect([]string{"diff", "HEAD"}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(gc.Diff, "+fresh") {
+		t.Errorf("Diff lacks the untracked file:\n%s", gc.Diff)
+	}
+	if !strings.Contains(gc.NameStatus, "A\tnew.txt") {
+		t.Errorf("NameStatus = %q, want the untracked file added", gc.NameStatus)
+	}
+}
+
+// BenchmarkGatherContext measures Collect on a repository with some history
+// and a change to a tenth of its files.
+func BenchmarkGatherContext(b *testing.B) {
+	dir, r := testRepo(b)
+	for i := range 20 {
+		for j := range 10 {
+			writeFile(b, dir, filepath.Join("pkg", string(rune('a'+j)), "file.go"), strings.Repeat("line\n", 50+i))
+		}
+		mustGit(b, r, "add", ".")
+		mustGit(b, r, "commit", "-q", "-m", "change "+string(rune('a'+i)))
+	}
+	writeFile(b, dir, "pkg/a/file.go", strings.Repeat("changed\n", 30))
+	mustGit(b, r, "add", "pkg/a/file.go")
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := r.Collect([]string{"diff", "--staged"}, 4); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
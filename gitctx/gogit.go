@@ -0,0 +1,630 @@
+package gitctx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// The go-git backend reads the repository with go-git instead of running the
+// git executable, for containers and CI images that have none. It covers
+// what describing pending changes needs, the "diff --staged" and
+// "diff HEAD" diffs, the status, the branch, and the log; everything that
+// writes to the repository or reads history still runs git.
+
+// DiscoverGoGit is Discover without git: it finds the repository dir is in.
+func DiscoverGoGit(dir string) (Layout, error) {
+	r, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true, EnableDotGitCommonDir: true})
+	if err != nil {
+		return Layout{}, err
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return Layout{}, err
+	}
+	l := Layout{Top: wt.Filesystem.Root()}
+	// A linked worktree's .git is a file naming its git directory, which
+	// names the common one in its commondir file.
+	l.GitDir = filepath.Join(l.Top, ".git")
+	if data, err := os.ReadFile(l.GitDir); err == nil {
+		if p, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: "); ok {
+			l.GitDir = absFrom(l.Top, p)
+		}
+	}
+	l.CommonDir = l.GitDir
+	if data, err := os.ReadFile(filepath.Join(l.GitDir, "commondir")); err == nil {
+		l.CommonDir = absFrom(l.GitDir, strings.TrimSpace(string(data)))
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return Layout{}, err
+	}
+	if rel, err := filepath.Rel(l.Top, abs); err == nil && rel != "." {
+		l.Prefix = filepath.ToSlash(rel) + "/"
+	}
+	return l, nil
+}
+
+func absFrom(dir, p string) string {
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(dir, p)
+}
+
+// CollectGoGit is Collect with go-git, for the work tree at top. diffArgs
+// must be one of the pending-change diffs, "diff --staged" or "diff HEAD",
+// optionally with a pathspec of files and directories. Renames are detected
+// only when the content is unchanged, and the status is in the short format.
+func (r Repo) CollectGoGit(top string, diffArgs []string) (CommitContext, error) {
+	var gc CommitContext
+	opts, paths := SplitPathspec(diffArgs)
+	staged, err := pendingDiff(opts)
+	if err != nil {
+		return gc, err
+	}
+	repo, err := git.PlainOpenWithOptions(top, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return gc, err
+	}
+
+	changes, err := pendingChanges(repo, top, staged, paths)
+	if err != nil {
+		return gc, fmt.Errorf("reading the changes: %w", err)
+	}
+	if gc.Diff, err = encodePatch(changes, false); err != nil {
+		return gc, err
+	}
+	if gc.DiffNoWS, err = encodePatch(changes, true); err != nil {
+		return gc, err
+	}
+	gc.NameStatus = nameStatus(changes)
+	if gc.Status, err = shortStatus(repo); err != nil {
+		return gc, fmt.Errorf("reading the status: %w", err)
+	}
+	gc.Branch = headBranch(repo)
+	_, err = repo.Head()
+	gc.Initial = errors.Is(err, plumbing.ErrReferenceNotFound)
+	if gc.Log, err = onelineLog(repo, 10); err != nil {
+		return gc, fmt.Errorf("reading the log: %w", err)
+	}
+	gc.Diff, gc.DiffNoWS = r.sanitizeDiff(gc.Diff), r.sanitizeDiff(gc.DiffNoWS)
+	if binaries := parseBinaryChanges(gc.Diff); len(binaries) > 0 {
+		addBlobSizes(binaries, changes)
+		gc.Binaries = describeBinaries(binaries)
+	}
+	return gc, nil
+}
+
+// HasStagedGoGit reports whether the index at top differs from HEAD, as
+// "git diff --staged --quiet" does.
+func HasStagedGoGit(top string) (bool, error) {
+	repo, err := git.PlainOpenWithOptions(top, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return false, err
+	}
+	head, err := headEntries(repo)
+	if err != nil {
+		return false, err
+	}
+	index, err := indexEntries(repo)
+	if err != nil {
+		return false, err
+	}
+	if len(head) != len(index) {
+		return true, nil
+	}
+	for p, e := range index {
+		if h, ok := head[p]; !ok || h.Hash != e.Hash || h.Mode != e.Mode {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// pendingDiff reads diff options as resolveRange builds them: staged for
+// "diff --staged", the work tree for "diff HEAD".
+func pendingDiff(opts []string) (staged bool, err error) {
+	unsupported := fmt.Errorf("git %s needs the git executable; the go-git backend reads only the staged changes and the work tree", strings.Join(opts, " "))
+	if len(opts) == 0 || opts[0] != "diff" {
+		return false, unsupported
+	}
+	head := false
+	for _, o := range opts[1:] {
+		switch {
+		case o == "--staged" || o == "--cached":
+			staged = true
+		case o == "HEAD":
+			head = true
+		case strings.HasPrefix(o, "--diff-algorithm="):
+		default:
+			return false, unsupported
+		}
+	}
+	if staged == head {
+		return false, unsupported
+	}
+	return staged, nil
+}
+
+// blobEntry is a path's content in a tree, the index, or the work tree.
+// data is set for work tree files, which aren't in the object store.
+type blobEntry struct {
+	Hash plumbing.Hash
+	Mode filemode.FileMode
+	data []byte
+}
+
+// fileChange is one file's change: From is nil for an added file and To for
+// a deleted one.
+type fileChange struct {
+	FromPath, ToPath string
+	From, To         *blobEntry
+	repo             *git.Repository
+}
+
+func headEntries(repo *git.Repository) (map[string]blobEntry, error) {
+	entries := map[string]blobEntry{}
+	ref, err := repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return entries, nil // no commit yet
+	} else if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	w := object.NewTreeWalker(tree, true, nil)
+	defer w.Close()
+	for {
+		name, e, err := w.Next()
+		if err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if e.Mode != filemode.Dir {
+			entries[name] = blobEntry{Hash: e.Hash, Mode: e.Mode}
+		}
+	}
+}
+
+// indexEntries returns the index's merged entries; conflicted paths and
+// ones only added with --intent-to-add are left out.
+func indexEntries(repo *git.Repository) (map[string]blobEntry, error) {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	entries := map[string]blobEntry{}
+	for _, e := range idx.Entries {
+		if e.Stage == 0 && !e.IntentToAdd {
+			entries[e.Name] = blobEntry{Hash: e.Hash, Mode: e.Mode}
+		}
+	}
+	return entries, nil
+}
+
+// worktreeEntries returns the work tree's version of the files in the
+// index. A file whose size and modification time still match the index is
+// taken to be unchanged without reading it, as git does.
+func worktreeEntries(repo *git.Repository, top string) (map[string]blobEntry, error) {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	entries := map[string]blobEntry{}
+	for _, e := range idx.Entries {
+		if e.Stage != 0 || e.IntentToAdd {
+			continue
+		}
+		if e.Mode == filemode.Submodule || e.SkipWorktree {
+			entries[e.Name] = blobEntry{Hash: e.Hash, Mode: e.Mode}
+			continue
+		}
+		name := filepath.Join(top, filepath.FromSlash(e.Name))
+		fi, err := os.Lstat(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // deleted
+		} else if err != nil {
+			return nil, err
+		}
+		var data []byte
+		mode := filemode.Regular
+		switch {
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(name)
+			if err != nil {
+				return nil, err
+			}
+			data, mode = []byte(filepath.ToSlash(target)), filemode.Symlink
+		case fi.Mode().IsRegular():
+			if fi.Mode()&0o111 != 0 {
+				mode = filemode.Executable
+			}
+			if mode == e.Mode && int64(e.Size) == fi.Size() && e.ModifiedAt.Equal(fi.ModTime()) {
+				entries[e.Name] = blobEntry{Hash: e.Hash, Mode: e.Mode}
+				continue
+			}
+			if data, err = os.ReadFile(name); err != nil {
+				return nil, err
+			}
+		default:
+			continue // a directory where the file was
+		}
+		entries[e.Name] = blobEntry{Hash: plumbing.ComputeHash(plumbing.BlobObject, data), Mode: mode, data: data}
+	}
+	return entries, nil
+}
+
+// pendingChanges compares HEAD with the index, or with the work tree, and
+// returns the changed files under paths (all of them when it's empty) in
+// path order.
+func pendingChanges(repo *git.Repository, top string, staged bool, paths []string) ([]fileChange, error) {
+	from, err := headEntries(repo)
+	if err != nil {
+		return nil, err
+	}
+	var to map[string]blobEntry
+	if staged {
+		to, err = indexEntries(repo)
+	} else {
+		to, err = worktreeEntries(repo, top)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var added, deleted, changes []fileChange
+	for p, f := range from {
+		if !inPathspec(p, paths) {
+			continue
+		}
+		if t, ok := to[p]; !ok {
+			deleted = append(deleted, fileChange{FromPath: p, ToPath: p, From: &f, repo: repo})
+		} else if t.Hash != f.Hash || t.Mode != f.Mode {
+			changes = append(changes, fileChange{FromPath: p, ToPath: p, From: &f, To: &t, repo: repo})
+		}
+	}
+	for p, t := range to {
+		if _, ok := from[p]; !ok && inPathspec(p, paths) {
+			added = append(added, fileChange{FromPath: p, ToPath: p, To: &t, repo: repo})
+		}
+	}
+
+	// A deleted file added again elsewhere with the same content is a
+	// rename.
+	for i := range added {
+		for j := range deleted {
+			if d := deleted[j]; d.From != nil && d.From.Hash == added[i].To.Hash {
+				added[i].FromPath, added[i].From = d.FromPath, d.From
+				deleted[j].From = nil
+				break
+			}
+		}
+	}
+	changes = append(changes, added...)
+	for _, d := range deleted {
+		if d.From != nil {
+			changes = append(changes, d)
+		}
+	}
+	slices.SortFunc(changes, func(a, b fileChange) int { return strings.Compare(a.ToPath, b.ToPath) })
+	return changes, nil
+}
+
+func inPathspec(p string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, spec := range paths {
+		spec = strings.TrimSuffix(path.Clean(spec), "/")
+		if spec == "." || spec == p || strings.HasPrefix(p, spec+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func nameStatus(changes []fileChange) string {
+	var lines []string
+	for _, c := range changes {
+		switch {
+		case c.From == nil:
+			lines = append(lines, "A\t"+c.ToPath)
+		case c.To == nil:
+			lines = append(lines, "D\t"+c.FromPath)
+		case c.FromPath != c.ToPath:
+			lines = append(lines, "R100\t"+c.FromPath+"\t"+c.ToPath)
+		default:
+			lines = append(lines, "M\t"+c.ToPath)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// addBlobSizes fills in the sizes of binaries from the changes they were
+// found in.
+func addBlobSizes(binaries []binaryChange, changes []fileChange) {
+	for i, b := range binaries {
+		for _, c := range changes {
+			p := c.ToPath
+			if c.To == nil {
+				p = c.FromPath
+			}
+			if p != b.Path {
+				continue
+			}
+			from, errFrom := c.content(c.From)
+			to, errTo := c.content(c.To)
+			if errFrom == nil && errTo == nil {
+				binaries[i].OldSize, binaries[i].NewSize = int64(len(from)), int64(len(to))
+			}
+			break
+		}
+	}
+}
+
+// content returns what e holds as a diff shows it: a submodule as its
+// "Subproject commit" line, like --submodule=short.
+func (c fileChange) content(e *blobEntry) (string, error) {
+	switch {
+	case e == nil:
+		return "", nil
+	case e.Mode == filemode.Submodule:
+		return "Subproject commit " + e.Hash.String() + "\n", nil
+	case e.data != nil:
+		return string(e.data), nil
+	}
+	blob, err := c.repo.BlobObject(e.Hash)
+	if err != nil {
+		return "", err
+	}
+	rd, err := blob.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer rd.Close()
+	data, err := io.ReadAll(rd)
+	return string(data), err
+}
+
+// encodePatch writes changes as git's unified diff; ignoreWS leaves out
+// changes in whitespace, like -w.
+func encodePatch(changes []fileChange, ignoreWS bool) (string, error) {
+	var p patch
+	for _, c := range changes {
+		from, err := c.content(c.From)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", c.FromPath, err)
+		}
+		to, err := c.content(c.To)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", c.ToPath, err)
+		}
+		fp := filePatch{}
+		if c.From != nil {
+			fp.from = &patchFile{c.FromPath, *c.From}
+		}
+		if c.To != nil {
+			fp.to = &patchFile{c.ToPath, *c.To}
+		}
+		if fp.binary = isBinary(from) || isBinary(to); !fp.binary {
+			fp.chunks = lineChunks(from, to, ignoreWS)
+		}
+		p = append(p, fp)
+	}
+	var b bytes.Buffer
+	if err := fdiff.NewUnifiedEncoder(&b, fdiff.DefaultContextLines).Encode(p); err != nil {
+		return "", err
+	}
+	return gitHeaders(strings.TrimSpace(b.String())), nil
+}
+
+// indexLineRe matches an index line of fdiff.UnifiedEncoder, which has the
+// full hashes.
+var indexLineRe = regexp.MustCompile(`(?m)^index ([0-9a-f]{7})[0-9a-f]{33}\.\.([0-9a-f]{7})[0-9a-f]{33}`)
+
+// gitHeaders makes the encoder's file headers read like git's: hashes
+// abbreviated, and renames, all exact, with their similarity.
+func gitHeaders(patch string) string {
+	patch = indexLineRe.ReplaceAllString(patch, "index $1..$2")
+	return strings.ReplaceAll(patch, "\nrename from ", "\nsimilarity index 100%\nrename from ")
+}
+
+// isBinary is git's test: a NUL byte in the first 8000.
+func isBinary(s string) bool {
+	return strings.IndexByte(s[:min(len(s), 8000)], 0) >= 0
+}
+
+// lineChunks diffs from and to by line. With ignoreWS, lines are compared
+// with their whitespace removed, and equal ones are shown as they are in
+// to.
+func lineChunks(from, to string, ignoreWS bool) []fdiff.Chunk {
+	if !ignoreWS {
+		var chunks []fdiff.Chunk
+		for _, d := range diff.Do(from, to) {
+			chunks = append(chunks, chunk{d.Text, operation(d.Type)})
+		}
+		return chunks
+	}
+
+	fromLines, toLines := splitLines(from), splitLines(to)
+	var chunks []fdiff.Chunk
+	i, j := 0, 0
+	take := func(lines []string, at *int, n int) string {
+		s := strings.Join(lines[*at:*at+n], "")
+		*at += n
+		return s
+	}
+	for _, d := range diff.Do(stripLines(fromLines), stripLines(toLines)) {
+		n := strings.Count(d.Text, "\n")
+		switch d.Type {
+		case dmp.DiffEqual:
+			i += n
+			chunks = append(chunks, chunk{take(toLines, &j, n), fdiff.Equal})
+		case dmp.DiffDelete:
+			chunks = append(chunks, chunk{take(fromLines, &i, n), fdiff.Delete})
+		case dmp.DiffInsert:
+			chunks = append(chunks, chunk{take(toLines, &j, n), fdiff.Add})
+		}
+	}
+	return chunks
+}
+
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// stripLines joins lines with their whitespace removed, one per line.
+func stripLines(lines []string) string {
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, l))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func operation(t dmp.Operation) fdiff.Operation {
+	switch t {
+	case dmp.DiffDelete:
+		return fdiff.Delete
+	case dmp.DiffInsert:
+		return fdiff.Add
+	}
+	return fdiff.Equal
+}
+
+func shortStatus(repo *git.Repository) (string, error) {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSpace(status.String()), "\n")
+	slices.SortFunc(lines, func(a, b string) int { return strings.Compare(a[min(len(a), 3):], b[min(len(b), 3):]) })
+	return strings.Join(lines, "\n"), nil
+}
+
+// headBranch is "git rev-parse --abbrev-ref HEAD": the branch name, or HEAD
+// when detached. A branch without commits yet is named too.
+func headBranch(repo *git.Repository) string {
+	ref, err := repo.Reference(plumbing.HEAD, false)
+	if err != nil || ref.Type() != plumbing.SymbolicReference {
+		return "HEAD"
+	}
+	return ref.Target().Short()
+}
+
+// onelineLog is "git log -n n --oneline" without decorations.
+func onelineLog(repo *git.Repository, n int) (string, error) {
+	ref, err := repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	commits, err := repo.Log(&git.LogOptions{From: ref.Hash()})
+	if err != nil {
+		return "", err
+	}
+	defer commits.Close()
+	var lines []string
+	for len(lines) < n {
+		c, err := commits.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		subject, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+		lines = append(lines, c.Hash.String()[:7]+" "+subject)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// patch, filePatch, patchFile, and chunk implement go-git's diff.Patch for
+// fdiff.UnifiedEncoder.
+type patch []filePatch
+
+func (p patch) FilePatches() []fdiff.FilePatch {
+	fps := make([]fdiff.FilePatch, len(p))
+	for i, fp := range p {
+		fps[i] = fp
+	}
+	return fps
+}
+
+func (patch) Message() string { return "" }
+
+type filePatch struct {
+	from, to *patchFile
+	binary   bool
+	chunks   []fdiff.Chunk
+}
+
+func (fp filePatch) IsBinary() bool { return fp.binary }
+
+func (fp filePatch) Files() (from, to fdiff.File) {
+	// Typed nil pointers would not compare equal to nil.
+	if fp.from != nil {
+		from = fp.from
+	}
+	if fp.to != nil {
+		to = fp.to
+	}
+	return from, to
+}
+
+func (fp filePatch) Chunks() []fdiff.Chunk { return fp.chunks }
+
+type patchFile struct {
+	path  string
+	entry blobEntry
+}
+
+func (f *patchFile) Hash() plumbing.Hash     { return f.entry.Hash }
+func (f *patchFile) Mode() filemode.FileMode { return f.entry.Mode }
+func (f *patchFile) Path() string            { return f.path }
+
+type chunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c chunk) Content() string       { return c.content }
+func (c chunk) Type() fdiff.Operation { return c.op }
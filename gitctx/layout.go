@@ -0,0 +1,44 @@
+package gitctx
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Layout is where a repository's files are, as git finds them from the
+// working directory, GIT_DIR, and GIT_WORK_TREE.
+type Layout struct {
+	Top       string // top level of the work tree
+	GitDir    string // this work tree's git directory; .git/worktrees/<name> in a linked worktree
+	CommonDir string // git directory shared by all of the repository's work trees
+	Prefix    string // working directory relative to Top, with a trailing slash; "" at the top
+}
+
+// Worktree reports whether l is a linked worktree, one added with git
+// worktree add.
+func (l Layout) Worktree() bool {
+	return l.GitDir != l.CommonDir
+}
+
+// Discover asks git which repository the working directory belongs to. git
+// must run in the working directory, not in a Layout's Top.
+func Discover(git Runner) (Layout, error) {
+	out, err := git("rev-parse", "--show-toplevel", "--absolute-git-dir", "--git-common-dir", "--show-prefix")
+	if err != nil {
+		return Layout{}, err
+	}
+	lines := strings.Split(out, "\n")
+	if len(lines) < 3 {
+		return Layout{}, fmt.Errorf("unexpected git rev-parse output %q", out)
+	}
+	l := Layout{Top: lines[0], GitDir: lines[1], CommonDir: lines[2]}
+	if len(lines) > 3 {
+		l.Prefix = lines[3]
+	}
+	// --git-common-dir is relative to the working directory.
+	if l.CommonDir, err = filepath.Abs(l.CommonDir); err != nil {
+		return Layout{}, err
+	}
+	return l, nil
+}
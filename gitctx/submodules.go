@@ -0,0 +1,111 @@
+package gitctx
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// submoduleChange is a submodule pointer update found in a diff. Old is empty
+// for an added submodule and New for a removed one.
+type submoduleChange struct {
+	Path     string
+	Old, New string
+}
+
+// parseSubmoduleChanges finds the "Subproject commit" lines git prints for
+// submodule (gitlink) changes.
+func parseSubmoduleChanges(diff string) []submoduleChange {
+	var changes []submoduleChange
+	for _, f := range SplitFiles(diff) {
+		c := submoduleChange{Path: f.Path}
+		for _, line := range strings.Split(f.Text, "\n") {
+			if sha, ok := strings.CutPrefix(line, "-Subproject commit "); ok {
+				c.Old = strings.TrimSuffix(sha, "-dirty")
+			} else if sha, ok := strings.CutPrefix(line, "+Subproject commit "); ok {
+				c.New = strings.TrimSuffix(sha, "-dirty")
+			}
+		}
+		if c.Old != "" || c.New != "" {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// initializedSubmodules returns the paths of submodules that are checked out,
+// according to `git submodule status` (uninitialized ones start with "-").
+func (r Repo) initializedSubmodules(top string, paths []string) map[string]bool {
+	out, err := r.Git(append([]string{"-C", top, "submodule", "status", "--"}, paths...)...)
+	if err != nil {
+		return nil
+	}
+	init := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" || line[0] == '-' {
+			continue
+		}
+		if fields := strings.Fields(line[1:]); len(fields) >= 2 {
+			init[fields[1]] = true
+		}
+	}
+	return init
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+// describeSubmodules turns submodule changes into one readable line each,
+// adding the subject of the new submodule commit when the submodule is
+// checked out.
+func (r Repo) describeSubmodules(changes []submoduleChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	var paths []string
+	for _, c := range changes {
+		paths = append(paths, c.Path)
+	}
+	// Diff paths are relative to the top level, not the working directory.
+	top, err := r.Git("rev-parse", "--show-toplevel")
+	if err != nil {
+		top = "."
+	}
+	init := r.initializedSubmodules(top, paths)
+
+	var lines []string
+	for _, c := range changes {
+		var line string
+		switch {
+		case c.Old == "":
+			line = fmt.Sprintf("add submodule %s at %s", c.Path, shortSHA(c.New))
+		case c.New == "":
+			line = fmt.Sprintf("remove submodule %s (was at %s)", c.Path, shortSHA(c.Old))
+		default:
+			line = fmt.Sprintf("update submodule %s from %s to %s", c.Path, shortSHA(c.Old), shortSHA(c.New))
+		}
+		if c.New != "" && init[c.Path] {
+			if subject, err := r.Git("-C", filepath.Join(top, c.Path), "log", "-1", "--format=%s", c.New); err == nil && subject != "" {
+				line += fmt.Sprintf(" (%q)", subject)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DropSubmoduleDiffs removes the per-file diffs of submodules, which are
+// described separately.
+func DropSubmoduleDiffs(diff string) string {
+	var b strings.Builder
+	for _, f := range SplitFiles(diff) {
+		if len(parseSubmoduleChanges(f.Text)) == 0 {
+			b.WriteString(f.Text)
+		}
+	}
+	return b.String()
+}
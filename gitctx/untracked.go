@@ -0,0 +1,107 @@
+package gitctx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Untracked is how Collect treats the untracked files that aren't ignored,
+// which git diff leaves out but git add . commits along with the rest.
+type Untracked int
+
+const (
+	UntrackedNone    Untracked = iota // left out, as by git diff
+	UntrackedNames                    // listed as new files, without their content
+	UntrackedContent                  // diffed as new files, within the caps below
+)
+
+// Untracked files past these caps are listed without their content, so a
+// new data file or a forgotten build directory doesn't crowd out the rest.
+const (
+	untrackedMaxBytes = 64 << 10
+	untrackedMaxFiles = 50
+)
+
+// UntrackedFiles lists the untracked files that aren't ignored, relative to
+// the top level; paths is a pathspec with its "--", or nil.
+func (r Repo) UntrackedFiles(paths []string) ([]string, error) {
+	out, err := r.Git(slices.Concat([]string{"ls-files", "-z", "--others", "--exclude-standard"}, paths)...)
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files failed: %w", err)
+	}
+	var files []string
+	for _, f := range strings.Split(out, "\x00") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// untrackedChanges is what the untracked files add to a CommitContext.
+type untrackedChanges struct {
+	Diff, NameStatus string
+	Binaries         []binaryChange
+}
+
+// collectUntracked diffs the untracked files against /dev/null, as the new
+// files they will be once added.
+func (r Repo) collectUntracked(paths []string) (untrackedChanges, error) {
+	var u untrackedChanges
+	files, err := r.UntrackedFiles(paths)
+	if err != nil || len(files) == 0 {
+		return u, err
+	}
+	top, err := r.Git("rev-parse", "--show-toplevel")
+	if err != nil {
+		top = "."
+	}
+	var diffs, names []string
+	for i, f := range files {
+		names = append(names, "A\t"+f)
+		info, err := os.Lstat(filepath.Join(top, filepath.FromSlash(f)))
+		if err != nil {
+			r.debugf("Reading untracked %s: %v", f, err)
+			continue
+		}
+		header := fmt.Sprintf("diff --git a/%s b/%s\nnew file mode 100644\n", f, f)
+		var why string
+		switch {
+		case r.Untracked == UntrackedNames:
+			why = "content not shown"
+		case i >= untrackedMaxFiles:
+			why = fmt.Sprintf("content not shown past the first %d untracked files", untrackedMaxFiles)
+		case info.Size() > untrackedMaxBytes:
+			why = fmt.Sprintf("%s, too large to show", byteSize(info.Size()))
+		}
+		if why != "" {
+			diffs = append(diffs, header+"(untracked file; "+why+")")
+			continue
+		}
+		// git diff --no-index exits 1 when the files differ, which they
+		// always do here; the diff itself is what tells success apart.
+		d, err := r.Git("diff", "--no-index", "--", "/dev/null", f)
+		if d == "" {
+			r.debugf("Diffing untracked %s: %v", f, err)
+			continue
+		}
+		for _, b := range parseBinaryChanges(d) {
+			b.OldSize, b.NewSize = 0, info.Size()
+			u.Binaries = append(u.Binaries, b)
+		}
+		diffs = append(diffs, d)
+	}
+	u.Diff, u.NameStatus = strings.Join(diffs, "\n"), strings.Join(names, "\n")
+	return u, nil
+}
+
+// joinOutput appends b to git output a, either of which may be empty.
+func joinOutput(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + "\n" + b
+}
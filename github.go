@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+var prURLRe = regexp.MustCompile(`^https?://github\.com/([^/]+)/([^/]+)/pull/(\d+)`)
+
+var linkNextRe = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// prFileStatus maps GitHub's file status names to --name-status letters.
+var prFileStatus = map[string]string{
+	"added":     "A",
+	"removed":   "D",
+	"modified":  "M",
+	"changed":   "M",
+	"unchanged": "M",
+	"renamed":   "R",
+	"copied":    "C",
+}
+
+var githubClient = &http.Client{Timeout: 30 * time.Second}
+
+// githubGet fetches url from the GitHub API into v, returning the next page
+// URL from the Link header, if any. GITHUB_TOKEN is used when set.
+func githubGet(url string, v any) (next string, err error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := githubClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.StatusCode == http.StatusTooManyRequests {
+			msg := "GitHub API rate limit exceeded"
+			if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+				msg += fmt.Sprintf("; resets at %s", time.Unix(reset, 0).Format(time.Kitchen))
+			}
+			if os.Getenv("GITHUB_TOKEN") == "" {
+				msg += " (set GITHUB_TOKEN for a higher limit)"
+			}
+			return "", fmt.Errorf("%s", msg)
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("GET %s: %s: %s", url, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", err
+	}
+	if m := linkNextRe.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+		next = m[1]
+	}
+	return next, nil
+}
+
+// fetchPullRequest builds the prompt context for a GitHub pull request from
+// the API, without touching the local repository. Files and commits are
+// paginated; files GitHub doesn't return a patch for (binary or too large)
+// are listed by name only.
+func fetchPullRequest(prURL string) (gitctx.CommitContext, error) {
+	m := prURLRe.FindStringSubmatch(prURL)
+	if m == nil {
+		return gitctx.CommitContext{}, fmt.Errorf("invalid pull request URL %q (want https://github.com/<owner>/<repo>/pull/<n>)", prURL)
+	}
+	api := fmt.Sprintf("https://api.github.com/repos/%s/%s/pulls/%s", m[1], m[2], m[3])
+
+	var pr struct {
+		Title string `json:"title"`
+		State string `json:"state"`
+		Head  struct {
+			Ref string `json:"ref"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	}
+	if _, err := githubGet(api, &pr); err != nil {
+		return gitctx.CommitContext{}, err
+	}
+
+	var diff, nameStatus strings.Builder
+	for url := api + "/files?per_page=100"; url != ""; {
+		var files []struct {
+			Filename         string `json:"filename"`
+			PreviousFilename string `json:"previous_filename"`
+			Status           string `json:"status"`
+			Patch            string `json:"patch"`
+		}
+		next, err := githubGet(url, &files)
+		if err != nil {
+			return gitctx.CommitContext{}, err
+		}
+		for _, f := range files {
+			old := f.Filename
+			if f.PreviousFilename != "" {
+				old = f.PreviousFilename
+				fmt.Fprintf(&nameStatus, "R\t%s\t%s\n", old, f.Filename)
+			} else {
+				fmt.Fprintf(&nameStatus, "%s\t%s\n", prFileStatus[f.Status], f.Filename)
+			}
+			fmt.Fprintf(&diff, "diff --git a/%s b/%s\n", old, f.Filename)
+			if f.Patch == "" {
+				diff.WriteString("(no patch available: binary or too large)\n")
+				continue
+			}
+			fmt.Fprintf(&diff, "--- a/%s\n+++ b/%s\n%s\n", old, f.Filename, f.Patch)
+		}
+		url = next
+	}
+
+	var log strings.Builder
+	for url := api + "/commits?per_page=100"; url != ""; {
+		var commits []struct {
+			SHA    string `json:"sha"`
+			Commit struct {
+				Message string `json:"message"`
+			} `json:"commit"`
+		}
+		next, err := githubGet(url, &commits)
+		if err != nil {
+			return gitctx.CommitContext{}, err
+		}
+		for _, c := range commits {
+			subject, _ := generator.SplitMessage(c.Commit.Message)
+			fmt.Fprintf(&log, "%s %s\n", c.SHA[:7], subject)
+		}
+		url = next
+	}
+
+	return gitctx.CommitContext{
+		Status:     fmt.Sprintf("Pull request %s/%s#%s (%s): %s\nMerging %s into %s", m[1], m[2], m[3], pr.State, pr.Title, pr.Head.Ref, pr.Base.Ref),
+		Branch:     pr.Head.Ref,
+		Log:        strings.TrimSpace(log.String()),
+		Diff:       strings.TrimSpace(diff.String()),
+		NameStatus: strings.TrimSpace(nameStatus.String()),
+	}, nil
+}
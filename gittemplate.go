@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// loadCommitTemplate reads the commit template from path, or from git's
+// commit.template setting when path is empty. A missing setting is not an
+// error; it just means there is no template.
+func loadCommitTemplate(path string) (string, error) {
+	if path == "" {
+		configured, err := runGit("config", "--path", "--get", "commit.template")
+		if err != nil || strings.TrimSpace(configured) == "" {
+			return "", nil
+		}
+		path = strings.TrimSpace(configured)
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			home, _ := os.UserHomeDir()
+			path = filepath.Join(home, rest)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return gitctx.NormalizeNewlines(string(data)), nil
+}
+
+// mergeTemplate places msg into template at the first line that is not a
+// comment. That line is replaced when it is blank or placeholder text; a
+// trailer such as "Signed-off-by:" is kept below the message instead.
+// Comment lines stay where they are.
+func mergeTemplate(template, msg string) string {
+	lines := strings.Split(strings.TrimRight(template, "\n"), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		var merged []string
+		merged = append(merged, lines[:i]...)
+		merged = append(merged, msg)
+		if trailerLineRe.MatchString(line) {
+			merged = append(merged, "", line)
+		}
+		merged = append(merged, lines[i+1:]...)
+		return strings.Join(merged, "\n") + "\n"
+	}
+	return msg + "\n\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// stripComments drops comment lines and surrounding blank lines, which is
+// what git records for a message with --cleanup=strip.
+func stripComments(msg string) string {
+	var kept []string
+	for _, line := range strings.Split(msg, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			kept = append(kept, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
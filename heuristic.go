@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// heuristicMessage builds a basic commit message from the changed file list
+// without calling a model, e.g. "chore: update 3 files in pkg/foo". It is the
+// --offline fallback; the wording is deliberately plain. A single scope
+// becomes the scope of the subject, e.g. "chore(auth): update 2 files".
+func heuristicMessage(style generator.Style, gc gitctx.CommitContext, scopes []string) string {
+	changes := gitctx.ParseNameStatus(gc.NameStatus)
+	if len(changes) == 0 {
+		return "chore: update files"
+	}
+
+	kind, verb := "chore", "update"
+	switch {
+	case allChanges(changes, func(c gitctx.FileChange) bool { return clusterKey(c.Path) == "docs" }):
+		kind = "docs"
+	case allChanges(changes, func(c gitctx.FileChange) bool { return isTestPath(c.Path) }):
+		kind = "test"
+	case allChanges(changes, func(c gitctx.FileChange) bool { return c.Status == "A" }):
+		kind = "feat"
+	}
+	switch {
+	case allChanges(changes, func(c gitctx.FileChange) bool { return c.Status == "A" }):
+		verb = "add"
+	case allChanges(changes, func(c gitctx.FileChange) bool { return c.Status == "D" }):
+		verb = "remove"
+	case allChanges(changes, func(c gitctx.FileChange) bool { return strings.HasPrefix(c.Status, "R") }):
+		verb = "rename"
+	}
+
+	var desc string
+	if len(changes) == 1 {
+		desc = verb + " " + path.Base(changes[0].Path)
+	} else {
+		desc = fmt.Sprintf("%s %d files", verb, len(changes))
+	}
+	if dir := commonDir(changes); dir != "" {
+		desc += " in " + dir
+	}
+
+	if len(scopes) == 1 && style != generator.StyleSimple {
+		kind += "(" + scopes[0] + ")"
+	}
+	switch style {
+	case generator.StyleSimple:
+		return desc
+	case generator.StyleDetailed:
+		lines := 0
+		for _, f := range gitctx.SplitFiles(gc.Diff) {
+			lines += f.Changed
+		}
+		return fmt.Sprintf("%s: %s\nChange %d lines across %d files.", kind, desc, lines, len(changes))
+	}
+	return kind + ": " + desc
+}
+
+func allChanges(changes []gitctx.FileChange, pred func(gitctx.FileChange) bool) bool {
+	return !slices.ContainsFunc(changes, func(c gitctx.FileChange) bool { return !pred(c) })
+}
+
+func isTestPath(p string) bool {
+	base := path.Base(p)
+	return strings.HasSuffix(base, "_test.go") || strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") ||
+		strings.HasPrefix(p, "test/") || strings.HasPrefix(p, "tests/") || strings.Contains(p, "/test/") || strings.Contains(p, "/tests/")
+}
+
+// commonDir returns the deepest directory containing every changed path, or
+// "" when that is the repository root.
+func commonDir(changes []gitctx.FileChange) string {
+	dir := path.Dir(changes[0].Path)
+	for _, c := range changes[1:] {
+		for dir != "." && c.Path != dir && !strings.HasPrefix(c.Path, dir+"/") {
+			dir = path.Dir(dir)
+		}
+	}
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// historyEntry is one generation run, appended to the history log when
+// history is enabled.
+type historyEntry struct {
+	Time         time.Time `json:"time"`
+	Repo         string    `json:"repo,omitempty"` // top level of the work tree
+	Provider     string    `json:"provider"`
+	Model        string    `json:"model"`
+	PromptTokens int       `json:"prompt_tokens"` // estimate, see estimateTokens
+	ElapsedMs    int64     `json:"elapsed_ms"`
+	Retries      int       `json:"retries"`
+	// Outcome is what became of the message: outcomeAccepted,
+	// outcomeEdited, or outcomeAborted; older entries have none.
+	Outcome       string   `json:"outcome,omitempty"`
+	Regenerations int      `json:"regenerations,omitempty"`
+	InputTokens   int      `json:"input_tokens,omitempty"`
+	OutputTokens  int      `json:"output_tokens,omitempty"`
+	CostUSD       *float64 `json:"cost_usd,omitempty"`
+}
+
+const (
+	outcomeAccepted = "accepted" // used as the model wrote it
+	outcomeEdited   = "edited"   // changed by hand before use
+	outcomeAborted  = "aborted"  // not used at all
+)
+
+// runOutcome follows the messages the model wrote during a run, so that a
+// message used in the end can be told apart from one edited by hand.
+type runOutcome struct {
+	generated     []string
+	regenerations int
+}
+
+func (o *runOutcome) add(msg string) { o.generated = append(o.generated, msg) }
+
+// regenerated adds a message asked for again, in review, the TUI, or the
+// candidate list.
+func (o *runOutcome) regenerated(msg string) {
+	o.regenerations++
+	o.add(msg)
+}
+
+// of is the outcome of using msg.
+func (o *runOutcome) of(msg string) string {
+	if slices.Contains(o.generated, msg) {
+		return outcomeAccepted
+	}
+	return outcomeEdited
+}
+
+func historyPath() string {
+	dir, _ := os.UserCacheDir()
+	return filepath.Join(dir, "commit", "history.jsonl")
+}
+
+// estimateTokens is a rough token count (about four bytes per token).
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+func appendHistory(e historyEntry) error {
+	path := historyPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func readHistory() ([]historyEntry, error) {
+	f, err := os.Open(historyPath())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e historyEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// historyStats adds up history entries, by model or by repository.
+type historyStats struct {
+	runs, retries, tokens, regenerations int
+	spent                                int // tokens reported, else estimated
+	elapsed                              int64
+	accepted, edited, aborted            int
+	cost                                 float64
+	costed                               bool // some entry had a cost
+}
+
+func (a *historyStats) add(e historyEntry) {
+	a.runs++
+	a.retries += e.Retries
+	a.tokens += e.PromptTokens
+	a.spent += cmp.Or(e.InputTokens+e.OutputTokens, e.PromptTokens)
+	a.elapsed += e.ElapsedMs
+	a.regenerations += e.Regenerations
+	switch e.Outcome {
+	case outcomeAccepted:
+		a.accepted++
+	case outcomeEdited:
+		a.edited++
+	case outcomeAborted:
+		a.aborted++
+	}
+	if e.CostUSD != nil {
+		a.cost += *e.CostUSD
+		a.costed = true
+	}
+}
+
+// rates formats the share of runs whose message was accepted as is, edited,
+// or dropped, among those that recorded it.
+func (a *historyStats) rates() string {
+	n := a.accepted + a.edited + a.aborted
+	if n == 0 {
+		return fmt.Sprintf("%6s %6s %6s", "-", "-", "-")
+	}
+	pct := func(k int) string { return fmt.Sprintf("%d%%", k*100/n) }
+	return fmt.Sprintf("%6s %6s %6s", pct(a.accepted), pct(a.edited), pct(a.aborted))
+}
+
+func (a *historyStats) costString() string {
+	if !a.costed {
+		return "-"
+	}
+	return fmt.Sprintf("$%.4f", a.cost)
+}
+
+// groupHistory adds up entries by key, and returns the keys in order.
+func groupHistory(entries []historyEntry, key func(historyEntry) string) ([]string, map[string]*historyStats) {
+	groups := map[string]*historyStats{}
+	for _, e := range entries {
+		k := key(e)
+		a := groups[k]
+		if a == nil {
+			a = &historyStats{}
+			groups[k] = a
+		}
+		a.add(e)
+	}
+	keys := slices.Collect(maps.Keys(groups))
+	slices.Sort(keys)
+	return keys, groups
+}
+
+// runStats implements `commit stats`: per model, the latency, tokens, and
+// retries of its runs and how often its messages were accepted, edited, or
+// dropped; then runs, outcomes, and spend per repository.
+func runStats() {
+	entries, err := readHistory()
+	if os.IsNotExist(err) || (err == nil && len(entries) == 0) {
+		fmt.Println("No history yet. Enable it with --history or \"history\": true in the config file.")
+		return
+	}
+	if err != nil {
+		errorf("Failed to read history: %v", err)
+		os.Exit(1)
+	}
+
+	models, byModel := groupHistory(entries, func(e historyEntry) string { return e.Model })
+	fmt.Println(header(fmt.Sprintf("%-45s %6s %12s %12s %8s %6s %6s %6s %6s %10s", "MODEL", "RUNS", "AVG LATENCY", "AVG TOKENS", "RETRIES", "ACCEPT", "EDIT", "ABORT", "REGEN", "COST")))
+	for _, m := range models {
+		a := byModel[m]
+		avg := time.Duration(a.elapsed/int64(a.runs)) * time.Millisecond
+		fmt.Printf("%-45s %6d %12s %12d %8d %s %6d %10s\n", m, a.runs, avg, a.tokens/a.runs, a.retries, a.rates(), a.regenerations, a.costString())
+	}
+
+	repos, byRepo := groupHistory(entries, func(e historyEntry) string { return cmp.Or(e.Repo, "(unknown)") })
+	fmt.Println()
+	fmt.Println(header(fmt.Sprintf("%-45s %6s %6s %6s %6s %6s %12s %10s", "REPOSITORY", "RUNS", "ACCEPT", "EDIT", "ABORT", "REGEN", "TOKENS", "COST")))
+	for _, r := range repos {
+		a := byRepo[r]
+		fmt.Printf("%-45s %6d %s %6d %12d %10s\n", r, a.runs, a.rates(), a.regenerations, a.spent, a.costString())
+	}
+}
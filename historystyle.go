@@ -0,0 +1,185 @@
+package main
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// historyStyleCommits is how many recent commits the style is learned
+	// from, and historyStyleMin how many it takes to learn anything.
+	historyStyleCommits = 200
+	historyStyleMin     = 10
+	// historyStyleTTL is how long a repository's learned style is reused
+	// before its history is read again.
+	historyStyleTTL = 24 * time.Hour
+)
+
+// historyStyle is what the recent commit subjects of a repository have in
+// common, as told to the model so messages fit in with them.
+type historyStyle struct {
+	Commits      int            `json:"commits"`
+	Types        map[string]int `json:"types"` // commits by Conventional Commits type
+	Scoped       int            `json:"scoped"`
+	Scopes       map[string]int `json:"scopes"`
+	SubjectChars int            `json:"subject_chars"` // average subject length
+	Lowercase    int            `json:"lowercase"`     // descriptions starting with a lowercase letter
+	Emoji        int            `json:"emoji"`         // subjects starting with an emoji or :shortcode:
+}
+
+// gitmojiCodeRe matches a leading gitmoji shortcode such as ":sparkles:".
+var gitmojiCodeRe = regexp.MustCompile(`^:[a-z0-9_+-]+:\s*`)
+
+// analyzeHistory measures subjects, newest first as git log lists them.
+func analyzeHistory(subjects []string) historyStyle {
+	h := historyStyle{Types: map[string]int{}, Scopes: map[string]int{}}
+	total := 0
+	for _, s := range subjects {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		h.Commits++
+		total += displayWidth(s)
+		if first, _ := utf8.DecodeRuneInString(s); unicode.Is(unicode.So, first) || gitmojiCodeRe.MatchString(s) {
+			h.Emoji++
+			s = strings.TrimLeftFunc(gitmojiCodeRe.ReplaceAllString(s, ""), func(r rune) bool {
+				return unicode.Is(unicode.So, r) || unicode.IsSpace(r) || r == '\uFE0F' // variation selector
+			})
+		}
+		desc := s
+		if m := typePrefixRe.FindStringSubmatch(s); m != nil {
+			h.Types[strings.ToLower(m[1])]++
+			if scope := strings.Trim(m[2], "()"); scope != "" {
+				h.Scoped++
+				h.Scopes[scope]++
+			}
+			desc = strings.TrimSpace(s[len(m[0]):])
+		}
+		if first, _ := utf8.DecodeRuneInString(desc); unicode.IsLower(first) {
+			h.Lowercase++
+		}
+	}
+	if h.Commits > 0 {
+		h.SubjectChars = total / h.Commits
+	}
+	return h
+}
+
+// prompt describes h as a list for the system prompt, or returns "" when
+// there is too little history to go by.
+func (h historyStyle) prompt() string {
+	if h.Commits < historyStyleMin {
+		return ""
+	}
+	percent := func(n int) int { return n * 100 / h.Commits }
+	var lines []string
+	typed := 0
+	for _, n := range h.Types {
+		typed += n
+	}
+	if percent(typed) < 20 {
+		lines = append(lines, "Subjects rarely have a type prefix.")
+	} else {
+		var common []string
+		for _, t := range byCount(h.Types, 6) {
+			common = append(common, fmt.Sprintf("%s %d%%", t, percent(h.Types[t])))
+		}
+		lines = append(lines, fmt.Sprintf("%d%% of subjects have a type prefix; the most used: %s.", percent(typed), strings.Join(common, ", ")))
+	}
+	switch {
+	case typed > 0 && h.Scoped*100/typed >= 50:
+		lines = append(lines, fmt.Sprintf("Most typed subjects have a scope. Scopes in use: %s%s.", strings.Join(byCount(h.Scopes, 12), ", "), scopeNaming(h.Scopes)))
+	case h.Scoped > 0:
+		lines = append(lines, fmt.Sprintf("Scopes are used now and then; ones in use: %s.", strings.Join(byCount(h.Scopes, 8), ", ")))
+	case typed > 0:
+		lines = append(lines, "Subjects have no scope.")
+	}
+	lines = append(lines, fmt.Sprintf("Subjects average %d characters.", h.SubjectChars))
+	switch p := percent(h.Lowercase); {
+	case p >= 80:
+		lines = append(lines, "Descriptions start with a lowercase letter.")
+	case p <= 20:
+		lines = append(lines, "Descriptions start with a capital letter.")
+	}
+	if p := percent(h.Emoji); p >= 50 {
+		lines = append(lines, "Subjects start with an emoji.")
+	} else if p == 0 {
+		lines = append(lines, "Subjects have no emoji.")
+	}
+	return "- " + strings.Join(lines, "\n- ")
+}
+
+// byCount returns the n keys of counts with the highest counts, ties broken
+// alphabetically.
+func byCount(counts map[string]int, n int) []string {
+	keys := slices.Sorted(maps.Keys(counts))
+	slices.SortStableFunc(keys, func(a, b string) int { return cmp.Compare(counts[b], counts[a]) })
+	return keys[:min(n, len(keys))]
+}
+
+// scopeNaming names the convention every scope follows, if there is one.
+func scopeNaming(scopes map[string]int) string {
+	lower, sep := true, map[rune]bool{}
+	for s := range scopes {
+		lower = lower && strings.ToLower(s) == s
+		for _, r := range s {
+			if r == '-' || r == '_' || r == '/' || r == '.' {
+				sep[r] = true
+			}
+		}
+	}
+	if !lower {
+		return ""
+	}
+	if len(sep) == 1 {
+		return fmt.Sprintf(" (lowercase, words joined with %q)", slices.Collect(maps.Keys(sep))[0])
+	}
+	return " (lowercase)"
+}
+
+func historyStyleCachePath() string {
+	dir, _ := os.UserCacheDir()
+	sum := sha256.Sum256([]byte(repo.CommonDir))
+	return filepath.Join(dir, "commit", "history-style", hex.EncodeToString(sum[:8])+".json")
+}
+
+// loadHistoryStyle returns the repository's learned style for the prompt,
+// reading the history again once the cached analysis is historyStyleTTL old.
+// Outside a repository, or with too little history, it returns "".
+func loadHistoryStyle() string {
+	if repo.CommonDir == "" {
+		return ""
+	}
+	path := historyStyleCachePath()
+	var h historyStyle
+	if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) < historyStyleTTL {
+		if data, err := os.ReadFile(path); err == nil && json.Unmarshal(data, &h) == nil {
+			return h.prompt()
+		}
+	}
+	out, err := runGit("log", "--no-merges", "-n", fmt.Sprint(historyStyleCommits), "--format=%s")
+	if err != nil {
+		debugf("Reading the history for its style: %v", err)
+		return ""
+	}
+	h = analyzeHistory(strings.Split(out, "\n"))
+	if data, err := json.Marshal(h); err == nil {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err == nil {
+			os.WriteFile(path, data, 0600)
+		}
+	}
+	debugf("Learned the style of %d recent commits", h.Commits)
+	return h.prompt()
+}
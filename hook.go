@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hookMarker identifies a prepare-commit-msg hook written by install-hook,
+// so uninstall-hook and a second install never touch anyone else's.
+const hookMarker = "# Installed by `commit install-hook`."
+
+// hookScript is the prepare-commit-msg hook. It only fills in the message
+// for a plain `git commit`: git passes a source in $2 for -m, -F, templates,
+// merges, squashes, and amends, which already have a message. A failure,
+// such as a missing API key, never blocks the commit.
+func hookScript(exe string) string {
+	return fmt.Sprintf(`#!/bin/sh
+%s Remove it with `+"`commit uninstall-hook`"+`.
+[ -z "$2" ] || exit 0
+%q -s --output-file "$1" </dev/null >/dev/null || true
+`, hookMarker, exe)
+}
+
+// hookPath is where git looks for the prepare-commit-msg hook, honoring
+// core.hooksPath.
+func hookPath() (string, error) {
+	path, err := gitPath("hooks/prepare-commit-msg")
+	if err != nil {
+		return "", fmt.Errorf("not in a git repository: %w", err)
+	}
+	return path, nil
+}
+
+// runInstallHook implements `commit install-hook [--force]`. An existing
+// hook of someone else's is left alone unless --force, which moves it to
+// prepare-commit-msg.bak.
+func runInstallHook(args []string) {
+	fs := flag.NewFlagSet("install-hook", flag.ExitOnError)
+	force := fs.Bool("force", false, "Replace an existing prepare-commit-msg hook, keeping it as prepare-commit-msg.bak")
+	fs.Parse(args)
+
+	path, err := hookPath()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fatalf("Failed to locate this program: %v", err)
+	}
+	if existing, err := os.ReadFile(path); err == nil && !strings.Contains(string(existing), hookMarker) {
+		if !*force {
+			fatalf("%s already exists and wasn't installed by commit; pass --force to replace it (it will be kept as %s.bak).", path, filepath.Base(path))
+		}
+		if err := os.Rename(path, path+".bak"); err != nil {
+			fatalf("Failed to back up the existing hook: %v", err)
+		}
+		infof("Moved the existing hook to %s.bak.", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fatalf("%v", err)
+	}
+	if err := os.WriteFile(path, []byte(hookScript(exe)), 0755); err != nil {
+		fatalf("Failed to write the hook: %v", err)
+	}
+	fmt.Println(success("Installed " + path + ": git commit now starts with a generated message."))
+}
+
+// runUninstallHook implements `commit uninstall-hook`, restoring a hook
+// that install-hook --force moved aside.
+func runUninstallHook(args []string) {
+	fs := flag.NewFlagSet("uninstall-hook", flag.ExitOnError)
+	fs.Parse(args)
+
+	path, err := hookPath()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	existing, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Println("No prepare-commit-msg hook is installed.")
+		return
+	}
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if !strings.Contains(string(existing), hookMarker) {
+		fatalf("%s wasn't installed by commit; leaving it alone.", path)
+	}
+	if err := os.Remove(path); err != nil {
+		fatalf("Failed to remove the hook: %v", err)
+	}
+	if _, err := os.Stat(path + ".bak"); err == nil {
+		if err := os.Rename(path+".bak", path); err != nil {
+			fatalf("Failed to restore %s.bak: %v", path, err)
+		}
+		fmt.Println(success("Removed the hook and restored the previous one from " + path + ".bak."))
+		return
+	}
+	fmt.Println(success("Removed " + path + "."))
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// apiKeyPages are where each provider hands out API keys.
+var apiKeyPages = map[string]string{
+	"googleai":  "https://aistudio.google.com/apikey",
+	"openai":    "https://platform.openai.com/api-keys",
+	"anthropic": "https://console.anthropic.com/settings/keys",
+}
+
+// initModelChoices caps how many models commit init lists by number.
+const initModelChoices = 9
+
+// applyAPIKeys exports the keys commit init saved, for the providers whose
+// variables aren't set already: the environment wins, as with .env files.
+func applyAPIKeys(keys map[string]string) {
+	for provider, key := range keys {
+		envs := generator.ProviderKeyEnv[provider]
+		if _, existing := generator.APIKeyFor(provider); len(envs) > 0 && existing == "" && key != "" {
+			os.Setenv(envs[0], key)
+		}
+	}
+}
+
+// applyAPIKeyEnv exports the keys found in the variables the config file
+// names, by provider, for the providers whose own variables aren't set. It
+// runs before applyAPIKeys, so such a variable wins over a saved key.
+func applyAPIKeyEnv(names map[string]string) {
+	for provider, name := range names {
+		envs := generator.ProviderKeyEnv[provider]
+		if _, existing := generator.APIKeyFor(provider); len(envs) > 0 && existing == "" && os.Getenv(name) != "" {
+			os.Setenv(envs[0], os.Getenv(name))
+		}
+	}
+}
+
+// readAnswer reads one line of input. It ends the run when the input is
+// used up rather than asking again forever.
+func readAnswer(reader *bufio.Reader) string {
+	input, err := reader.ReadString('\n')
+	if err != nil && input == "" {
+		fmt.Println()
+		fatalf("No answer given.")
+	}
+	return strings.TrimSpace(input)
+}
+
+// runInit implements `commit init`: it asks for the provider, its API key,
+// the default model, the style, and what to do with messages, saves the
+// answers to the config file, and tries the model with a test call.
+func runInit(ctx context.Context) {
+	if !isTerminal(os.Stdin) {
+		fatalf("commit init asks questions; run it in a terminal")
+	}
+	reader := bufio.NewReader(os.Stdin)
+	cfg := loadConfig()
+	fmt.Println(header("Let's set up commit."))
+
+	cfg.Provider = askProvider(reader)
+	if cfg.Provider != "ollama" {
+		cfg.APIKeys = askAPIKey(reader, cfg.Provider, cfg.APIKeys)
+	}
+	cfg.Model = askModel(ctx, reader, cfg.Provider, cfg.Model)
+	cfg.Style = askStyle(reader)
+	cfg.Action = askAction(reader)
+	if cfg.Action == ActionClipboard {
+		cfg.ClipFormat = askClipFormat(reader)
+	}
+	saveConfig(cfg)
+	fmt.Printf("\nSaved to %s.\n", configPath())
+
+	if cfg.Provider == "ollama" {
+		if err := checkOllama(ctx, cfg.Model); err != nil {
+			errorf("%v", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("Trying %s... ", cfg.Model)
+	c := pingModel(cfg.Model)
+	if !c.ok {
+		fmt.Println(failure("✗"))
+		errorf("%s; fix it and run commit init again, or check with commit doctor --live.", c.hint)
+		os.Exit(1)
+	}
+	fmt.Println(success("✓") + " (" + c.info + ")")
+	fmt.Println("All set: run commit in a repository with changes.")
+}
+
+func askProvider(reader *bufio.Reader) string {
+	names := generator.ProviderNames()
+	fmt.Println("\n" + header("Provider:"))
+	for i, p := range names {
+		note := ""
+		switch name, key := generator.APIKeyFor(p); {
+		case p == "ollama" && generator.OllamaReachable():
+			note = "running at " + generator.OllamaAddress()
+		case p == "ollama":
+			note = "local, no API key; start ollama serve first"
+		case key != "":
+			note = "key found in " + name
+		}
+		if note != "" {
+			note = paint(ansiDim, "("+note+")")
+		}
+		fmt.Printf("  %d) %-10s %s\n", i+1, p, note)
+	}
+	for {
+		fmt.Printf("Choose (1-%d): ", len(names))
+		if n, err := strconv.Atoi(readAnswer(reader)); err == nil && n >= 1 && n <= len(names) {
+			return names[n-1]
+		}
+		fmt.Println(warn(fmt.Sprintf("Invalid choice. Enter a number from 1 to %d.", len(names))))
+	}
+}
+
+// askAPIKey asks for provider's key unless the environment has one, and
+// returns keys with it saved. The key is exported for the test call too.
+func askAPIKey(reader *bufio.Reader, provider string, keys map[string]string) map[string]string {
+	name, key := generator.APIKeyFor(provider)
+	saved := keys[provider]
+	if key != "" && key != saved {
+		fmt.Printf("\nUsing the API key in %s.\n", name)
+		return keys
+	}
+	fmt.Println("\n" + header("API key:"))
+	if page := apiKeyPages[provider]; page != "" {
+		fmt.Println("Get one at " + page)
+	}
+	for {
+		if saved != "" {
+			fmt.Print("Paste a new key, or press Enter to keep the saved one: ")
+		} else {
+			fmt.Print("Paste your key (it isn't shown): ")
+		}
+		var input string
+		if data, err := term.ReadPassword(os.Stdin.Fd()); err == nil {
+			fmt.Println()
+			input = strings.TrimSpace(string(data))
+		} else {
+			input = readAnswer(reader)
+		}
+		switch {
+		case input == "" && saved != "":
+			return keys
+		case input == "":
+			fmt.Println(warn("A key is needed for " + provider + "."))
+			continue
+		}
+		if keys == nil {
+			keys = map[string]string{}
+		}
+		keys[provider] = input
+		if envs := generator.ProviderKeyEnv[provider]; len(envs) > 0 {
+			os.Setenv(envs[0], input)
+		}
+		return keys
+	}
+}
+
+// askModel offers provider's models, the first few by number, and returns
+// the one chosen; Enter keeps current when it belongs to provider, else the
+// provider's default.
+func askModel(ctx context.Context, reader *bufio.Reader, provider, current string) string {
+	def := current
+	if generator.ProviderOf(current) != provider {
+		def, _, _ = generator.ResolveModel(provider, "", false)
+	}
+	models, _, err := listModels(ctx, provider)
+	if err != nil {
+		debugf("Listing models: %v", err)
+	}
+	fmt.Println("\n" + header("Default model:"))
+	shown := models[:min(len(models), initModelChoices)]
+	for i, m := range shown {
+		fmt.Printf("  %d) %s\n", i+1, m)
+	}
+	for {
+		fmt.Printf("Choose a number or type a name [%s]: ", def)
+		input := readAnswer(reader)
+		if input == "" {
+			return def
+		}
+		if n, err := strconv.Atoi(input); err == nil {
+			if n >= 1 && n <= len(shown) {
+				return shown[n-1]
+			}
+			fmt.Println(warn("Invalid choice."))
+			continue
+		}
+		if !strings.Contains(input, "/") {
+			input = provider + "/" + input
+		}
+		return input
+	}
+}
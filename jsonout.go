@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// messageJSON is the --json form of a generated message, for scripts and
+// editor plugins. Message is the full text as it would be committed.
+type messageJSON struct {
+	Type     string `json:"type"`
+	Scope    string `json:"scope"`
+	Subject  string `json:"subject"`
+	Body     string `json:"body"`
+	Breaking bool   `json:"breaking"`
+	Message  string `json:"message"`
+	// Usage is the tokens and rough cost of the generation; it is missing
+	// when no model was called, e.g. for a cached or --offline message.
+	Usage *usageJSON `json:"usage,omitempty"`
+}
+
+// structuredMessage splits msg into its Conventional Commits parts. A
+// leading emoji, as --emoji adds, is skipped; without a type prefix the
+// whole subject line is the subject.
+func structuredMessage(msg string) messageJSON {
+	subject, rest := generator.SplitMessage(msg)
+	out := messageJSON{Subject: strings.TrimSpace(subject), Body: strings.TrimSpace(rest), Message: msg}
+	line := out.Subject
+	if first, after, ok := strings.Cut(line, " "); ok && !strings.ContainsFunc(first, isASCIIAlnum) {
+		line = after
+	}
+	if m := typePrefixRe.FindStringSubmatch(line); m != nil {
+		out.Type = m[1]
+		out.Scope = strings.Trim(m[2], "()")
+		out.Breaking = m[3] == "!"
+		out.Subject = strings.TrimSpace(line[len(m[0]):])
+	}
+	out.Breaking = out.Breaking || breakingFooterRe.MatchString(out.Body)
+	return out
+}
+
+func isASCIIAlnum(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// writeMessageJSON prints msg and the usage of its generation as one line
+// of JSON.
+func writeMessageJSON(w io.Writer, msg string, usage *usageJSON) error {
+	out := structuredMessage(msg)
+	out.Usage = usage
+	return json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// maxLintRetries bounds how often a message that fails --lint is
+// regenerated before it is kept with a warning.
+const maxLintRetries = 2
+
+// lintBodyWidth is the longest body line lint allows unless told otherwise,
+// the width --long wraps at.
+const lintBodyWidth = 72
+
+// lintRules are the checks lintMessage applies, the same rules generation
+// steers the model towards and post-processing enforces.
+type lintRules struct {
+	MaxSubject  int
+	Types       []string // allowed types; empty skips the Conventional Commits checks
+	Case        generator.SubjectCase
+	AllowPeriod bool
+	BodyWidth   int                   // longest body line; 0 allows any
+	ReleaseTool generator.ReleaseTool // also check what the release tool parses, see releaseProblems
+	Emoji       map[string]string     // emoji that may come before the type, besides gitmojis
+}
+
+// lintMessage returns one line per rule the message breaks. Comment lines, as
+// left by git in a commit-msg hook, are ignored.
+func lintMessage(msg string, r lintRules) []string {
+	var lines []string
+	for _, line := range strings.Split(gitctx.NormalizeNewlines(msg), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	msg = strings.TrimSpace(strings.Join(lines, "\n"))
+	if msg == "" {
+		return []string{"message is empty"}
+	}
+
+	var problems []string
+	subject, rest := generator.SplitMessage(msg)
+	if n := displayWidth(subject); r.MaxSubject > 0 && n > r.MaxSubject {
+		problems = append(problems, fmt.Sprintf("subject is %d characters (limit %d)", n, r.MaxSubject))
+	}
+	if rest != "" && !strings.HasPrefix(rest, "\n\n") {
+		problems = append(problems, "subject must be followed by a blank line")
+	}
+	if !r.AllowPeriod && stripPeriod(subject) != strings.TrimRight(subject, " ") {
+		problems = append(problems, "subject ends with a period")
+	}
+
+	if r.BodyWidth > 0 {
+		for i, line := range strings.Split(strings.TrimLeft(rest, "\n"), "\n") {
+			// Indented lines and lone words such as URLs can't be wrapped.
+			if n := displayWidth(line); n > r.BodyWidth && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") && strings.Contains(line, " ") {
+				problems = append(problems, fmt.Sprintf("body line %d is %d characters (limit %d)", i+1, n, r.BodyWidth))
+			}
+		}
+	}
+
+	_, desc := trimEmoji(subject, r.Emoji)
+	if len(r.Types) > 0 {
+		m := typePrefixRe.FindStringSubmatch(desc)
+		if m == nil {
+			problems = append(problems, `subject is not in "type(scope): description" form`)
+		} else {
+			if !slices.Contains(r.Types, m[1]) {
+				problems = append(problems, fmt.Sprintf("type %q is not one of %s", m[1], strings.Join(r.Types, ", ")))
+			}
+			desc = desc[len(m[0]):]
+			if !strings.HasPrefix(desc, " ") {
+				problems = append(problems, `missing space after ":"`)
+			}
+		}
+	}
+	desc = strings.TrimLeft(desc, " ")
+	if desc == "" {
+		problems = append(problems, "subject has no description")
+	} else if r.Case == generator.CaseLower || r.Case == generator.CaseSentence {
+		if applySubjectCase(desc, r.Case) != desc {
+			problems = append(problems, fmt.Sprintf("subject description should be %s case", r.Case))
+		}
+	}
+	return append(problems, releaseProblems(msg, r.ReleaseTool)...)
+}
+
+// fixMessage corrects what lintMessage flags that needs no rewording: the
+// type's case and the space after its colon, the blank line after the
+// subject, a trailing period, the description's case, and long body lines.
+// A leading emoji is kept in front of the fixed subject.
+func fixMessage(msg string, r lintRules) string {
+	subject, rest := generator.SplitMessage(strings.TrimSpace(msg))
+	prefix, subject := trimEmoji(subject, r.Emoji)
+	if m := typePrefixRe.FindStringSubmatchIndex(subject); m != nil && len(r.Types) > 0 {
+		subject = strings.ToLower(subject[:m[3]]) + subject[m[3]:m[1]] + " " + strings.TrimLeft(subject[m[1]:], " ")
+	}
+	if !r.AllowPeriod {
+		subject = stripPeriod(subject)
+	}
+	if rest != "" && !strings.HasPrefix(rest, "\n\n") {
+		rest = "\n" + rest
+	}
+	return wrapBody(generator.JoinMessage(prefix+applySubjectCase(subject, r.Case), rest), r.BodyWidth)
+}
+
+// styleTypes returns the types lint accepts for style, or nil for the
+// styles whose subjects have no type prefix.
+func styleTypes(style generator.Style, cfg Config) []string {
+	switch style {
+	case generator.StyleConventional, "":
+		if len(cfg.LintTypes) > 0 {
+			return cfg.LintTypes
+		}
+		return generator.CommitTypes
+	case generator.StyleAngular:
+		return generator.AngularTypes
+	}
+	return nil
+}
+
+// generateLinted is the --lint stage after generation: the message gets
+// fixMessage's fixes, and one that still breaks a rule once post-processed
+// is regenerated up to maxLintRetries times, after which the last one is
+// kept with a warning.
+func generateLinted(generate func() (generator.Suggestion, error), post postProcess, r lintRules) (generator.Suggestion, error) {
+	// Text the user adds around the subject isn't the model's to fix, and
+	// scrubbing warns once the message is final.
+	post.Prepend, post.Append, post.Emoji, post.Scrub = "", "", nil, nil
+	for attempt := 0; ; attempt++ {
+		sg, err := generate()
+		if err != nil {
+			return sg, err
+		}
+		sg.Message = fixMessage(sg.Message, r)
+		problems := lintMessage(post.apply(sg.Message), r)
+		switch {
+		case len(problems) == 0:
+			debugf("Message passed lint")
+			return sg, nil
+		case attempt == maxLintRetries:
+			warnf("The message still breaks the lint rules: %s.", strings.Join(problems, "; "))
+			return sg, nil
+		}
+		warnf("Rejected a message that breaks the lint rules (%s); regenerating.", strings.Join(problems, "; "))
+	}
+}
+
+// runLint implements `commit lint [--fix] [--message MSG | FILE | -]`. It exits 1 when
+// the message breaks any rule, so it can serve as a commit-msg hook.
+func runLint(args []string, cfg Config) {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	message := fs.String("message", "", "Message to check (default: read FILE, or stdin)")
+	style := fs.String("style", string(cfg.Style), "conventional and angular check the type prefix; simple, detailed, and gitmoji skip it")
+	maxSubject := fs.Int("max-subject", cmp.Or(cfg.MaxSubjectLength, maxSubjectLen), "Maximum subject length (0 = no limit)")
+	subjectCase := fs.String("subject-case", string(generator.CaseLower), "Required description case: lower, sentence, or preserve")
+	allowPeriod := fs.Bool("keep-period", false, "Allow a trailing period on the subject line")
+	bodyWidth := fs.Int("body-width", lintBodyWidth, "Maximum body line length (0 = no limit)")
+	fix := fs.Bool("fix", false, "Fix what needs no rewording (type case, blank line, period, case, wrapping) and write the message back: to FILE, else to stdout")
+	releaseTool := fs.String("release-tool", cfg.ReleaseTool, "Also check the footers release-please or semantic-release parse")
+	fs.Parse(args)
+
+	c, err := generator.ParseSubjectCase(*subjectCase)
+	if err != nil {
+		errorf("%v", err)
+		os.Exit(2)
+	}
+	var rt generator.ReleaseTool
+	if *releaseTool != "" {
+		if rt, err = generator.ParseReleaseTool(*releaseTool); err != nil {
+			errorf("%v", err)
+			os.Exit(2)
+		}
+	}
+
+	msg, path := *message, ""
+	if msg == "" {
+		var data []byte
+		if path = fs.Arg(0); path != "" && path != "-" {
+			data, err = os.ReadFile(path)
+		} else {
+			data, err = io.ReadAll(os.Stdin)
+		}
+		if err != nil {
+			errorf("Failed to read message: %v", err)
+			os.Exit(2)
+		}
+		msg = string(data)
+	}
+
+	emoji, _ := emojiMap(cfg.Emoji, nil)
+	rules := lintRules{MaxSubject: *maxSubject, Types: styleTypes(generator.Style(*style), cfg), Case: c, AllowPeriod: *allowPeriod, BodyWidth: *bodyWidth, ReleaseTool: rt, Emoji: emoji}
+	verdict := os.Stdout
+	if *fix {
+		// git's comments, and a verbose commit's diff after them, are kept
+		// as they are.
+		text, comments := msg, ""
+		if i := strings.Index("\n"+msg, "\n#"); i >= 0 {
+			text, comments = msg[:i], msg[i:]
+		}
+		msg = fixMessage(text, rules) + "\n"
+		if comments != "" {
+			msg += "\n" + comments
+		}
+		if path == "" || path == "-" {
+			fmt.Print(msg)
+			verdict = os.Stderr
+		} else if err := os.WriteFile(path, []byte(msg), 0o644); err != nil {
+			errorf("Failed to write message: %v", err)
+			os.Exit(2)
+		}
+	}
+
+	problems := lintMessage(msg, rules)
+	if len(problems) == 0 {
+		fmt.Fprintln(verdict, success("✓ Message looks good."))
+		return
+	}
+	for _, p := range problems {
+		fmt.Fprintln(os.Stderr, failure("✗ "+p))
+	}
+	os.Exit(1)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+func TestLintCommitText(t *testing.T) {
+	rules := lintRules{MaxSubject: 72, Types: styleTypes(generator.StyleDetailed, Config{}), BodyWidth: lintBodyWidth}
+	msg := "Cache model responses\nRepeated runs on the same diff reuse the last message."
+
+	// A detailed message keeps its description on the line after the title
+	// until commitText adds the blank line git records.
+	if problems := lintMessage(msg, rules); !strings.Contains(strings.Join(problems, "; "), "blank line") {
+		t.Errorf("lintMessage(%q) = %q, want a missing blank line", msg, problems)
+	}
+	if problems := lintMessage(commitText(msg, generator.StyleDetailed), rules); len(problems) > 0 {
+		t.Errorf("lintMessage(commitText(%q)) = %q, want no problems", msg, problems)
+	}
+}
+
+func TestLintEmojiSubject(t *testing.T) {
+	rules := lintRules{MaxSubject: 72, Types: generator.CommitTypes, Case: generator.CaseLower, BodyWidth: lintBodyWidth, Emoji: map[string]string{"feat": "🚀"}}
+	for _, msg := range []string{"✨ feat: add caching", "♻️ refactor(api): split handlers", "♻ refactor: drop the selector", "🚀 feat: a custom emoji"} {
+		if problems := lintMessage(msg, rules); len(problems) > 0 {
+			t.Errorf("lintMessage(%q) = %q, want no problems", msg, problems)
+		}
+	}
+	if problems := lintMessage("🦄 feat: an unknown emoji", rules); len(problems) == 0 {
+		t.Errorf("lintMessage accepted a subject starting with an unknown emoji")
+	}
+
+	tests := []struct{ msg, want string }{
+		{"✨ Feat:Add caching.", "✨ feat: add caching"},
+		{"🐛 fix(api): Handle nil\nThe handler crashed.", "🐛 fix(api): handle nil\n\nThe handler crashed."},
+		{"Fix:Handle nil", "fix: handle nil"},
+	}
+	for _, tt := range tests {
+		if got := fixMessage(tt.msg, rules); got != tt.want {
+			t.Errorf("fixMessage(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
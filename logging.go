@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LogLevel orders diagnostics printed to stderr. The commit message itself
+// and interactive prompts are not log output and always go to stdout.
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota // prompts, raw responses, provider selection
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// logLevel is set once at startup from --log-level or COMMIT_LOG_LEVEL.
+var logLevel = LevelInfo
+
+func parseLogLevel(s string) (LogLevel, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q (want debug, info, warn, or error)", s)
+}
+
+func logAt(level LogLevel, msg string) {
+	if level < logLevel {
+		return
+	}
+	switch level {
+	case LevelDebug:
+		msg = paint(ansiDim, "debug: "+msg)
+	case LevelWarn:
+		msg = warn(msg)
+	case LevelError:
+		msg = failure(msg)
+	}
+	fmt.Fprintln(os.Stderr, msg)
+}
+
+func debugf(format string, args ...any) { logAt(LevelDebug, fmt.Sprintf(format, args...)) }
+func infof(format string, args ...any)  { logAt(LevelInfo, fmt.Sprintf(format, args...)) }
+func warnf(format string, args ...any)  { logAt(LevelWarn, fmt.Sprintf(format, args...)) }
+func errorf(format string, args ...any) { logAt(LevelError, fmt.Sprintf(format, args...)) }
+
+// fatalf logs an error and exits with status 1.
+func fatalf(format string, args ...any) {
+	errorf(format, args...)
+	os.Exit(1)
+}
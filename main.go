@@ -2,31 +2,29 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"io/fs"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/atotto/clipboard"
-	"github.com/firebase/genkit/go/ai"
-	"github.com/firebase/genkit/go/genkit"
-	"github.com/firebase/genkit/go/plugins/googlegenai"
-)
-
-type Style string
-
-const (
-	StyleConventional Style = "conventional" // fix: message
-	StyleSimple       Style = "simple"       // message
-	StyleDetailed     Style = "detailed"     // git commit -m "title" -m "description"
+	"github.com/BurntSushi/toml"
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
 )
 
 type Action string
@@ -36,6 +34,19 @@ const (
 	ActionClipboard Action = "clipboard" // copy to clipboard only
 )
 
+// effectiveAction settles what is done with the message: a commit when a
+// flag asks for one, else a copy when output flags say where the message
+// goes, else the configured action.
+func effectiveAction(configured Action, explicitSinks, commit bool) Action {
+	switch {
+	case commit:
+		return ActionCommit
+	case explicitSinks:
+		return ActionClipboard
+	}
+	return configured
+}
+
 type ClipFormat string
 
 const (
@@ -44,16 +55,141 @@ const (
 )
 
 type Config struct {
-	Style      Style      `json:"style"`
-	Action     Action     `json:"action"`
-	ClipFormat ClipFormat `json:"clip_format"`
+	Style      generator.Style `json:"style"`
+	Action     Action          `json:"action"`
+	ClipFormat ClipFormat      `json:"clip_format"`
+	PromptURL  string          `json:"prompt_url,omitempty"` // shared system prompt, see loadSharedPrompt
+	History    bool            `json:"history,omitempty"`    // record per-run metrics, see `commit stats`
+	Mood       generator.Mood  `json:"mood,omitempty"`
+	PreCommit  string          `json:"pre_commit_command,omitempty"` // run by --pre-commit-run, default "pre-commit run"
+	LintTypes  []string        `json:"lint_types,omitempty"`         // types accepted by `commit lint`
+	// Issue tracker used to add ticket context, see fetchTicket.
+	Tracker      string `json:"tracker,omitempty"` // jira or github
+	TrackerURL   string `json:"tracker_url,omitempty"`
+	TrackerToken string `json:"tracker_token,omitempty"`
+	// Templates maps a commit type to the body structure used for it.
+	Templates map[string]string `json:"templates,omitempty"`
+	// Scopes maps path patterns such as "services/auth/**" to a scope.
+	Scopes map[string]string `json:"scopes,omitempty"`
+	// PromptAppend is added to the end of the system prompt, see
+	// promptAppendix.
+	PromptAppend string `json:"prompt_append,omitempty"`
+	// StyleGuide is a file with the team's commit conventions, see
+	// loadStyleGuide.
+	StyleGuide string `json:"style_guide,omitempty"`
+	// BranchTypes maps branch prefixes such as "feat/" to a commit type for
+	// --auto-type-from-branch, on top of defaultBranchTypes.
+	BranchTypes map[string]string `json:"branch_types,omitempty"`
+	// Provider and Model are used when --provider and --model aren't given.
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// WIPPrefix marks --wip subjects, "wip:" by default.
+	WIPPrefix string `json:"wip_prefix,omitempty"`
+	// CloseKeyword turns on issue closing lines, like --close-keyword.
+	CloseKeyword string `json:"close_keyword,omitempty"`
+	// Buckets maps path patterns to diff buckets for --bucket-diff, over
+	// generator.DefaultBucket.
+	Buckets map[string]string `json:"buckets,omitempty"`
+	// NoLog leaves recent commits out of the prompt, like --no-log.
+	NoLog bool `json:"no_log,omitempty"`
+	// OSC52 copies through the terminal, like --osc52.
+	OSC52 bool `json:"osc52,omitempty"`
+	// Emoji maps commit types to the emoji --emoji adds, over gitmojis.
+	Emoji map[string]string `json:"emoji,omitempty"`
+	// SensitivePaths adds to defaultSensitivePaths.
+	SensitivePaths []string `json:"sensitive_paths,omitempty"`
+	// RedactPatterns are regular expressions redacted from diffs before they
+	// are sent, on top of secretPatterns, and scrubbed from generated
+	// messages, on top of absolute paths and secret-looking tokens.
+	RedactPatterns []string `json:"redact_patterns,omitempty"`
+	// ReleaseTool is the default for --release-tool.
+	ReleaseTool string `json:"release_tool,omitempty"`
+	// TokenBudget and MaxFileTokens are the defaults for --token-budget and
+	// --max-file-tokens.
+	TokenBudget   int `json:"token_budget,omitempty"`
+	MaxFileTokens int `json:"max_file_tokens,omitempty"`
+	// StyleTemplate is the default for --style-template.
+	StyleTemplate string `json:"style_template,omitempty"`
+	// PromptProfile is the default for --prompt-profile.
+	PromptProfile string `json:"prompt_profile,omitempty"`
+	// ScopeFrom is the default for --scope-from.
+	ScopeFrom string `json:"scope_from,omitempty"`
+	// ExcludePaths are left out of the diff and status sent to the model,
+	// on top of the repository's .commitignore.
+	ExcludePaths []string `json:"exclude_paths,omitempty"`
+	// FallbackModels are tried in order when generating with the chosen
+	// model fails, see withFallback.
+	FallbackModels []string `json:"fallback_models,omitempty"`
+	// SplitBy is the default for --split-by.
+	SplitBy string `json:"split_by,omitempty"`
+	// TicketPattern finds a ticket ID in the branch name, which is written
+	// into the message at TicketPosition, see ticketRef.
+	TicketPattern  string `json:"ticket_pattern,omitempty"`
+	TicketPosition string `json:"ticket_position,omitempty"`
+	// Language is the default for --lang.
+	Language string `json:"lang,omitempty"`
+	// Lint turns on --lint for every generated message.
+	Lint bool `json:"lint,omitempty"`
+	// BlockOnSecret turns on --block-on-secret for every run.
+	BlockOnSecret bool `json:"block_on_secret,omitempty"`
+	// APIKeys are the keys commit init saved, by provider. A key in the
+	// environment wins, see applyAPIKeys.
+	APIKeys map[string]string `json:"api_keys,omitempty"`
+	// NoHistoryStyle stops matching the style of recent commits, like
+	// --no-history-style.
+	NoHistoryStyle bool `json:"no_history_style,omitempty"`
+	// Temperature, TopP, and MaxOutputTokens are the defaults for
+	// --temperature, --top-p, and --max-output-tokens.
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"top_p,omitempty"`
+	MaxOutputTokens int      `json:"max_output_tokens,omitempty"`
+	// Proxy, CABundle, and BaseURLs adapt the requests to a corporate
+	// network, see applyNetwork: a proxy URL, a PEM file of extra root CAs,
+	// and API base URLs by provider.
+	Proxy    string            `json:"proxy,omitempty"`
+	CABundle string            `json:"ca_bundle,omitempty"`
+	BaseURLs map[string]string `json:"base_urls,omitempty"`
+	// NoUntrackedContent lists the untracked files of a work tree
+	// description by name only, see --no-untracked-content.
+	NoUntrackedContent bool `json:"no_untracked_content,omitempty"`
+	// Signoff adds a Signed-off-by trailer to every commit made, like
+	// --signoff, for projects that require the DCO.
+	Signoff bool `json:"signoff,omitempty"`
+	// Trailers are added to every commit message made, after any from
+	// --trailer; see withTrailers.
+	Trailers []string `json:"trailers,omitempty"`
+	// Exemplars are commits whose messages are shown to the model as
+	// examples, see exemplarExamples; `commit exemplar add` keeps more in
+	// git config.
+	Exemplars []string `json:"exemplars,omitempty"`
+	// StructuredOutput asks for the message as JSON parts, like
+	// --structured.
+	StructuredOutput bool `json:"structured_output,omitempty"`
+	// MaxSubjectLength is the default for --max-subject-length.
+	MaxSubjectLength int `json:"max_subject_length,omitempty"`
+	// Clipboard set to false never touches the clipboard, like
+	// --no-clipboard.
+	Clipboard *bool `json:"clipboard,omitempty"`
+	// APIKeyEnv names, by provider, a variable to read the API key from
+	// when the provider's usual ones aren't set, see applyAPIKeyEnv.
+	APIKeyEnv map[string]string `json:"api_key_env,omitempty"`
 }
 
-// const MODEL = "googleai/gemini-3-flash-preview"
-// const MODEL = "googleai/gemini-3.1-pro-preview"
-const MODEL = "googleai/gemini-3.1-flash-lite-preview"
-
+// configPath is the config file, commit/config.toml in $XDG_CONFIG_HOME or
+// ~/.config. Its keys are the JSON names of Config's fields.
 func configPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "commit", "config.toml")
+}
+
+// legacyConfigPath is where earlier versions kept the config, as JSON in the
+// cache directory. It is read while there is no config.toml; the first save
+// writes its settings there.
+func legacyConfigPath() string {
 	dir, _ := os.UserCacheDir()
 	return filepath.Join(dir, "commit", "config.json")
 }
@@ -61,89 +197,245 @@ func configPath() string {
 func loadConfig() Config {
 	var c Config
 	data, err := os.ReadFile(configPath())
-	if err != nil {
+	if errors.Is(err, fs.ErrNotExist) {
+		if data, err = os.ReadFile(legacyConfigPath()); err == nil {
+			json.Unmarshal(data, &c)
+		}
 		return c
 	}
-	json.Unmarshal(data, &c)
+	// Decoding through JSON gives the TOML file the same keys as the old
+	// config.json and .commitrc.
+	var keys map[string]any
+	if err == nil {
+		err = toml.Unmarshal(data, &keys)
+	}
+	if err == nil {
+		data, _ = json.Marshal(keys)
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil {
+		warnf("Ignoring %s: %v", configPath(), err)
+		return Config{}
+	}
 	return c
 }
 
 func saveConfig(c Config) {
 	path := configPath()
 	os.MkdirAll(filepath.Dir(path), 0700)
-	data, _ := json.MarshalIndent(c, "", "  ")
-	os.WriteFile(path, data, 0600)
+	var keys map[string]any
+	data, _ := json.Marshal(c)
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	d.Decode(&keys)
+	var b bytes.Buffer
+	if err := toml.NewEncoder(&b).Encode(tomlValues(keys)); err != nil {
+		warnf("Failed to save %s: %v", path, err)
+		return
+	}
+	os.WriteFile(path, b.Bytes(), 0600)
+}
+
+// tomlValues turns the numbers of decoded JSON into integers where they are
+// whole, so the TOML file says 8000 rather than 8000.0 or "8000".
+func tomlValues(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for k, e := range v {
+			v[k] = tomlValues(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = tomlValues(e)
+		}
+	}
+	return v
+}
+
+// runConfig implements `commit config [path]`: the config file, or with
+// path only where it is.
+func runConfig(args []string) {
+	path := configPath()
+	switch {
+	case len(args) == 1 && args[0] == "path":
+		fmt.Println(path)
+		return
+	case len(args) > 0:
+		fatalf("usage: commit config [path]")
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		if data, err = os.ReadFile(legacyConfigPath()); err == nil {
+			path = legacyConfigPath()
+		} else {
+			infof("No config file at %s yet; the first run creates it.", path)
+			return
+		}
+	}
+	if err != nil {
+		fatalf("%v", err)
+	}
+	fmt.Printf("%s\n%s\n", header(path), strings.TrimSpace(string(data)))
 }
 
-func askStyle(reader *bufio.Reader) Style {
-	fmt.Println("\nCommit message style:")
+func askStyle(reader *bufio.Reader) generator.Style {
+	fmt.Println("\n" + header("Commit message style:"))
 	fmt.Println("  1) Conventional  (fix: add validation)")
 	fmt.Println("  2) Simple        (add validation)")
 	fmt.Println("  3) Detailed      (title + description)")
+	fmt.Println("  4) Gitmoji       (✨ feat: add validation)")
+	fmt.Println("  5) Angular       (feat(forms): add validation)")
 	for {
-		fmt.Print("Choose (1-3): ")
-		input, _ := reader.ReadString('\n')
-		switch strings.TrimSpace(input) {
+		fmt.Print("Choose (1-5): ")
+		switch readAnswer(reader) {
 		case "1":
-			return StyleConventional
+			return generator.StyleConventional
 		case "2":
-			return StyleSimple
+			return generator.StyleSimple
 		case "3":
-			return StyleDetailed
+			return generator.StyleDetailed
+		case "4":
+			return generator.StyleGitmoji
+		case "5":
+			return generator.StyleAngular
 		default:
-			fmt.Println("Invalid choice. Enter 1, 2, or 3.")
+			fmt.Println(warn("Invalid choice. Enter 1, 2, 3, 4, or 5."))
 		}
 	}
 }
 
 func askAction(reader *bufio.Reader) Action {
-	fmt.Println("\nAfter generating the commit message:")
+	fmt.Println("\n" + header("After generating the commit message:"))
 	fmt.Println("  1) Run commit  (git add + git commit automatically)")
 	fmt.Println("  2) Copy only   (copy to clipboard)")
 	for {
 		fmt.Print("Choose (1-2): ")
-		input, _ := reader.ReadString('\n')
-		switch strings.TrimSpace(input) {
+		switch readAnswer(reader) {
 		case "1":
 			return ActionCommit
 		case "2":
 			return ActionClipboard
 		default:
-			fmt.Println("Invalid choice. Enter 1 or 2.")
+			fmt.Println(warn("Invalid choice. Enter 1 or 2."))
 		}
 	}
 }
 
 func askClipFormat(reader *bufio.Reader) ClipFormat {
-	fmt.Println("\nClipboard copy format:")
+	fmt.Println("\n" + header("Clipboard copy format:"))
 	fmt.Println("  1) Message only  (fix: add validation)")
 	fmt.Println("  2) Command       (git commit -m \"fix: add validation\")")
 	for {
 		fmt.Print("Choose (1-2): ")
-		input, _ := reader.ReadString('\n')
-		switch strings.TrimSpace(input) {
+		switch readAnswer(reader) {
 		case "1":
 			return ClipFormatMessage
 		case "2":
 			return ClipFormatCommand
 		default:
-			fmt.Println("Invalid choice. Enter 1 or 2.")
+			fmt.Println(warn("Invalid choice. Enter 1 or 2."))
 		}
 	}
 }
 
-func gitCommit(msg string, style Style) error {
-	var args []string
-	if style == StyleDetailed {
+func gitCommit(msg string, style generator.Style, extra ...string) error {
+	return commitWithMessage(commitText(msg, style), extra...)
+}
+
+// commitText is the message as git should record it. Detailed messages put a
+// blank line between the title and the description.
+func commitText(msg string, style generator.Style) string {
+	if style == generator.StyleDetailed {
 		lines := strings.SplitN(msg, "\n", 2)
-		args = []string{"commit", "-m", strings.TrimSpace(lines[0])}
+		text := strings.TrimSpace(lines[0])
 		if len(lines) == 2 {
-			args = append(args, "-m", strings.TrimSpace(lines[1]))
+			text += "\n\n" + strings.TrimSpace(lines[1])
 		}
-	} else {
-		args = []string{"commit", "-m", msg}
+		return text
+	}
+	return msg
+}
+
+// commitWithMessage runs git commit -F with msg written to a temporary file,
+// so multi-line messages reach git exactly as written. args go after the -F
+// option (e.g. "--amend", or "--" and paths). The file is removed even when
+// the commit fails.
+func commitWithMessage(msg string, args ...string) error {
+	if dryCommit {
+		// -F - reads the same message from stdin.
+		previewGit(slices.Concat([]string{"commit", "-F", "-"}, commitSignArgs, args)...)
+		fmt.Println("Message:\n" + indent(msg))
+		return nil
+	}
+	f, err := os.CreateTemp("", "commit-msg-*.txt")
+	if err != nil {
+		return err
 	}
-	cmd := exec.Command("git", args...)
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(msg); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	args = slices.Concat([]string{"commit", "-F", f.Name()}, commitSignArgs, args)
+	return signingError(runCommit(args))
+}
+
+// dryCommit makes the commit path print the git commands it would run
+// instead of running them (--dry-commit).
+var dryCommit bool
+
+// previewGit prints a git command line for --dry-commit.
+func previewGit(args ...string) {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	fmt.Println("  git " + strings.Join(quoted, " "))
+}
+
+// indent prefixes every non-empty line of s with two spaces.
+func indent(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = "  " + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runCommit runs git with the given arguments, streaming its output to the
+// terminal while keeping stderr for the returned error.
+func runCommit(args []string) error {
+	if dryCommit {
+		previewGit(args...)
+		return nil
+	}
+	var stderr bytes.Buffer
+	cmd := gitCmd(args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	return gitctx.CommandError(cmd.Run(), stderr.String())
+}
+
+// runHooks runs a hook command such as "pre-commit run" with the terminal
+// attached. The command is split on whitespace, not run through a shell.
+func runHooks(command string) error {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return errors.New("empty command")
+	}
+	cmd := exec.CommandContext(runCtx, fields[0], fields[1:]...)
+	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -158,7 +450,7 @@ func update() <-chan string {
 			return
 		}
 		dir := filepath.Dir(file)
-		out, err := exec.Command("git", "-C", dir, "pull", "origin", "main").CombinedOutput()
+		out, err := gitCmd("-C", dir, "pull", "origin", "main").CombinedOutput()
 		if err != nil || strings.Contains(string(out), "Already up to date.") {
 			return
 		}
@@ -172,83 +464,130 @@ func update() <-chan string {
 }
 
 func runGit(args ...string) (string, error) {
-	out, err := exec.Command("git", args...).Output()
-	return strings.TrimSpace(string(out)), err
+	ctx, cancel := withTimeout(runCtx, queryTimeout)
+	defer cancel()
+	out, err := gitctx.GitIn(ctx, repo.Top)(args...)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("git %s took longer than %s (raise --timeout)", args[0], queryTimeout)
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", fmt.Errorf("git %s needs git, which isn't installed: %w", args[0], err)
+	}
+	return out, err
 }
 
-func collectGitData(staged bool) (gitStatus, currentBranch, gitLog, diff string) {
-	var wg sync.WaitGroup
-	var statusErr, branchErr, logErr, diffErr error
-
-	wg.Add(4)
-
-	go func() {
-		defer wg.Done()
-		gitStatus, statusErr = runGit("status")
-	}()
-
-	go func() {
-		defer wg.Done()
-		currentBranch, branchErr = runGit("rev-parse", "--abbrev-ref", "HEAD")
-	}()
+func gitCmd(args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(runCtx, gitctx.Path(), args...)
+	cmd.Dir = repo.Top
+	return cmd
+}
 
-	go func() {
-		defer wg.Done()
-		gitLog, logErr = runGit("log", "-n", "10", "--oneline")
-	}()
+// collectGitData gathers the context of the change diffArgs selects, see
+// gitctx.Repo.Collect, or CollectGoGit with the go-git backend. A failing
+// git command ends the run, see gitFatalf.
+func collectGitData(diffArgs []string, concurrency int) gitctx.CommitContext {
+	r := gitctx.Repo{Git: runGit, Debugf: debugf, Untracked: untracked}
+	var gc gitctx.CommitContext
+	var err error
+	if goGit {
+		gc, err = r.CollectGoGit(repo.Top, diffArgs)
+	} else {
+		gc, err = r.Collect(diffArgs, concurrency)
+	}
+	if err != nil {
+		gitFatalf("%v", err)
+	}
+	return gc
+}
 
-	go func() {
-		defer wg.Done()
-		if staged {
-			diff, diffErr = runGit("diff", "--staged")
-		} else {
-			diff, diffErr = runGit("diff", "HEAD")
+// streamChunks prints an answer dimmed as it arrives, starting on a new
+// line.
+func streamChunks() func(string) {
+	started := false
+	return func(chunk string) {
+		if !started {
+			fmt.Println()
+			started = true
 		}
-	}()
-
-	wg.Wait()
-
-	if statusErr != nil {
-		log.Fatalf("git status failed: %v", statusErr)
+		fmt.Print(paint(ansiDim, chunk))
 	}
-	if branchErr != nil {
-		log.Fatalf("git branch failed: %v", branchErr)
+}
+
+// checkModel reports why model can't be used: its provider is unknown or has
+// no API key in the environment, or it is an Ollama model the server doesn't
+// have. generator.New panics on a missing key, so every model is checked
+// before a generator is made for it.
+func checkModel(ctx context.Context, model string) error {
+	p := generator.ProviderOf(model)
+	if _, ok := generator.LookupProvider(p); !ok {
+		return fmt.Errorf("unknown provider %q in model %s (known: %s)", p, model, strings.Join(generator.ProviderNames(), ", "))
 	}
-	if logErr != nil {
-		log.Fatalf("git log failed: %v", logErr)
+	if _, key := generator.APIKeyFor(p); key == "" && len(generator.ProviderKeyEnv[p]) > 0 {
+		return fmt.Errorf("No API key for %s: set %s or run commit init", p, strings.Join(generator.ProviderKeyEnv[p], " or "))
 	}
-	if diffErr != nil {
-		log.Fatalf("git diff failed: %v", diffErr)
+	if p == "ollama" {
+		return checkOllama(ctx, model)
 	}
+	return nil
+}
 
-	return
+// newGenerator sets up model with the run's logging and
+// --print-prompt-and-response.
+func newGenerator(ctx context.Context, model string) *generator.Generator {
+	g := generator.New(ctx, model)
+	g.Debugf, g.Warnf, g.OnExchange = debugf, warnf, recordExchange
+	return g
 }
 
-func systemPromptForStyle(style Style) string {
-	base := "Be extremely concise. Sacrifice grammar for the sake of concision.\nYou are a semantic git commit message generator.\nUse imperative mood.\nConsider the branch context when choosing message type.\nReturn ONLY the commit message, nothing else."
+// exitGenerationFailed is the exit code used when no usable message could be
+// generated, so scripts can tell it apart from git failures.
+const exitGenerationFailed = 4
+
+// exitNoChanges is used with --fail-on-no-changes when there is nothing to
+// describe; without the flag that case exits 0.
+const exitNoChanges = 3
+
+// The exit codes of --ci, for automation that handles each kind of failure
+// on its own: no changes, a failed git command, and a message that breaks
+// the lint rules. A failed generation is exitGenerationFailed either way.
+const (
+	exitCINoChanges    = 2
+	exitGitFailed      = 3
+	exitInvalidMessage = 5
+)
+
+// ciMode is set by --ci.
+var ciMode bool
+
+// untracked is how collectGitData treats untracked files: they are part of
+// a work tree description, since its commit runs git add . to take them in.
+var untracked gitctx.Untracked
 
-	switch style {
-	case StyleSimple:
-		return base + "\nDo NOT use any prefix like fix:, feat:, etc. Just write the message directly.\nKeep it under 50 chars."
-	case StyleDetailed:
-		return base + "\nFollow Conventional Commits format.\nReturn exactly two lines: first line is the short title (under 50 chars), second line is a brief description (under 100 chars).\nNo blank line between them."
-	default: // conventional
-		return base + "\nFollow Conventional Commits format.\nKeep subject under 50 chars."
+// noModelExit is the exit code when there is no model to ask, such as
+// without an API key: a failed generation for --ci, else 1.
+func noModelExit() int {
+	if ciMode {
+		return exitGenerationFailed
 	}
+	return 1
 }
 
-func generateMessage(ctx context.Context, g *genkit.Genkit, style Style, gitStatus, currentBranch, gitLog, diff string) (string, error) {
-	res, err := genkit.Generate(ctx, g,
-		ai.WithSystem(systemPromptForStyle(style)),
-		ai.WithPrompt("Generate a commit message for the following git status:\n"+gitStatus+
-			"\nCurrent branch: "+currentBranch+
-			"\nRecent commits:\n"+gitLog+
-			"\nDiff:\n"+diff),
-	)
-	if err != nil {
-		return "", err
+// gitFatalf is fatalf for a failed git command, which --ci exits
+// exitGitFailed for.
+func gitFatalf(format string, args ...any) {
+	errorf(format, args...)
+	if ciMode {
+		os.Exit(exitGitFailed)
+	}
+	os.Exit(1)
+}
+
+// printRationale writes the model's explanation to stderr so it can never end
+// up in a piped, copied, or committed message.
+func printRationale(s generator.Suggestion) {
+	if s.Rationale != "" {
+		fmt.Fprintf(os.Stderr, "\nWhy: %s\n", s.Rationale)
 	}
-	return strings.TrimSpace(res.Text()), nil
 }
 
 func formatForClipboard(msg string, format ClipFormat) string {
@@ -262,41 +601,452 @@ func formatForClipboard(msg string, format ClipFormat) string {
 	return msg
 }
 
-func pickInteractive(messages []string) string {
-	fmt.Println("\nGenerated commit messages:")
-	for i, msg := range messages {
-		fmt.Printf("  %d) %s\n", i+1, msg)
+func pickInteractive(suggestions []generator.Suggestion, reader *bufio.Reader, regen func(model string) (generator.Suggestion, error), models func() []string) generator.Suggestion {
+	show := func() {
+		fmt.Println("\n" + header("Generated commit messages:"))
+		for i, sg := range suggestions {
+			fmt.Printf("  %d) %s %s\n", i+1, paint(ansiDim, "("+sg.Model+")"), colorMessage(sg.Message))
+		}
 	}
+	show()
 
-	reader := bufio.NewReader(os.Stdin)
 	for {
-		fmt.Printf("\nSelect a message (1-%d): ", len(messages))
+		fmt.Printf("\nSelect a message (1-%d), or m to try another model: ", len(suggestions))
 		input, _ := reader.ReadString('\n')
 		input = strings.TrimSpace(input)
+		if input == "m" {
+			m := pickModel(models(), reader)
+			if m == "" {
+				continue
+			}
+			sg, err := regen(m)
+			if err != nil {
+				warnf("\nGeneration with %s failed: %v", m, err)
+				continue
+			}
+			suggestions = append(suggestions, sg)
+			show()
+			continue
+		}
 		n, err := strconv.Atoi(input)
-		if err == nil && n >= 1 && n <= len(messages) {
-			return messages[n-1]
+		if err == nil && n >= 1 && n <= len(suggestions) {
+			return suggestions[n-1]
 		}
-		fmt.Printf("Invalid choice. Enter a number between 1 and %d.\n", len(messages))
+		fmt.Println(warn(fmt.Sprintf("Invalid choice. Enter a number between 1 and %d, or m.", len(suggestions))))
+	}
+}
+
+// confirmAmend asks whether HEAD should get the message just shown, for
+// --amend. Anything but yes, including no terminal to answer on, is no.
+func confirmAmend(reader *bufio.Reader) bool {
+	fmt.Print("\nAmend HEAD with this message? [y/N]: ")
+	input, _ := reader.ReadString('\n')
+	a := strings.ToLower(strings.TrimSpace(input))
+	return a == "y" || a == "yes"
+}
+
+// pickModel asks for a model by number from models or by name. It returns ""
+// when the input is empty.
+func pickModel(models []string, reader *bufio.Reader) string {
+	fmt.Println("\n" + header("Models:"))
+	for i, m := range models {
+		fmt.Printf("  %d) %s\n", i+1, m)
+	}
+	fmt.Print("\nModel (number or name, empty to cancel): ")
+	input, _ := reader.ReadString('\n')
+	input = strings.TrimSpace(input)
+	if n, err := strconv.Atoi(input); err == nil && n >= 1 && n <= len(models) {
+		return models[n-1]
+	}
+	if input != "" && !strings.Contains(input, "/") && len(models) > 0 {
+		input = generator.ProviderOf(models[0]) + "/" + input
 	}
+	return input
 }
 
 func main() {
 	updateCh := update()
 
 	interactive := flag.Bool("i", false, "Interactive mode: generate multiple suggestions and pick one")
-	staged := flag.Bool("s", false, "Use staged changes only (git diff --staged)")
-	autoAdd := flag.Bool("a", false, "Auto-stage all changes before generating")
-	setStyle := flag.Bool("style", false, "Change commit message style")
+	staged := flag.Bool("s", false, "Use staged changes only (git diff --staged), even when nothing is staged")
+	all := flag.Bool("all", false, "Describe staged and unstaged changes (git diff HEAD) instead of the index only")
+	flag.BoolVar(all, "unstaged", false, "Same as --all")
+	autoAdd := flag.Bool("a", false, "Stage modified and deleted tracked files (git add -u) before generating, like git commit -a")
+	flag.BoolVar(autoAdd, "commit-all", false, "Same as -a")
+	var styleFlag optionalString
+	flag.Var(&styleFlag, "style", "Change the saved commit message style; --style=NAME uses conventional, simple, detailed, gitmoji, or angular for this run only")
 	setAction := flag.Bool("action", false, "Change post-generate action (commit or clipboard)")
 	setClipFormat := flag.Bool("clipformat", false, "Change clipboard copy format (message or command)")
-	flag.Parse()
+	noColor := flag.Bool("no-color", false, "Disable colored output (also honors NO_COLOR)")
+	safetyFlag := flag.String("safety", string(generator.SafetyDefault), "Provider safety filtering: off, default, or strict")
+	explain := flag.Bool("explain", false, "Print the model's reasoning for the message to stderr")
+	rev := flag.String("rev", "", "Generate a message for an existing commit (amends it when it is HEAD)")
+	promptURL := flag.String("prompt-url", "", "Shared system prompt source: http(s) URL or git:<repo>#<path>")
+	refreshPrompt := flag.Bool("refresh-prompt", false, "Re-fetch the shared prompt even if the cached copy is fresh")
+	prependText := flag.String("prepend", "", "Text to add before the generated subject")
+	appendText := flag.String("append", "", "Text to add after the generated subject (e.g. \"[skip ci]\")")
+	subjectOnly := flag.Bool("subject-only", false, "Regenerate only the subject of an existing message (--message or stdin), keeping its body")
+	existingMessage := flag.String("message", "", "Existing commit message for --subject-only")
+	dotenv := flag.Bool("dotenv", false, "Load API keys from .env in the repository root")
+	envFile := flag.String("env-file", "", "Load API keys from the given .env file")
+	model := flag.String("model", "", "Model to use (default: the provider's default; see: commit models)")
+	providerFlag := flag.String("provider", generator.ProviderAuto, "AI provider: auto, googleai, openai, anthropic, or ollama")
+	offline := flag.Bool("offline", false, "Build a basic message from the changed file list without calling a model")
+	verbose := flag.Bool("verbose", false, "Shorthand for --log-level debug")
+	quiet := flag.Bool("quiet", false, "Print only the message on stdout (--stdout), with everything else on stderr and only errors logged")
+	logLevelFlag := flag.String("log-level", "", "Diagnostics on stderr: debug, info (default), warn, or error (also COMMIT_LOG_LEVEL)")
+	force := flag.Bool("force", false, "Commit even if the working tree has unresolved conflicts, or reword a pushed commit with --reword-last")
+	short := flag.Bool("short", false, "Preset: a single terse subject line")
+	long := flag.Bool("long", false, "Preset: subject plus a bullet-point body with context")
+	flag.BoolVar(long, "body", false, "Same as --long")
+	subjectCaseFlag := flag.String("subject-case", string(generator.CaseLower), "Subject description case: lower, sentence, or preserve")
+	keepPeriod := flag.Bool("keep-period", false, "Keep a trailing period on the subject line")
+	var modelOptionFlags stringList
+	flag.Var(&modelOptionFlags, "model-option", "Provider generation setting as key=value, e.g. temperature=0.2 or thinkingConfig.thinkingBudget=0 (repeatable)")
+	var trailerFlags stringList
+	flag.Var(&trailerFlags, "trailer", "Add a \"Key: value\" trailer such as Co-authored-by to the message; {model} stands for the model (repeatable)")
+	var notes stringList
+	flag.Var(&notes, "note", "Extra context for the model (repeatable)")
+	flag.Var(&notes, "hint", "Same as --note")
+	history := flag.Bool("history", false, "Record this run's model, latency, and retries in the history log")
+	interactiveStage := flag.Bool("interactive-stage", false, "Pick hunks with git add -p, then generate from the staged diff")
+	moodFlag := flag.String("mood", "", "Verb mood: imperative (default), past, or present")
+	split := flag.Bool("split", false, "Suggest splitting the changes into several commits, one message per group of files")
+	rangeFlag := flag.String("range", "", "Changes to describe: staged (default when anything is staged), worktree (default otherwise), or a revision range like HEAD~3..HEAD or main...")
+	ignoreWhitespace := flag.Bool("ignore-whitespace", false, "Drop whitespace-only changes from the diff sent to the model")
+	githubPR := flag.String("github-pr", "", "Summarize a GitHub pull request by URL instead of local changes (uses GITHUB_TOKEN)")
+	maxFiles := flag.Int("max-files", 0, "Include full diffs only for the N most-changed files and list the rest by name (0 = no limit)")
+	examplesFile := flag.String("examples-file", "", "JSON file of {\"diff\", \"message\"} pairs used as few-shot examples")
+	maxExamples := flag.Int("max-examples", 3, "Maximum number of few-shot examples to include")
+	preCommitRun := flag.Bool("pre-commit-run", false, "Run pre-commit hooks (or pre_commit_command from the config) before generating")
+	maxMessageTokens := flag.Int("max-message-tokens", 0, "Budget for the whole message; the body is truncated to fit, never the subject (0 = no limit)")
+	changeID := flag.Bool("change-id", false, "Add a Gerrit Change-Id trailer, reusing the existing one when amending")
+	tuiFlag := flag.Bool("tui", false, "Review and edit the message in a terminal UI before committing")
+	statOnly := flag.Bool("include-diff-stat-only", false, "Send only file names, change types, and line counts to the model, never the diff content")
+	gitConcurrency := flag.Int("git-concurrency", 4, "Maximum number of git commands run at once while gathering context (1 = serial)")
+	forceRegenerate := flag.Bool("force-regenerate-on-same-hash", false, "Ignore the cached message for an unchanged diff and prompt, and replace it")
+	flag.BoolVar(&noCache, "no-cache", false, "Neither use nor update the message cache")
+	dumpFlag := flag.String("print-prompt-and-response", "", "Append the exact prompts and raw model responses to this file (auto: a timestamped file in the cache directory)")
+	noteRef := flag.String("note-ref", "", "Attach a detailed explanation as a git note under this notes ref (e.g. commits) to HEAD or --rev")
+	noteModeFlag := flag.String("note-mode", string(NoteAppend), "When the commit already has a note: append or replace")
+	templateFile := flag.String("commit-template-file", "", "Commit template to merge the message into (default: git config commit.template)")
+	ignoreGitTemplate := flag.Bool("ignore-git-template", false, "Don't merge the message into the commit template")
+	tokenBudget := flag.Int("token-budget", 0, "Trim old log lines, then low-signal and large diff hunks until the prompt fits about N tokens, listing every file's line counts next to the hunks kept (0 = no limit)")
+	sign := flag.Bool("sign", false, "Sign the commit (git commit -S), regardless of commit.gpgsign")
+	noSign := flag.Bool("no-sign", false, "Don't sign the commit, regardless of commit.gpgsign")
+	compareModels := flag.String("compare-models", "", "Comma-separated models to run on the same diff, printing each message with latency and estimated cost")
+	compareJSON := flag.Bool("json", false, "Print the message as JSON (type, scope, subject, body, breaking) on stdout and everything else on stderr; with --compare-models, the comparison")
+	maxRetries := flag.Int("max-retries-per-model", 2, "Retries per model after a failed or empty answer, with jittered backoff")
+	normalizeSubject := flag.Bool("normalize-unicode", true, "Replace smart quotes, dashes, and non-breaking spaces in the subject with ASCII (--normalize-unicode=false to keep them)")
+	normalizeBody := flag.Bool("normalize-unicode-body", false, "Also normalize the body to ASCII")
+	promptAppend := flag.String("base-prompt-append", "", "Extra instructions added to the end of the system prompt instead of replacing it")
+	maxLineLength := flag.Int("max-line-length", 1000, "Replace diff lines longer than this many bytes with a placeholder (0 keeps them)")
+	autoType := flag.Bool("auto-type-from-branch", false, "Use the commit type implied by the branch prefix (feat/, fix/, chore/, ...; see branch_types in the config)")
+	styleGuide := flag.String("style-guide", "", "File with the team's commit conventions (e.g. CONTRIBUTING.md) to follow")
+	watch := flag.Bool("watch", false, "Print a fresh message whenever the working tree changes, until interrupted")
+	appendStats := flag.Bool("append-stats", false, "Add a footer listing changed files with insertion and deletion counts")
+	filesFrom := flag.String("files-from", "", "Only describe (and commit) the changed files listed in this file, one per line (- for stdin)")
+	failOnNoChanges := flag.Bool("fail-on-no-changes", false, "Exit with status 3 instead of 0 when there are no changes to describe")
+	stash := flag.String("stash", "", "Summarize a stash entry (stash@{N} or N) instead of pending changes")
+	stashSave := flag.Bool("stash-save", false, "Stash the changes with the generated subject as the stash message instead of committing")
+	wip := flag.Bool("wip", false, "Quick checkpoint: a short subject marked wip: (see wip_prefix), no body")
+	fixup := flag.String("fixup", "", "Write a fixup! message for this commit, for git rebase --autosquash (no model call)")
+	squash := flag.String("squash", "", "Write a squash! message for this commit, with a generated description of the changes")
+	minDiffLines := flag.Int("min-diff-lines", 0, "Use the offline heuristic message, without a model call, for diffs with fewer changed lines than this (0 = always use the model)")
+	closeKeywordFlag := flag.String("close-keyword", "", "Add a \"<keyword> #N\" line (e.g. Fixes or Closes) for issues named by the branch or a --note")
+	allowEmpty := flag.Bool("allow-empty", false, "Without changes, generate a message from --note and the branch and commit with git commit --allow-empty")
+	bucketDiff := flag.Bool("bucket-diff", false, "Show the model the diff in labeled source, tests, docs, and config sections (see buckets in the config)")
+	rewordLast := flag.Bool("reword-last", false, "Regenerate the message of the last commit, using its subject as context, and amend it (git commit --amend --only)")
+	amendFlag := flag.Bool("amend", false, "Like --reword-last, but show the new message and ask before amending")
+	multiType := flag.Bool("multi-type", false, "When a change mixes types, keep the secondary ones as \"Also <type>: ...\" body lines")
+	patchFile := flag.String("patch-file", "", "Describe a patch file (git format-patch output or a unified diff) instead of the repository")
+	noLog := flag.Bool("no-log", false, "Leave recent commits out of the prompt, e.g. when poor history messages get imitated")
+	verify := flag.Bool("verify", false, "Check the message against the diff (file names, plus one extra model call) and regenerate it if unsupported")
+	autoSplitCommit := flag.Bool("auto-split-commit", false, "Like --split, but commit each group in turn without asking for the plan (-i asks before each commit)")
+	osc52 := flag.Bool("osc52", false, "Copy through the terminal with the OSC 52 escape sequence, which reaches the local clipboard over SSH")
+	dryCommitFlag := flag.Bool("dry-commit", false, "Print the git commands, files, and message a commit would use instead of committing")
+	emojiFlag := flag.Bool("emoji", false, "Start the subject with the emoji for its type (gitmoji by default; see emoji in the config)")
+	strictPrivacy := flag.Bool("strict-privacy", false, "Abort instead of withholding the contents of sensitive files (.env, keys, credentials; see sensitive_paths)")
+	mapReduce := flag.Bool("mapreduce", false, "For huge diffs: summarize each file, combine the summaries, and generate from them (one extra model call per file, cached)")
+	toStdout := flag.Bool("stdout", false, "Print the message to stdout; combines with --clipboard and --write-editmsg")
+	toClipboard := flag.Bool("clipboard", false, "Copy the message to the clipboard; combines with --stdout and --write-editmsg")
+	noClipboard := flag.Bool("no-clipboard", false, "Never touch the clipboard: print the message where it would be copied")
+	toEditMsg := flag.Bool("write-editmsg", false, "Write the message to .git/COMMIT_EDITMSG for git commit -eF; combines with --stdout and --clipboard")
+	noScrub := flag.Bool("no-scrub", false, "Keep absolute paths, secret-looking tokens, and redact_patterns matches in the generated message")
+	releaseToolFlag := flag.String("release-tool", "", "Follow and check the commit rules of release-please or semantic-release, whose version bumps these messages drive")
+	commitFlag := flag.Bool("commit", false, "Commit with the generated message, whatever the configured action")
+	signoff := flag.Bool("signoff", false, "Add a Signed-off-by trailer when committing (git commit --signoff)")
+	review := flag.Bool("review", false, "After generating, accept and commit, edit in $EDITOR, regenerate with extra instructions, or quit")
+	maxFileTokens := flag.Int("max-file-tokens", 0, "Keep only the leading hunks of any file whose diff is over about N tokens (0 = no limit)")
+	styleTemplate := flag.String("style-template", "", "File with a message format using {type}, {scope}, {subject}, and {body}, replacing the style's format")
+	candidates := flag.Int("candidates", 0, "Generate N alternative messages to pick from (implies -i; -i alone makes 3)")
+	selectN := flag.Int("select", 0, "Take candidate N without asking, for scripts (implies -i)")
+	outputFile := flag.String("output-file", "", "Write the message to this file, keeping comment lines already in it (used by install-hook)")
+	stream := flag.Bool("stream", false, "Print the message as the model writes it, so a bad one can be stopped early with Ctrl-C")
+	promptProfileFlag := flag.String("prompt-profile", "", "Prompt size: auto (compact for Ollama), full, or compact (short rules, no recent commits, a 3000-token budget) for small local models")
+	scopeFromFlag := flag.String("scope-from", "", "Scope for files the scopes map doesn't cover: map (none), directory (top-level directory), or package (nearest go.mod, package.json, Cargo.toml, or pyproject.toml)")
+	noBreakingCheck := flag.Bool("no-breaking-check", false, "Don't look for breaking changes (removed or changed exported Go API) or mark them with ! and a BREAKING CHANGE footer")
+	maxPromptTokens := flag.Int("max-tokens", 0, "Refuse to send a prompt of more than about N tokens (0 = no limit)")
+	dryRun := flag.Bool("dry-run", false, "Print the system and user prompts that would be sent, after redaction and trimming, with a token and cost estimate, and stop without calling the model")
+	maxCost := flag.Float64("max-cost", 0, "Refuse to send a request estimated to cost more than this many USD (0 = no limit)")
+	splitByFlag := flag.String("split-by", "", "How --split groups changes: directory (whole files by top-level directory, default) or model (the model groups single hunks)")
+	langFlag := flag.String("lang", "", "Language to write the message in, as a tag such as ja or pt-BR (default: English)")
+	lintFlag := flag.Bool("lint", false, "Check the message with the commit lint rules, fix what needs no rewording, and regenerate it if it still fails")
+	watchFile := flag.String("watch-file", "", "Keep the latest --watch message in this file, e.g. for an editor status line (implies --watch)")
+	blockOnSecret := flag.Bool("block-on-secret", false, "Refuse to send a diff with secrets in it (keys, tokens, passwords, redact_patterns) and list the lines, instead of redacting them")
+	ciFlag := flag.Bool("ci", false, "Run unattended, e.g. in a bot: no prompts or clipboard, only the message (or --json) on stdout, and exit 2 for no changes, 3 for a git error, 4 for a model error, 5 for a message that breaks the lint rules")
+	noHistoryStyle := flag.Bool("no-history-style", false, "Don't match the types, scopes, subject length, and emoji of the repository's recent commits")
+	gitBackend := flag.String("git-backend", "auto", "How to read the changes: exec (run git), go-git (built in, for systems without git; committing still needs git), or auto (go-git when git isn't installed)")
+	temperature := flag.Float64("temperature", 0, "Sampling temperature, 0 to 2; lower is more predictable (default: the model's)")
+	topP := flag.Float64("top-p", 0, "Nucleus sampling: only the most likely tokens, up to this share of the probability, 0 to 1 (default: the model's)")
+	maxOutputTokens := flag.Int("max-output-tokens", 0, "Stop the model after this many output tokens, thinking included for models that think (0 = the model's limit)")
+	noUntrackedContent := flag.Bool("no-untracked-content", false, "List new untracked files by name only when describing the working tree, without their content")
+	maxSubjectLength := flag.Int("max-subject-length", 0, "Ask for subjects under this many characters, and warn and lint beyond it (default 50)")
+	structured := flag.Bool("structured", false, "Ask the model for the message's parts as JSON (type, scope, subject, body, breaking, footers) and assemble it, so fences or commentary can't end up in it")
+	reposFlag := flag.String("repos", "", "Describe the changes of several repositories at once (comma-separated directories), then commit in the ones picked")
+	workspaceFlag := flag.String("workspace", "", "Same as --repos, with the directories listed one per line in `FILE`")
+	timeout := flag.Duration("timeout", 30*time.Second, "Give up on a model request or git query that takes longer than this (0 = no limit)")
+	diffAlgorithmFlag := flag.String("diff-algorithm", "histogram", "Diff algorithm passed to git: histogram, patience, minimal, or myers")
+	// The command line only picks the command; the run continues here.
+	// `commit generate [flags]` is the default command spelled out, and
+	// `commit watch [flags]` is --watch.
+	command, args, ok := parseCommandLine(os.Args[1:])
+	if !ok {
+		return // help or a completion was printed
+	}
+	switch command {
+	case "generate":
+		command = ""
+	case "watch":
+		command, *watch = "", true
+	}
+	runCtx, queryTimeout = interruptContext(), *timeout
+	if ciMode = *ciFlag; ciMode {
+		if *tuiFlag || *review || *interactiveStage || *amendFlag || (*interactive || *candidates > 0) && *selectN == 0 || styleFlag.Given && styleFlag.Value == "" || *setAction || *setClipFormat {
+			fatalf("--ci runs without prompts; it can't be combined with --tui, --review, --interactive-stage, --amend, -i or --candidates without --select, or the setup flags")
+		}
+		*quiet, *noClipboard = !*compareJSON, true
+	}
+	if *amendFlag {
+		*rewordLast = true
+	}
+
+	// --json keeps stdout for the JSON alone: everything else printed there
+	// goes to stderr instead, and only warnings and errors are logged.
+	jsonOut := io.Writer(nil)
+	if *compareJSON && *compareModels == "" {
+		jsonOut, os.Stdout = os.Stdout, os.Stderr
+	}
+	// --quiet does the same for the bare message: --stdout, with the
+	// progress and preview on stderr and only errors logged.
+	quietOut := io.Writer(nil)
+	if *quiet {
+		*toStdout = true
+		if jsonOut == nil {
+			quietOut, os.Stdout = os.Stdout, os.Stderr
+		}
+	}
+	setupColor(*noColor)
+	if *logLevelFlag == "" {
+		*logLevelFlag = os.Getenv("COMMIT_LOG_LEVEL")
+		if *verbose {
+			*logLevelFlag = "debug"
+		} else if *quiet {
+			*logLevelFlag = "error"
+		} else if jsonOut != nil {
+			*logLevelFlag = "warn"
+		}
+	}
+	level, err := parseLogLevel(*logLevelFlag)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	logLevel = level
+	if err := chooseGitBackend(*gitBackend); err != nil {
+		fatalf("%v", err)
+	}
+	discoverRepo()
+	if commitSignArgs, err = signArgs(*sign, *noSign); err != nil {
+		fatalf("%v", err)
+	}
+	if *dumpFlag != "" {
+		dumpPath = resolveDumpPath(*dumpFlag)
+	}
+	if *tuiFlag && (!isTerminal(os.Stdin) || !isTerminal(os.Stdout)) {
+		fatalf("%v", errNotTerminal)
+	}
+	if *review && *tuiFlag {
+		fatalf("--review and --tui are mutually exclusive")
+	}
+	if *candidates < 0 || *selectN < 0 {
+		fatalf("--candidates and --select take a positive number")
+	}
+	if *selectN > cmp.Or(*candidates, 3) {
+		fatalf("--select %d is out of range: only %d candidates are generated", *selectN, cmp.Or(*candidates, 3))
+	}
+	if *candidates > 0 || *selectN > 0 {
+		*interactive = true
+	}
+	if *review && !isTerminal(os.Stdin) {
+		fatalf("%v", errReviewNotTerminal)
+	}
+
+	// Subcommands find the keys commit init saved here; the main run takes
+	// them after --env-file, whose keys win.
+	multiRepo := *reposFlag != "" || *workspaceFlag != ""
+	if command != "" || multiRepo {
+		c := loadConfig()
+		applyAPIKeyEnv(c.APIKeyEnv)
+		applyAPIKeys(c.APIKeys)
+		if err := applyNetwork(c); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	switch command {
+	case "init":
+		runInit(runCtx)
+		return
+	case "models":
+		runModels(runCtx, args)
+		return
+	case "stats":
+		runStats()
+		return
+	case "cache":
+		runCache(args)
+		return
+	case "doctor":
+		m, _, err := generator.ResolveModel(*providerFlag, *model, *model != "")
+		if err != nil {
+			m = generator.DefaultModel
+		}
+		runDoctor(args, m)
+		return
+	case "pr":
+		m, _, err := generator.ResolveModel(*providerFlag, *model, *model != "")
+		if err != nil {
+			fatalf("%v", err)
+		}
+		runPR(args, m, withGitConfig(withRepoConfig(loadConfig())))
+		return
+	case "rewrite":
+		m, _, err := generator.ResolveModel(*providerFlag, *model, *model != "")
+		if err != nil {
+			fatalf("%v", err)
+		}
+		runRewrite(args, m, withGitConfig(withRepoConfig(loadConfig())))
+		return
+	case "serve":
+		m, _, err := generator.ResolveModel(*providerFlag, *model, *model != "")
+		if err != nil {
+			fatalf("%v", err)
+		}
+		runServe(args, m, loadConfig())
+		return
+	case "changelog":
+		runChangelog(args, *providerFlag, *model)
+		return
+	case "lint":
+		runLint(args, withGitConfig(withRepoConfig(loadConfig())))
+		return
+	case "exemplar":
+		runExemplar(args, withGitConfig(withRepoConfig(loadConfig())))
+		return
+	case "install-hook":
+		runInstallHook(args)
+		return
+	case "uninstall-hook":
+		runUninstallHook(args)
+		return
+	case "config":
+		runConfig(args)
+		return
+	case "man":
+		runMan()
+		return
+	}
+	if multiRepo {
+		dirs, err := readRepoList(*reposFlag, *workspaceFlag)
+		if err != nil {
+			fatalf("--repos: %v", err)
+		}
+		m, _, err := generator.ResolveModel(*providerFlag, *model, *model != "")
+		if err != nil {
+			fatalf("%v", err)
+		}
+		c := loadConfig()
+		trailers, err := parseTrailers(slices.Concat(trailerFlags, c.Trailers))
+		if err != nil {
+			fatalf("%v", err)
+		}
+		dryCommit = *dryCommitFlag
+		runRepos(dirs, m, c, reposOptions{Commit: *commitFlag || *dryCommitFlag, Concurrency: *gitConcurrency, Signoff: *signoff || c.Signoff, Trailers: trailers, Force: *force})
+		return
+	}
+
+	safety, err := generator.ParseSafety(*safetyFlag)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	diffAlgorithm, err := parseDiffAlgorithm(*diffAlgorithmFlag)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	subjectCase, err := generator.ParseSubjectCase(*subjectCaseFlag)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if *short && *long {
+		fatalf("--short and --long are mutually exclusive")
+	}
+	if *noClipboard && *toClipboard {
+		fatalf("--clipboard and --no-clipboard are mutually exclusive")
+	}
+	if *watchFile != "" {
+		*watch = true
+	}
+	if *autoSplitCommit {
+		*split = true
+	}
+	if *dryRun && (*offline || *split || *watch || *tuiFlag || *compareModels != "" || *mapReduce) {
+		fatalf("--dry-run shows the prompt of a single generation; it can't be combined with --offline, --split, --watch, --tui, --compare-models, or --mapreduce")
+	}
+	if *dryCommitFlag && *split {
+		fatalf("--dry-commit can't be combined with --split or --auto-split-commit")
+	}
+	if *verify && (*offline || *interactive) {
+		fatalf("--verify checks a generated message; it can't be combined with --offline or -i")
+	}
+	if *lintFlag && (*offline || *interactive) {
+		fatalf("--lint checks a generated message; it can't be combined with --offline or -i")
+	}
+	if *multiType && (*short || *wip) {
+		fatalf("--multi-type needs a body; it can't be combined with --short or --wip")
+	}
+
+	if *envFile == "" && *dotenv {
+		root, err := runGit("rev-parse", "--show-toplevel")
+		if err != nil {
+			fatalf("--dotenv: not inside a git repository: %v", err)
+		}
+		*envFile = filepath.Join(root, ".env")
+	}
+	if *envFile != "" {
+		if err := loadDotenv(*envFile); err != nil {
+			fatalf("Failed to load %s: %v", *envFile, err)
+		}
+	}
 
 	cfg := loadConfig()
+	applyAPIKeyEnv(cfg.APIKeyEnv)
+	applyAPIKeys(cfg.APIKeys)
+	if err := applyNetwork(cfg); err != nil {
+		fatalf("%v", err)
+	}
 	reader := bufio.NewReader(os.Stdin)
 
 	// --style: change style and exit
-	if *setStyle {
+	if styleFlag.Given && styleFlag.Value == "" {
 		cfg.Style = askStyle(reader)
 		saveConfig(cfg)
 		fmt.Printf("Style saved: %s\n", cfg.Style)
@@ -322,113 +1072,1144 @@ func main() {
 		return
 	}
 
+	// .commitrc and then git config sit between the flags and the config
+	// file. Only answers given during setup are saved, never values that
+	// came from either.
+	fileCfg := cfg
+	cfg = withGitConfig(withRepoConfig(cfg))
+	if styleFlag.Value != "" {
+		if cfg.Style, err = generator.ParseStyle(styleFlag.Value); err != nil {
+			fatalf("%v", err)
+		}
+	}
+
+	// The style template is checked as soon as the config is known, before
+	// the changes are gathered or a model is called, so a typo in it fails
+	// fast.
+	if *styleTemplate == "" {
+		*styleTemplate = cfg.StyleTemplate
+	}
+	var styleTmpl string
+	if *styleTemplate != "" {
+		if styleTmpl, err = loadStyleTemplate(*styleTemplate); err != nil {
+			fatalf("Failed to read the style template: %v", err)
+		}
+	}
+
+	// Without a terminal there is no one to answer the setup questions, e.g.
+	// in a git hook or with --ci: run with the defaults and leave setup for
+	// later.
+	if (cfg.Style == "" || cfg.Action == "") && (!isTerminal(os.Stdin) || ciMode) {
+		cfg.Style = cmp.Or(cfg.Style, generator.StyleConventional)
+		cfg.Action = cmp.Or(cfg.Action, ActionClipboard)
+	}
+
 	// First-run setup
 	if cfg.Style == "" || cfg.Action == "" {
 		fmt.Println("Welcome! Let's set up your preferences.")
 		if cfg.Style == "" {
 			cfg.Style = askStyle(reader)
+			fileCfg.Style = cfg.Style
 		}
 		if cfg.Action == "" {
 			cfg.Action = askAction(reader)
+			fileCfg.Action = cfg.Action
 			if cfg.Action == ActionClipboard && cfg.ClipFormat == "" {
 				cfg.ClipFormat = askClipFormat(reader)
+				fileCfg.ClipFormat = cfg.ClipFormat
 			}
 		}
-		saveConfig(cfg)
+		saveConfig(fileCfg)
 		fmt.Printf("Setup complete! (style: %s, action: %s)\n\n", cfg.Style, cfg.Action)
 	}
-
-	// Auto-stage if requested
-	if *autoAdd {
-		if err := exec.Command("git", "add", ".").Run(); err != nil {
-			log.Fatalf("git add failed: %v", err)
+	if *model == "" {
+		*model = cfg.Model
+	}
+	if cfg.Clipboard != nil && !*cfg.Clipboard && !*toClipboard {
+		*noClipboard = true
+	}
+	if !flagSet("provider") && cfg.Provider != "" {
+		*providerFlag = cfg.Provider
+	}
+	var releaseTool generator.ReleaseTool
+	if rt := cmp.Or(*releaseToolFlag, cfg.ReleaseTool); rt != "" {
+		if releaseTool, err = generator.ParseReleaseTool(rt); err != nil {
+			fatalf("%v", err)
+		}
+		if cfg.Style != generator.StyleConventional && cfg.Style != generator.StyleAngular {
+			infof("%s reads Conventional Commits; using the conventional style.", releaseTool)
+			cfg.Style = generator.StyleConventional
+		}
+	}
+	var closeKeyword string
+	if kw := cmp.Or(*closeKeywordFlag, cfg.CloseKeyword); kw != "" {
+		if closeKeyword, err = parseCloseKeyword(kw); err != nil {
+			fatalf("%v", err)
 		}
-		fmt.Println("All changes staged.")
-		*staged = true
 	}
 
-	// Check for changes
-	var checkArgs []string
-	if *staged {
-		checkArgs = []string{"diff", "--staged", "--quiet"}
-	} else {
-		checkArgs = []string{"diff-index", "--quiet", "HEAD"}
+	trailers, err := parseTrailers(slices.Concat(trailerFlags, cfg.Trailers))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	*signoff = *signoff || cfg.Signoff
+
+	tickets, err := newTicketRef(cfg.TicketPattern, cfg.TicketPosition)
+	if err != nil {
+		fatalf("%v", err)
 	}
-	if err := exec.Command("git", checkArgs...).Run(); err == nil {
+	splitMode, err := parseSplitMode(cmp.Or(*splitByFlag, cfg.SplitBy, string(SplitByDirectory)))
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	var dr diffRange
+	var revSHA string
+	var revIsHead bool
+
+	var gc gitctx.CommitContext
+
+	if *githubPR != "" {
+		// --github-pr: describe a pull request fetched from the GitHub API
+		if gc, err = fetchPullRequest(*githubPR); err != nil {
+			fatalf("Failed to fetch pull request: %v", err)
+		}
+		dr = diffRange{Spec: *githubPR, History: true}
+	} else if *patchFile != "" {
+		// --patch-file: describe a patch without looking at any repository
+		if gc, err = readPatchFile(*patchFile); err != nil {
+			fatalf("Failed to read patch: %v", err)
+		}
+		dr = diffRange{Spec: *patchFile, History: true}
+	} else if *rewordLast {
+		// --reword-last: --rev HEAD that always amends
+		if *rev != "" || *noteRef != "" || *stash != "" {
+			fatalf("--reword-last can't be combined with --rev, --note-ref, or --stash")
+		}
+		if revSHA, revIsHead, err = resolveRev("HEAD"); err != nil {
+			fatalf("--reword-last: there is no commit yet")
+		}
+		if remotes, err := remoteBranchesContaining(revSHA); err == nil && len(remotes) > 0 && !*force {
+			errorf("HEAD is already on %s; rewording it rewrites published history. Pass --force to reword it anyway.", strings.Join(remotes, ", "))
+			os.Exit(1)
+		}
+		dr = diffRange{Spec: revSHA, Args: []string{"show", "--format=", "--diff-algorithm=" + diffAlgorithm, revSHA}, History: true}
+	} else if *rev != "" || *noteRef != "" {
+		// --rev: describe an existing commit instead of pending changes.
+		// --note-ref without --rev annotates HEAD.
+		if *rev == "" {
+			*rev = "HEAD"
+		}
+		revSHA, revIsHead, err = resolveRev(*rev)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		dr = diffRange{Spec: revSHA, Args: []string{"show", "--format=", "--diff-algorithm=" + diffAlgorithm, revSHA}, History: true}
+	} else if *stash != "" {
+		// --stash: summarize a stash entry
+		ref, err := resolveStash(*stash)
+		if err != nil {
+			fatalf("--stash: %v", err)
+		}
+		dr = diffRange{Spec: ref, Args: []string{"stash", "show", "-p", "--diff-algorithm=" + diffAlgorithm, ref}, History: true}
+	} else {
+		// -a and --interactive-stage both stage first and imply staged mode
+		if *autoAdd || *interactiveStage {
+			*staged = true
+		}
+		// -s is a shortcut for --range staged
 		if *staged {
-			fmt.Println("No staged changes detected.")
+			if *rangeFlag != "" && *rangeFlag != RangeStaged {
+				fatalf("--range %s conflicts with staged mode (-s, -a, --interactive-stage)", *rangeFlag)
+			}
+			*rangeFlag = RangeStaged
+		}
+		if *all {
+			if *rangeFlag != "" && *rangeFlag != RangeWorktree {
+				fatalf("--all conflicts with --range %s and with staged mode (-s, -a, --interactive-stage)", *rangeFlag)
+			}
+			*rangeFlag = RangeWorktree
+		}
+		// By default the message describes what git commit would commit:
+		// the index, unless nothing is staged at all.
+		if *rangeFlag == "" {
+			if hasStaged() {
+				*rangeFlag = RangeStaged
+			} else {
+				warnf("Nothing is staged; describing all changes in the working tree (stage files to narrow it down, or pass --all).")
+				*rangeFlag = RangeWorktree
+			}
+		}
+		if dr, err = resolveRange(*rangeFlag, diffAlgorithm); err != nil {
+			fatalf("%v", err)
+		}
+		if dr.Spec == RangeWorktree {
+			untracked = gitctx.UntrackedContent
+			if *noUntrackedContent || cfg.NoUntrackedContent {
+				untracked = gitctx.UntrackedNames
+			}
+		}
+
+		// Like git commit -a: tracked files only, untracked ones stay out.
+		if *autoAdd {
+			if _, err := runGit("add", "-u"); err != nil {
+				gitFatalf("git add -u failed: %v", err)
+			}
+			fmt.Println("Tracked changes staged.")
+		}
+
+		// Pick hunks with git's own interactive staging, then describe them
+		if *interactiveStage {
+			cmd := gitCmd("add", "-p")
+			cmd.Stdin = os.Stdin
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				gitFatalf("git add -p failed: %v", err)
+			}
+		}
+
+		// Run formatters/linters first so the diff reflects what gets committed
+		if *preCommitRun {
+			command := cfg.PreCommit
+			if command == "" {
+				command = "pre-commit run"
+			}
+			if err := runHooks(command); err != nil {
+				errorf("%s failed: %v", command, err)
+				os.Exit(1)
+			}
+		}
+	}
+	diffArgs := dr.Args
+
+	// --fixup/--squash: mark the message for git rebase --autosquash
+	var target autosquashTarget
+	if *fixup != "" || *squash != "" {
+		if *fixup != "" && *squash != "" {
+			fatalf("--fixup and --squash are mutually exclusive")
+		}
+		if dr.History || *interactive || *tuiFlag || *split || *watch || *compareModels != "" || *stashSave {
+			fatalf("--fixup and --squash describe pending changes; they can't be combined with --rev, --stash, --github-pr, a revision range, -i, --tui, --split, --watch, --compare-models, or --stash-save")
+		}
+		kind, rev := "fixup", *fixup
+		if *squash != "" {
+			kind, rev = "squash", *squash
+		}
+		if target, err = resolveAutosquash(kind, rev); err != nil {
+			fatalf("--%s: %v", kind, err)
+		}
+	}
+	// A fixup message is fully determined by its target.
+	noModel := *offline || target.Kind == "fixup"
+
+	if *stashSave && dr.History {
+		fatalf("--stash-save stashes pending changes; it can't be combined with --rev, --stash, --github-pr, or a revision range")
+	}
+
+	// --files-from: describe and commit only the listed files
+	var fileList []string
+	if *filesFrom != "" {
+		if *githubPR != "" || *patchFile != "" || *split {
+			fatalf("--files-from can't be combined with --github-pr, --patch-file, or --split")
+		}
+		if fileList, err = readFileList(*filesFrom); err != nil {
+			fatalf("--files-from: %v", err)
+		}
+		if err := checkFileList(fileList, diffArgs); err != nil {
+			fatalf("--files-from: %v", err)
+		}
+		diffArgs = slices.Concat(diffArgs, []string{"--"}, fileList)
+	}
+
+	gatherStart := time.Now()
+	if *githubPR == "" && *patchFile == "" {
+		gc = collectGitData(diffArgs, *gitConcurrency)
+	}
+	gatherElapsed := time.Since(gatherStart)
+
+	// An empty diff means there is nothing to describe. This is checked on the
+	// gathered diff rather than with a separate serial git call.
+	// --allow-empty goes on with only the notes and the branch to go by.
+	emptyCommit := gc.Diff == "" && *allowEmpty && !dr.History
+	if emptyCommit {
+		if len(notes) == 0 {
+			warnf("No changes and no --note: the message can only be based on the branch name.")
 		} else {
+			infof("No changes: the message is based on --note and the branch only.")
+		}
+	} else if gc.Diff == "" {
+		switch {
+		case dr.History:
+			fmt.Println("No diff found.")
+		case dr.Spec == RangeStaged:
+			fmt.Println("No staged changes detected.")
+			if unstaged, err := runGit("diff", "--name-only"); err == nil && unstaged != "" {
+				fmt.Println("There are unstaged changes; stage them with git add, or run without -s.")
+			} else if files, err := (gitctx.Repo{Git: runGit}).UntrackedFiles(nil); err == nil && len(files) > 0 {
+				fmt.Println("There are untracked files; add them with git add, or run without -s.")
+			}
+		default:
 			fmt.Println("No changes detected.")
 		}
+		if ciMode {
+			os.Exit(exitCINoChanges)
+		}
+		if *failOnNoChanges {
+			os.Exit(exitNoChanges)
+		}
 		return
 	}
 
-	gitStatus, currentBranch, gitLog, diff := collectGitData(*staged)
+	// The diff is trimmed below for the prompt; --append-stats reports it whole.
+	fullDiff := gc.Diff
+
+	// --split commits every file, so it leaves them out per group instead.
+	commitIgnore, err := loadCommitIgnore()
+	if err != nil {
+		warnf("Ignoring %s: %v", commitIgnoreName, err)
+	}
+	excludePatterns = slices.Concat(cfg.ExcludePaths, commitIgnore)
+	if !*split {
+		var excluded []string
+		if gc, excluded = excludeFiles(gc, excludePatterns); len(excluded) > 0 {
+			infof("Leaving excluded files out of the prompt: %s", strings.Join(excluded, ", "))
+		}
+	}
+
+	sensitivePatterns = slices.Concat(defaultSensitivePaths, cfg.SensitivePaths)
+	var withheld []string
+	if gc, withheld = withholdSensitive(gc); len(withheld) > 0 {
+		if *strictPrivacy {
+			errorf("Sensitive files changed: %s. Refusing to send the diff anywhere (--strict-privacy).", strings.Join(withheld, ", "))
+			os.Exit(1)
+		}
+		warnf("Withholding the contents of sensitive files from the model: %s", strings.Join(withheld, ", "))
+	}
+	if redactPatterns, err = compileRedactPatterns(cfg.RedactPatterns); err != nil {
+		fatalf("%v", err)
+	}
+	blockSecrets = *blockOnSecret || cfg.BlockOnSecret
+	var secrets []secretFinding
+	if gc, secrets = redactSecrets(gc); len(secrets) > 0 {
+		if blockSecrets {
+			refuseSecrets(secrets, "--block-on-secret")
+		}
+		var where []string
+		for _, f := range secrets {
+			where = append(where, fmt.Sprintf("%s:%d (%s)", f.Path, f.Line, f.Kind))
+		}
+		warnf("Redacted secrets from the prompt: %s", strings.Join(where, ", "))
+	}
+
+	whitespaceOnly := !hasHunks(gc.DiffNoWS) && hasHunks(gc.Diff)
+	if whitespaceOnly {
+		warnf("Warning: the diff contains only whitespace changes.")
+	} else if *ignoreWhitespace {
+		gc.Diff = gc.DiffNoWS
+	}
+
+	gc.Diff = omitLongLines(gc.Diff, *maxLineLength)
+	if *maxFiles > 0 {
+		gc.Diff = limitDiffFiles(gc.Diff, *maxFiles)
+	}
+	if !flagSet("max-file-tokens") {
+		*maxFileTokens = cfg.MaxFileTokens
+	}
+	var cut int
+	if gc.Diff, cut = limitFileTokens(gc.Diff, *maxFileTokens); cut > 0 {
+		infof("Cut the diffs of %d files to about %d tokens each (--max-file-tokens).", cut, *maxFileTokens)
+	}
+
+	unmerged, err := runGit("diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		debugf("Listing unmerged paths: %v", err)
+	}
+	conflicts := hasConflicts(unmerged, gc.Diff)
+	if conflicts {
+		// Accepting in --review, committing from --tui, and
+		// --auto-split-commit commit too.
+		planned := effectiveAction(cfg.Action, *toStdout || *toClipboard || *toEditMsg || *outputFile != "" || jsonOut != nil, *commitFlag || *rewordLast || *dryCommitFlag || *review || *tuiFlag || *autoSplitCommit)
+		if planned == ActionCommit && !dr.History && !*force {
+			errorf("Unresolved merge conflicts detected; refusing to commit. Resolve them or pass --force.")
+			os.Exit(1)
+		}
+		warnf("Warning: unresolved merge conflicts detected.")
+	}
+
+	// --min-diff-lines: a tiny change isn't worth a model call. Modes that
+	// exist to show model output always use the model.
+	offlineReason := "--offline"
+	if *minDiffLines > 0 && !noModel && !emptyCommit && !*interactive && !*tuiFlag && !*split && !*watch && *compareModels == "" && *noteRef == "" && !*dryRun {
+		if n := changedLines(fullDiff); n < *minDiffLines {
+			debugf("Diff has %d changed lines, below --min-diff-lines %d: using the heuristic message", n, *minDiffLines)
+			*offline, noModel = true, true
+			offlineReason = "--min-diff-lines"
+		} else {
+			debugf("Diff has %d changed lines, at least --min-diff-lines %d: using the model", n, *minDiffLines)
+		}
+	}
+
+	ctx := runCtx
+	var g *generator.Generator
+	var modelName string
+	if !noModel {
+		var auto bool
+		modelName, auto, err = generator.ResolveModel(*providerFlag, *model, *model != "")
+		if err != nil && *dryRun && auto {
+			// Nothing is sent, so the prompt can be shown without a key.
+			modelName, err = generator.DefaultModel, nil
+		}
+		if err != nil {
+			errorf("%v (or pass --offline for a basic message)", err)
+			os.Exit(noModelExit())
+		}
+		if auto {
+			debugf("Auto-selected provider %s (%s)", generator.ProviderOf(modelName), modelName)
+		}
+		if !*dryRun {
+			if err := checkModel(ctx, modelName); err != nil {
+				errorf("%v (or pass --offline for a basic message)", err)
+				os.Exit(noModelExit())
+			}
+		}
+		if safety != generator.SafetyDefault && generator.ProviderOf(modelName) != "googleai" {
+			warnf("--safety only applies to googleai; ignoring it.")
+			safety = generator.SafetyDefault
+		}
+		if !*dryRun {
+			g = newGenerator(ctx, modelName)
+		}
+	}
+
+	if *moodFlag == "" {
+		*moodFlag = string(cfg.Mood)
+	}
+	mood := generator.MoodImperative
+	if *moodFlag != "" {
+		if mood, err = generator.ParseMood(*moodFlag); err != nil {
+			fatalf("%v", err)
+		}
+	}
 
-	if diff == "" {
-		fmt.Println("No diff found.")
+	if !flagSet("max-subject-length") {
+		*maxSubjectLength = cfg.MaxSubjectLength
+	}
+	if *maxSubjectLength > 0 {
+		maxSubjectLen = *maxSubjectLength
+	}
+	opts := generator.Options{Style: cfg.Style, Mood: mood, Safety: safety, Explain: *explain, SubjectCase: subjectCase, SubjectLimit: maxSubjectLen, Notes: notes, WhitespaceOnly: whitespaceOnly, Model: modelName, MaxRetries: *maxRetries, Empty: emptyCommit, Timeout: *timeout}
+	if lang := cmp.Or(*langFlag, cfg.Language); lang != "" {
+		if opts.Language, err = generator.ParseLanguage(lang); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	// The repository's own exemplars come first, so the examples file only
+	// fills in the places they leave under --max-examples.
+	opts.Examples = exemplarExamples(cfg.Exemplars)
+	if *examplesFile != "" {
+		examples, err := loadExamples(*examplesFile, *maxExamples)
+		if err != nil {
+			fatalf("Failed to load examples: %v", err)
+		}
+		opts.Examples = append(opts.Examples, examples...)
+	}
+	if *maxExamples >= 0 && len(opts.Examples) > *maxExamples {
+		opts.Examples = opts.Examples[:*maxExamples]
+	}
+	opts.MaxTokens = *maxMessageTokens
+	opts.Structured = *structured || cfg.StructuredOutput
+	sampling := generator.Sampling{Temperature: cfg.Temperature, TopP: cfg.TopP, MaxOutputTokens: cfg.MaxOutputTokens}
+	if flagSet("temperature") {
+		sampling.Temperature = temperature
+	}
+	if flagSet("top-p") {
+		sampling.TopP = topP
+	}
+	if flagSet("max-output-tokens") {
+		sampling.MaxOutputTokens = *maxOutputTokens
+	}
+	if err := sampling.Validate(); err != nil {
+		fatalf("%v", err)
+	}
+	if !sampling.IsZero() && !noModel {
+		if options, ok := sampling.ModelOptions(generator.ProviderOf(modelName)); ok {
+			opts.ModelOptions = options
+		} else {
+			warnf("%s takes no generation settings; ignoring the temperature, top-p, and max output tokens.", generator.ProviderOf(modelName))
+		}
+	}
+	for _, s := range modelOptionFlags {
+		o, err := generator.ParseModelOption(s)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		opts.ModelOptions = append(opts.ModelOptions, o)
+	}
+	if len(opts.ModelOptions) > 0 && !noModel {
+		provider := generator.ProviderOf(modelName)
+		unknown, err := generator.CheckModelOptions(provider, opts.ModelOptions)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		for _, k := range unknown {
+			warnf("Unknown model option %q for %s (known: %s); passing it through.", k, provider, strings.Join(generator.KnownModelOptions(provider), ", "))
+		}
+	}
+	opts.Templates = cfg.Templates
+	if *autoType && cfg.Style != generator.StyleSimple {
+		if opts.Type = typeFromBranch(gc.Branch, cfg.BranchTypes); opts.Type != "" {
+			debugf("Type %s from branch %s", opts.Type, gc.Branch)
+		}
+	}
+	opts.BucketDiff, opts.Buckets = *bucketDiff, cfg.Buckets
+	opts.MultiType = *multiType
+	opts.ReleaseTool = releaseTool
+	profile, err := parsePromptProfile(cmp.Or(*promptProfileFlag, cfg.PromptProfile, string(ProfileAuto)))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	opts.Compact = profile == ProfileCompact || profile == ProfileAuto && generator.ProviderOf(modelName) == "ollama"
+	opts.NoLog = *noLog || cfg.NoLog
+	if !opts.NoLog && !opts.Compact && !*noHistoryStyle && !cfg.NoHistoryStyle && opts.Style != generator.StyleSimple {
+		opts.HistoryStyle = loadHistoryStyle()
+	}
+	scopeFrom, err := parseScopeSource(cmp.Or(*scopeFromFlag, cfg.ScopeFrom, string(ScopeFromMap)))
+	if err != nil {
+		fatalf("%v", err)
+	}
+	opts.Scopes = scopesFor(cfg.Scopes, scopeFrom, gc.NameStatus)
+	if !*noBreakingCheck && *noteRef == "" && opts.Style != generator.StyleSimple {
+		opts.BreakingCheck = true
+		if opts.Breaking = breakingChanges(gc.Diff); len(opts.Breaking) > 0 {
+			infof("Breaking changes: %s", strings.Join(opts.Breaking, "; "))
+		}
+	}
+	if len(opts.Scopes) > 0 {
+		debugf("Scopes of the changed files: %s", strings.Join(opts.Scopes, ", "))
+	}
+	if cfg.Tracker != "" && !noModel {
+		if opts.Ticket, err = fetchTicket(cfg, gc.Branch); err != nil {
+			warnf("Could not fetch ticket context (%v); continuing without it.", err)
+		}
+	}
+	if *statOnly {
+		opts.StatOnly = true
+		warnf("--include-diff-stat-only: the model sees only file names and line counts, so the message will be less specific.")
+	}
+	if *short {
+		opts.SingleLine = true
+	}
+	if *long {
+		opts.Body = true
+		opts.BodyWidth = 72
+	}
+	if *subjectOnly {
+		text := *existingMessage
+		if text == "" {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fatalf("Failed to read message from stdin: %v", err)
+			}
+			text = string(data)
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			fatalf("--subject-only needs an existing message via --message or stdin")
+		}
+		opts.SubjectOnly = true
+		_, opts.KeepBody = generator.SplitMessage(text)
+	}
+	if *rewordLast {
+		if opts.OldSubject, err = runGit("log", "-1", "--format=%s", revSHA); err != nil {
+			gitFatalf("git log failed: %v", err)
+		}
+	}
+	if *promptURL == "" {
+		*promptURL = cfg.PromptURL
+	}
+	if *promptURL != "" {
+		opts.SystemPrompt = loadSharedPrompt(*promptURL, *refreshPrompt)
+	}
+	if *promptAppend == "" {
+		*promptAppend = cfg.PromptAppend
+	}
+	opts.PromptAppend = strings.TrimSpace(*promptAppend)
+	if *styleGuide == "" {
+		*styleGuide = cfg.StyleGuide
+	}
+	if *styleGuide != "" {
+		if opts.StyleGuide, err = loadStyleGuide(*styleGuide); err != nil {
+			fatalf("Failed to read the style guide: %v", err)
+		}
+	}
+	opts.StyleTemplate = styleTmpl
+
+	if *mapReduce {
+		if noModel || *split || *watch {
+			fatalf("--mapreduce needs a model and can't be combined with --offline, --split, or --watch")
+		}
+		if opts.DiffSummary, err = mapReduceDiff(ctx, g, opts, gc.Diff); err != nil {
+			errorf("%v", err)
+			os.Exit(exitGenerationFailed)
+		}
+	}
+	if !flagSet("token-budget") {
+		*tokenBudget = cfg.TokenBudget
+		if *tokenBudget == 0 && opts.Compact {
+			*tokenBudget = compactTokenBudget
+		}
+	}
+	if *tokenBudget > 0 {
+		var dropped int
+		if opts, gc, dropped = fitTokenBudget(opts, gc, *tokenBudget); dropped > 0 {
+			infof("Trimmed %d log lines and diff hunks to fit --token-budget %d.", dropped, *tokenBudget)
+		}
+		if n := estimateTokens(generator.SystemPrompt(opts) + generator.UserPrompt(opts, gc)); n > *tokenBudget {
+			warnf("Prompt is still about %d tokens, over --token-budget %d.", n, *tokenBudget)
+		}
+	}
+
+	post := postProcess{Prepend: *prependText, Append: *appendText, Width: opts.BodyWidth, Case: subjectCase, StripPeriod: !*keepPeriod, MaxTokens: opts.MaxTokens, NormalizeSubject: *normalizeSubject, NormalizeBody: *normalizeBody}
+	if *emojiFlag || cfg.Style == generator.StyleGitmoji {
+		known := slices.Concat(generator.CommitTypes, cfg.LintTypes, slices.Collect(maps.Keys(cfg.Templates)))
+		var unknown []string
+		post.Emoji, unknown = emojiMap(cfg.Emoji, known)
+		for _, t := range unknown {
+			warnf("The emoji map names an unknown commit type %q.", t)
+		}
+	}
+	post.ReleaseTool = releaseTool
+	if *wip {
+		// A checkpoint: one short untyped line behind the WIP marker.
+		if *long {
+			fatalf("--wip and --long are mutually exclusive")
+		}
+		opts.Style, opts.SingleLine, opts.Body = generator.StyleSimple, true, false
+		opts.Templates, opts.Type = nil, ""
+		opts.WIP = true
+		post.Prepend = strings.TrimSpace(cmp.Or(cfg.WIPPrefix, "wip:") + " " + post.Prepend)
+		post.StripType = true
+	}
+	if *noteRef != "" {
+		// A note is free text, not a commit message: no subject rules.
+		opts.SystemPrompt = notePrompt
+		opts.Templates, opts.Type = nil, ""
+		opts.SubjectCase = generator.CasePreserve
+		opts.SingleLine, opts.Body = false, false
+		post = postProcess{MaxTokens: opts.MaxTokens}
+	}
+	if opts.BreakingCheck && opts.Style != generator.StyleSimple {
+		post.Breaking, post.BreakingFooter = opts.Breaking, !opts.SingleLine && !opts.SubjectOnly
+	}
+	if !*noScrub {
+		root, _ := runGit("rev-parse", "--show-toplevel")
+		post.Scrub = &scrubber{Root: root, Patterns: redactPatterns}
+	}
+	// A --wip checkpoint or a note isn't held to the commit lint rules.
+	// --ci fails a message that breaks them, whether or not --lint tried to
+	// fix it first.
+	var lint, ciLint *lintRules
+	if !*wip && *noteRef == "" {
+		rules := &lintRules{MaxSubject: maxSubjectLen, Types: styleTypes(opts.Style, cfg), Case: subjectCase, AllowPeriod: *keepPeriod, BodyWidth: cmp.Or(opts.BodyWidth, lintBodyWidth), ReleaseTool: releaseTool, Emoji: post.Emoji}
+		if *lintFlag || cfg.Lint {
+			lint = rules
+		}
+		if ciMode {
+			ciLint = rules
+		}
+	}
+
+	if *compareModels != "" {
+		if *offline {
+			fatalf("--compare-models needs models and cannot be used with --offline")
+		}
+		var models []string
+		for _, m := range strings.Split(*compareModels, ",") {
+			resolved, _, err := generator.ResolveModel(*providerFlag, strings.TrimSpace(m), true)
+			if err != nil {
+				fatalf("%v", err)
+			}
+			models = append(models, resolved)
+		}
+		runCompare(ctx, models, opts, post, gc, gatherElapsed, *gitConcurrency, *compareJSON)
+		return
+	}
+
+	if *watch {
+		if *offline || *interactive || dr.History || *githubPR != "" {
+			fatalf("--watch describes pending changes with a model; it can't be combined with --offline, -i, --github-pr, or a revision range")
+		}
+		refresh := func() (gitctx.CommitContext, generator.Options) {
+			gc, _ := excludeFiles(collectGitData(diffArgs, *gitConcurrency), excludePatterns)
+			gc, _ = withholdSensitive(gc)
+			gc, _ = redactSecrets(gc)
+			o := opts
+			o.WhitespaceOnly = !hasHunks(gc.DiffNoWS) && hasHunks(gc.Diff)
+			if *ignoreWhitespace && !o.WhitespaceOnly {
+				gc.Diff = gc.DiffNoWS
+			}
+			gc.Diff = omitLongLines(gc.Diff, *maxLineLength)
+			if *maxFiles > 0 {
+				gc.Diff = limitDiffFiles(gc.Diff, *maxFiles)
+			}
+			o.Scopes = scopesFor(cfg.Scopes, scopeFrom, gc.NameStatus)
+			return gc, o
+		}
+		runWatch(ctx, g, opts, post, gc, refresh, *watchFile)
+		return
+	}
+
+	if *split {
+		if dr.History {
+			fatalf("--split works on pending changes, not on existing commits")
+		}
+		if *offline {
+			fatalf("--split needs a model and cannot be used with --offline")
+		}
+		runSplit(ctx, g, opts, post, cfg.Style, splitMode, diffArgs, gc, reader, *gitConcurrency, *autoSplitCommit, *interactive, conflicts && !*force)
 		return
 	}
 
-	ctx := context.Background()
-	g := genkit.Init(ctx,
-		genkit.WithPlugins(&googlegenai.GoogleAI{}),
-		genkit.WithDefaultModel(MODEL),
-	)
+	if !*offline && target.Kind != "fixup" {
+		calls := 1
+		if *interactive {
+			calls = cmp.Or(*candidates, 3)
+		}
+		if err := checkPromptBudget(modelName, opts, gc, calls, *maxPromptTokens, *maxCost); err != nil {
+			fatalf("%v", err)
+		}
+		if *dryRun {
+			printDryRun(modelName, opts, gc, calls)
+			return
+		}
+	}
 
-	var commitMessage string
+	var chosen generator.Suggestion
+	var cached bool      // reused from the message cache, no model call
+	var usage *usageJSON // tokens and cost of the generation, nil without one
+	var outcome runOutcome
+	var genElapsed time.Duration
+	genStart := time.Now()
 
-	if *interactive {
-		fmt.Print("Generating 3 suggestions...")
+	if target.Kind == "fixup" {
+		chosen = generator.Suggestion{Message: target.message(""), Attempts: 1}
+		fmt.Printf("\n%s\n", colorMessage(chosen.Message))
+	} else if *offline {
+		chosen = generator.Suggestion{Message: post.apply(heuristicMessage(cfg.Style, gc, opts.Scopes)), Attempts: 1}
+		if target.Kind == "squash" {
+			chosen.Message = target.message(chosen.Message)
+		}
+		warnf("Heuristic message (%s): built from the file list, no model was used.", offlineReason)
+		fmt.Printf("\n%s\n", colorMessage(chosen.Message))
+	} else if *interactive {
+		n := cmp.Or(*candidates, 3)
+		fmt.Printf("Generating %d suggestions...", n)
 
 		type result struct {
-			msg string
+			sg  generator.Suggestion
 			err error
 		}
-		results := make(chan result, 3)
-		for range 3 {
+		// Each request writes its own slot, so the list keeps a stable
+		// order for --select.
+		results := make([]result, n)
+		var wg sync.WaitGroup
+		for i := range n {
+			wg.Add(1)
 			go func() {
-				msg, err := generateMessage(ctx, g, cfg.Style, gitStatus, currentBranch, gitLog, diff)
-				results <- result{msg, err}
+				defer wg.Done()
+				sg, err := withFallback(ctx, g, opts, cfg.FallbackModels, func(g *generator.Generator, o generator.Options) (generator.Suggestion, error) {
+					return g.Generate(ctx, o, gc)
+				})
+				results[i] = result{sg, err}
 			}()
 		}
+		wg.Wait()
 
-		var messages []string
-		for range 3 {
-			r := <-results
-			if r.err == nil && r.msg != "" {
-				messages = append(messages, r.msg)
+		var suggestions []generator.Suggestion
+		var lastErr error
+		for _, r := range results {
+			if r.err == nil && r.sg.Message != "" {
+				r.sg.Message = post.apply(r.sg.Message)
+				suggestions = append(suggestions, r.sg)
+			} else if r.err != nil {
+				lastErr = r.err
 			}
 		}
 
-		if len(messages) == 0 {
-			log.Fatal("Failed to generate any commit messages.")
+		if len(suggestions) == 0 {
+			if lastErr != nil {
+				errorf("Failed to generate any commit messages: %v", lastErr)
+			} else {
+				errorf("Failed to generate any commit messages.")
+			}
+			os.Exit(exitGenerationFailed)
 		}
 
-		commitMessage = pickInteractive(messages)
+		genElapsed = time.Since(genStart)
+		usage = usageOf(modelName, opts, gc, suggestions...)
+		for _, sg := range suggestions {
+			outcome.add(sg.Message)
+		}
+		regen := func(m string) (generator.Suggestion, error) {
+			fmt.Printf("Generating with %s...", m)
+			o := opts
+			o.Model = m
+			sg, err := newGenerator(ctx, m).Generate(ctx, o, gc)
+			sg.Message = post.apply(sg.Message)
+			sg.Model = m
+			if err == nil {
+				outcome.regenerated(sg.Message)
+			}
+			return sg, err
+		}
+		models := func() []string {
+			list, _, err := listModels(ctx, generator.ProviderOf(modelName))
+			if err != nil {
+				warnf("%v", err)
+			}
+			return list
+		}
+		if *selectN > 0 {
+			if *selectN > len(suggestions) {
+				errorf("--select %d: only %d of %d candidates were generated.", *selectN, len(suggestions), n)
+				os.Exit(exitGenerationFailed)
+			}
+			chosen = suggestions[*selectN-1]
+			fmt.Printf("\n\n%s\n", colorMessage(chosen.Message))
+		} else {
+			chosen = pickInteractive(suggestions, reader, regen, models)
+		}
 	} else {
 		fmt.Print("Generating commit message...")
-		var err error
-		commitMessage, err = generateMessage(ctx, g, cfg.Style, gitStatus, currentBranch, gitLog, diff)
+		var checks []string
+		if *verify {
+			checks = append(checks, "verify")
+		}
+		if lint != nil {
+			checks = append(checks, fmt.Sprintf("lint%+v", *lint))
+		}
+		key := generationCacheKey(opts, gc, checks...)
+		var ok bool
+		if !*forceRegenerate {
+			chosen, ok = loadCachedMessage(key)
+		}
+		if ok {
+			cached = true
+			debugf("Using cached message %s", key)
+		} else {
+			var err error
+			// Only this single generation streams; parallel ones would
+			// interleave their output.
+			streamed := opts
+			if *stream {
+				streamed.OnChunk = streamChunks()
+			}
+			chosen, err = withFallback(ctx, g, streamed, cfg.FallbackModels, func(g *generator.Generator, o generator.Options) (generator.Suggestion, error) {
+				generate := func() (generator.Suggestion, error) {
+					if *verify {
+						return generateVerified(ctx, g, o, gc)
+					}
+					return g.Generate(ctx, o, gc)
+				}
+				if lint != nil {
+					return generateLinted(generate, post, *lint)
+				}
+				return generate()
+			})
+			if err != nil {
+				errorf("Generation failed: %v (pass --offline for a basic message)", err)
+				os.Exit(exitGenerationFailed)
+			}
+			if err := storeCachedMessage(key, chosen); err != nil {
+				debugf("Failed to cache message: %v", err)
+			}
+			usage = usageOf(chosen.Model, opts, gc, chosen)
+		}
+		genElapsed = time.Since(genStart)
+		chosen.Message = post.apply(chosen.Message)
+		if target.Kind == "squash" {
+			chosen.Message = target.message(chosen.Message)
+		}
+		fmt.Printf("\n\n%s\n", colorMessage(chosen.Message))
+	}
+	if !*interactive {
+		outcome.add(chosen.Message)
+	}
+	if usage != nil {
+		infof("Used %s.", usage)
+	}
+	printRationale(chosen)
+	for _, p := range releaseProblems(chosen.Message, opts.ReleaseTool) {
+		warnf("%s: %s.", opts.ReleaseTool, p)
+	}
+	// checkCILint fails a --ci run whose message, as git would record it,
+	// breaks the lint rules.
+	checkCILint := func(msg string) {
+		if ciLint == nil {
+			return
+		}
+		if problems := lintMessage(commitText(msg, cfg.Style), *ciLint); len(problems) > 0 {
+			errorf("The message breaks the lint rules: %s.", strings.Join(problems, "; "))
+			os.Exit(exitInvalidMessage)
+		}
+	}
+	if types := secondaryTypes(chosen.Message); opts.MultiType && len(types) > 0 && !dr.History {
+		infof("The change also includes %s work; --split can commit the parts separately.", strings.Join(types, " and "))
+	}
+	if _, err := os.Stat(dumpPath); dumpPath != "" && err == nil {
+		infof("Prompts and responses written to %s", dumpPath)
+	}
+	if *interactive && !*offline && *selectN == 0 {
+		if msg := offerShorten(ctx, g, opts, post, chosen.Message, reader); msg != chosen.Message {
+			outcome.add(msg)
+			chosen.Message = msg
+		}
+	}
+	// recordHistory appends the run to the history log, when it is on,
+	// with what became of the message.
+	recordHistory := func(result string) {
+		if !*history && !cfg.History || noModel || cached {
+			return
+		}
+		e := historyEntry{
+			Time:          genStart,
+			Repo:          repo.Top,
+			Provider:      generator.ProviderOf(cmp.Or(chosen.Model, modelName)),
+			Model:         cmp.Or(chosen.Model, modelName),
+			PromptTokens:  estimateTokens(generator.SystemPrompt(opts) + generator.UserPrompt(opts, gc)),
+			ElapsedMs:     genElapsed.Milliseconds(),
+			Retries:       chosen.Attempts - 1,
+			Outcome:       result,
+			Regenerations: outcome.regenerations,
+		}
+		if usage != nil {
+			e.InputTokens, e.OutputTokens, e.CostUSD = usage.InputTokens, usage.OutputTokens, usage.CostUSD
+		}
+		if err := appendHistory(e); err != nil {
+			warnf("Failed to write history: %v", err)
+		}
+	}
+	forceCommit := false
+	if *tuiFlag {
+		regen := func() (string, error) {
+			if *offline {
+				return post.apply(heuristicMessage(cfg.Style, gc, opts.Scopes)), nil
+			}
+			sg, err := g.Generate(ctx, opts, gc)
+			if err != nil {
+				return "", err
+			}
+			msg := post.apply(sg.Message)
+			outcome.regenerated(msg)
+			return msg, nil
+		}
+		msg, result, err := runTUI(gc, chosen.Message, regen)
 		if err != nil {
-			log.Fatalf("Generation failed: %v", err)
+			fatalf("%v", err)
+		}
+		if result == tuiCancel || msg == "" {
+			recordHistory(outcomeAborted)
+			fmt.Println("Aborted.")
+			return
+		}
+		chosen.Message = msg
+		forceCommit = result == tuiCommit
+	}
+	if *review {
+		regen := func(instructions string) (string, error) {
+			if *offline {
+				return post.apply(heuristicMessage(cfg.Style, gc, opts.Scopes)), nil
+			}
+			o := opts
+			if instructions != "" {
+				o.PromptAppend = strings.TrimSpace(o.PromptAppend + "\n" + instructions)
+			}
+			sg, err := g.Generate(ctx, o, gc)
+			if err != nil {
+				return "", err
+			}
+			msg := post.apply(sg.Message)
+			outcome.regenerated(msg)
+			return msg, nil
+		}
+		msg, accepted := reviewMessage(chosen.Message, reader, regen)
+		if !accepted {
+			recordHistory(outcomeAborted)
+			fmt.Println("Aborted.")
+			return
 		}
-		fmt.Printf("\n\n%s\n", commitMessage)
+		chosen.Message = msg
+		forceCommit = true
 	}
 
-	if cfg.Action == ActionCommit {
-		if err := exec.Command("git", "add", ".").Run(); err != nil {
-			log.Fatalf("git add failed: %v", err)
+	recordHistory(outcome.of(chosen.Message))
+	warnSubjectLength(chosen.Message)
+
+	if *noteRef != "" {
+		mode, err := parseNoteMode(*noteModeFlag)
+		if err != nil {
+			fatalf("%v", err)
 		}
-		if err := gitCommit(commitMessage, cfg.Style); err != nil {
-			log.Fatalf("git commit failed: %v", err)
+		if err := writeNote(*noteRef, revSHA, chosen.Message, mode); err != nil {
+			gitFatalf("git notes failed: %v", err)
 		}
-	} else {
-		clipContent := formatForClipboard(commitMessage, cfg.ClipFormat)
-		if err := clipboard.WriteAll(clipContent); err != nil {
-			log.Fatalf("Failed to copy to clipboard: %v", err)
+		fmt.Println("\n" + success(fmt.Sprintf("Note added to %s under refs/notes/%s.", revSHA[:min(len(revSHA), 12)], *noteRef)))
+		return
+	}
+	if *stash != "" {
+		return // the summary has been printed; there is nothing to commit
+	}
+	if *stashSave {
+		checkCILint(chosen.Message)
+		if err := stashPush(chosen.Message, dr.Spec == RangeStaged, fileList); err != nil {
+			gitFatalf("git stash push failed: %v", err)
+		}
+		fmt.Println("\n" + success("Changes stashed."))
+		return
+	}
+
+	commitMessage := chosen.Message
+	if *appendStats && gc.NameStatus != "" {
+		commitMessage += "\n\n" + statFooter(gc.NameStatus, fullDiff)
+	}
+	if closeKeyword != "" && target.Kind != "fixup" {
+		commitMessage = withCloseTrailers(commitMessage, closeKeyword, issueRefs(gc.Branch, notes))
+	}
+	if tickets != nil && target.Kind != "fixup" {
+		commitMessage = tickets.apply(commitMessage, gc.Branch)
+	}
+	if len(trailers) > 0 && target.Kind != "fixup" {
+		by := cmp.Or(chosen.Model, modelName)
+		if *offline {
+			by = "heuristics"
+		}
+		commitMessage = withTrailers(commitMessage, trailers, by)
+	}
+	if *changeID {
+		previous := opts.KeepBody
+		if revSHA != "" {
+			if body, err := runGit("log", "-1", "--format=%B", revSHA); err == nil {
+				previous = body
+			}
+		}
+		commitMessage = withChangeID(commitMessage, previous)
+	}
+	checkCILint(commitMessage)
+
+	var template string
+	if !*ignoreGitTemplate && revSHA == "" && target.Kind == "" {
+		if template, err = loadCommitTemplate(*templateFile); err != nil {
+			warnf("Could not read commit template (%v); ignoring it.", err)
+		}
+	}
+
+	sinks, explicitSinks := outputSinks(*toStdout, *toClipboard, *toEditMsg, *outputFile, clipboardSink{Format: cfg.ClipFormat, OSC52: *osc52 || cfg.OSC52}, quietOut)
+	if !*noClipboard && !clipboardAvailable(*osc52 || cfg.OSC52) && slices.ContainsFunc(sinks, func(s outputSink) bool { _, ok := s.(clipboardSink); return ok }) {
+		infof("No clipboard tool (xclip, xsel, or wl-copy) found; printing the message instead.")
+		*noClipboard = true
+	}
+	if *noClipboard {
+		sinks = withoutClipboard(sinks, quietOut)
+	}
+	if jsonOut != nil && !explicitSinks {
+		// The JSON replaces the clipboard unless that is asked for too.
+		sinks, explicitSinks = nil, true
+	}
+	action := effectiveAction(cfg.Action, explicitSinks, forceCommit || *commitFlag || *rewordLast || *dryCommitFlag)
+	if dr.History && revSHA == "" && action == ActionCommit {
+		if *patchFile != "" {
+			warnf("\nA patch file isn't applied here; copying the message instead of committing.")
+		} else {
+			warnf("\nThese changes are already committed; copying the message instead of committing.")
+		}
+		action = ActionClipboard
+	}
+
+	if jsonOut != nil {
+		if err := writeMessageJSON(jsonOut, commitText(commitMessage, cfg.Style), usage); err != nil {
+			fatalf("Failed to write JSON: %v", err)
+		}
+	}
+
+	if *dryCommitFlag && action == ActionCommit && (revSHA == "" || revIsHead) {
+		dryCommit = true
+		fmt.Println("\n" + header("Dry run (--dry-commit): nothing is staged or committed."))
+		fmt.Println("Files:\n" + indent(gc.NameStatus))
+		fmt.Println("Commands:")
+	}
+
+	switch {
+	case revSHA != "" && !revIsHead:
+		fmt.Println("\n" + warn("Only HEAD can be amended directly; use this message in a `git rebase -i` reword step."))
+	case revSHA != "" && action == ActionCommit && *amendFlag && !dryCommit && !confirmAmend(reader):
+		fmt.Println("HEAD left unchanged.")
+	case revSHA != "" && action == ActionCommit:
+		// Only the message changes: the staged changes aren't in the diff it
+		// was written for.
+		amend := []string{"--amend", "--only"}
+		if *signoff {
+			amend = append(amend, "--signoff")
+		}
+		if err := gitCommit(commitMessage, cfg.Style, amend...); err != nil {
+			gitFatalf("git commit --amend failed: %v", err)
+		}
+	case action == ActionCommit:
+		// In staged mode the message describes only the index, so commit
+		// exactly that instead of staging everything.
+		// With --files-from only the listed files are staged and committed.
+		// git commit -- <paths> would take them from the working tree, so the
+		// staged mode commits the index as is.
+		var only []string
+		switch {
+		case len(fileList) == 0:
+		case dr.Spec != RangeStaged:
+			only = append([]string{"--"}, fileList...)
+		default:
+			if extra := unlistedChanges(fileList, dr.Args); len(extra) > 0 {
+				warnf("The index also has changes to %s; committing the whole index.", strings.Join(extra, ", "))
+			}
+		}
+		if dr.Spec != RangeStaged {
+			add := []string{"add", "."}
+			if only != nil {
+				add = append([]string{"add"}, only...)
+			}
+			if dryCommit {
+				previewGit(add...)
+			} else if _, err := runGit(add...); err != nil {
+				gitFatalf("git add failed: %v", err)
+			}
+		}
+		if emptyCommit {
+			only = append([]string{"--allow-empty"}, only...)
+		}
+		if *signoff {
+			only = append([]string{"--signoff"}, only...)
+		}
+		var err error
+		switch {
+		case target.Kind != "":
+			err = target.commit(commitText(commitMessage, cfg.Style), only...)
+		case template != "":
+			// Comments from the template are kept in the file and stripped
+			// by git, as when committing through the editor.
+			err = commitWithMessage(mergeTemplate(template, commitText(commitMessage, cfg.Style)), append([]string{"--cleanup=strip"}, only...)...)
+		default:
+			err = gitCommit(commitMessage, cfg.Style, only...)
+		}
+		if err != nil {
+			gitFatalf("git commit failed: %v", err)
+		}
+	default:
+		if template != "" && !dr.History {
+			commitMessage = stripComments(mergeTemplate(template, commitMessage))
+		}
+		for _, sink := range sinks {
+			if err := sink.Write(commitMessage); err != nil {
+				fatalf("%v", err)
+			}
 		}
-		fmt.Println("\nCommit message copied to clipboard!")
 	}
 
 	if msg, ok := <-updateCh; ok {
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+const (
+	// mapReduceConcurrency bounds how many summaries are generated at once.
+	mapReduceConcurrency = 4
+	// mapReduceFanIn is how many summaries one reduce step combines.
+	mapReduceFanIn = 20
+)
+
+const (
+	fileSummaryPrompt  = "You summarize one file's part of a git diff for someone writing the commit message.\nIn one or two short sentences, say what changed in this file and why, if the diff shows it. Return ONLY the summary."
+	groupSummaryPrompt = "You combine summaries of the files of a large git change.\nIn a few short sentences, say what the change as a whole does, keeping the details a commit message would mention. Return ONLY the summary."
+)
+
+// summarize generates one map or reduce summary. Summaries are cached like
+// messages, keyed by the model and their input, so unchanged files cost
+// nothing on a re-run; cached reports whether the cache was used.
+func summarize(ctx context.Context, g *generator.Generator, opts generator.Options, system, input string) (summary string, cached bool, err error) {
+	key := messageCacheKey(opts.Model, system, input)
+	if sg, ok := loadCachedMessage(key); ok {
+		return sg.Message, true, nil
+	}
+	summary, err = g.Ask(ctx, opts, system, input)
+	if err != nil {
+		return "", false, err
+	}
+	if summary == "" {
+		return "", false, generator.ErrEmptyMessage
+	}
+	if err := storeCachedMessage(key, generator.Suggestion{Message: summary}); err != nil {
+		debugf("Failed to cache summary: %v", err)
+	}
+	return summary, false, nil
+}
+
+// summarizeAll runs summarize over inputs, at most mapReduceConcurrency at a
+// time, keeping their order.
+func summarizeAll(ctx context.Context, g *generator.Generator, opts generator.Options, system string, inputs []string) (summaries []string, calls int, err error) {
+	summaries = make([]string, len(inputs))
+	errs := make([]error, len(inputs))
+	hits := make([]bool, len(inputs))
+	sem := make(chan struct{}, mapReduceConcurrency)
+	var wg sync.WaitGroup
+	for i, in := range inputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			summaries[i], hits[i], errs[i] = summarize(ctx, g, opts, system, in)
+		}()
+	}
+	wg.Wait()
+	for i := range inputs {
+		if errs[i] != nil {
+			return nil, 0, errs[i]
+		}
+		if !hits[i] {
+			calls++
+		}
+	}
+	return summaries, calls, nil
+}
+
+// mapReduceDiff condenses a diff too large for one prompt: every file is
+// summarized on its own, then groups of mapReduceFanIn summaries are combined
+// until one list of at most that many remains. The result replaces the diff
+// in the prompt.
+func mapReduceDiff(ctx context.Context, g *generator.Generator, opts generator.Options, diff string) (string, error) {
+	files := gitctx.SplitFiles(diff)
+	inputs := make([]string, len(files))
+	for i, f := range files {
+		inputs[i] = f.Text
+	}
+	summaries, calls, err := summarizeAll(ctx, g, opts, fileSummaryPrompt, inputs)
+	if err != nil {
+		return "", fmt.Errorf("summarizing files: %w", err)
+	}
+	total := len(files)
+	lines := make([]string, len(files))
+	for i, f := range files {
+		lines[i] = "- " + f.Path + ": " + summaries[i]
+	}
+	for len(lines) > mapReduceFanIn {
+		var groups []string
+		for start := 0; start < len(lines); start += mapReduceFanIn {
+			groups = append(groups, strings.Join(lines[start:min(start+mapReduceFanIn, len(lines))], "\n"))
+		}
+		combined, n, err := summarizeAll(ctx, g, opts, groupSummaryPrompt, groups)
+		if err != nil {
+			return "", fmt.Errorf("combining summaries: %w", err)
+		}
+		calls += n
+		total += len(groups)
+		lines = make([]string, len(combined))
+		for i, s := range combined {
+			lines[i] = "- " + s
+		}
+	}
+	infof("Summarized %d files: %d model calls, %d summaries from the cache.", len(files), calls, total-calls)
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// maxSubjectLen is the subject length the prompts ask the model to stay
+// under, changed by --max-subject-length or max_subject_length.
+var maxSubjectLen = generator.DefaultSubjectLimit
+
+// postProcess holds the deterministic edits applied to every generated
+// message before it is shown, copied, or committed.
+type postProcess struct {
+	Prepend string // text placed before the subject
+	Append  string // text placed after the subject
+	Width   int    // wrap the body at this column; 0 leaves it as generated
+	Case    generator.SubjectCase
+	// StripPeriod removes a trailing "." from the subject; the body is never
+	// touched.
+	StripPeriod bool
+	MaxTokens   int // truncate the body to fit; 0 means no limit
+	// NormalizeSubject and NormalizeBody replace smart quotes, dashes,
+	// non-breaking spaces and similar characters with ASCII.
+	NormalizeSubject bool
+	NormalizeBody    bool
+	// StripType drops a type(scope): prefix from the subject, for subjects
+	// that get their own marker such as --wip.
+	StripType bool
+	// Emoji maps types to the emoji put in front of the subject, see
+	// withEmoji; nil adds none.
+	Emoji map[string]string
+	// Scrub removes paths and secrets the model put in the message, with a
+	// warning; nil keeps the message as generated.
+	Scrub *scrubber
+	// ReleaseTool fixes misspelled breaking change footers, see
+	// fixBreakingFooters.
+	ReleaseTool generator.ReleaseTool
+	// Breaking lists the breaking changes found in the diff, which the
+	// message must be marked with; BreakingFooter also adds a BREAKING
+	// CHANGE footer when the model wrote none. See markBreaking.
+	Breaking       []string
+	BreakingFooter bool
+}
+
+func (p postProcess) apply(msg string) string {
+	if p.Scrub != nil {
+		var found []string
+		if msg, found = p.Scrub.scrub(msg); len(found) > 0 {
+			warnf("Scrubbed %s from the generated message.", strings.Join(found, ", "))
+		}
+	}
+	if p.NormalizeSubject || p.NormalizeBody {
+		msg = normalizeUnicode(msg, p.NormalizeSubject, p.NormalizeBody)
+	}
+	if p.ReleaseTool != "" {
+		msg = fixBreakingFooters(msg)
+	}
+	if !p.StripType {
+		msg = markBreaking(msg, p.Breaking, p.BreakingFooter)
+	}
+	subject, rest := generator.SplitMessage(applySubjectCase(msg, p.Case))
+	if p.StripPeriod {
+		subject = stripPeriod(subject)
+	}
+	if p.StripType {
+		if loc := typePrefixRe.FindStringIndex(subject); loc != nil {
+			subject = strings.TrimSpace(subject[loc[1]:])
+		}
+	}
+	if p.Emoji != nil {
+		subject = withEmoji(subject, p.Emoji)
+	}
+	if p.Prepend != "" {
+		subject = p.Prepend + " " + subject
+	}
+	if p.Append != "" {
+		subject = subject + " " + p.Append
+	}
+	return truncateBody(wrapBody(generator.JoinMessage(subject, rest), p.Width), p.MaxTokens)
+}
+
+// warnSubjectLength prints a warning when the subject exceeds maxSubjectLen.
+func warnSubjectLength(msg string) {
+	subject, _ := generator.SplitMessage(msg)
+	if n := displayWidth(subject); n > maxSubjectLen {
+		warnf("Subject is %d characters (limit %d).", n, maxSubjectLen)
+	}
+}
+
+// footerRe matches the first line of a footer, a git trailer such as
+// "Refs: X" or a BREAKING CHANGE note.
+var footerRe = regexp.MustCompile(`^([\w-]+|BREAKING CHANGE): `)
+
+// wrapBody re-flows the body (everything after the subject) to width columns.
+// Bullet lines ("- " or "* ") wrap with a hanging indent, and footers in the
+// last paragraph with indented continuation lines, which git and release
+// tools read as part of the footer. Indented lines are left alone.
+func wrapBody(msg string, width int) string {
+	subject, rest := generator.SplitMessage(msg)
+	if rest == "" || width <= 0 {
+		return msg
+	}
+	lines := strings.Split(rest, "\n")
+	footers := len(lines)
+	for footers > 0 && strings.TrimSpace(lines[footers-1]) != "" {
+		footers--
+	}
+	var out []string
+	for i, line := range lines {
+		out = append(out, wrapLine(line, width, i >= footers && footerRe.MatchString(line))...)
+	}
+	return generator.JoinMessage(subject, strings.Join(out, "\n"))
+}
+
+func wrapLine(line string, width int, footer bool) []string {
+	if utf8.RuneCountInString(line) <= width || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+		return []string{line}
+	}
+	indent := ""
+	if footer || strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ") {
+		indent = "  "
+	}
+	var lines []string
+	cur := ""
+	for _, word := range strings.Fields(line) {
+		switch {
+		case cur == "":
+			cur = word
+		case utf8.RuneCountInString(cur)+1+utf8.RuneCountInString(word) > width:
+			lines = append(lines, cur)
+			cur = indent + word
+		default:
+			cur += " " + word
+		}
+	}
+	return append(lines, cur)
+}
+
+// applySubjectCase adjusts the first letter of the subject description (the
+// text after any "type(scope):" prefix). Words that look like acronyms, such
+// as "API", are left alone when lowercasing.
+func applySubjectCase(msg string, c generator.SubjectCase) string {
+	if c != generator.CaseLower && c != generator.CaseSentence {
+		return msg
+	}
+	subject, rest := generator.SplitMessage(msg)
+	start := 0
+	if loc := typePrefixRe.FindStringIndex(subject); loc != nil {
+		start = loc[1]
+	}
+	for start < len(subject) && subject[start] == ' ' {
+		start++
+	}
+	desc := []rune(subject[start:])
+	if len(desc) == 0 {
+		return msg
+	}
+	if c == generator.CaseLower {
+		if len(desc) > 1 && unicode.IsUpper(desc[1]) {
+			return msg
+		}
+		desc[0] = unicode.ToLower(desc[0])
+	} else {
+		desc[0] = unicode.ToUpper(desc[0])
+	}
+	return generator.JoinMessage(subject[:start]+string(desc), rest)
+}
+
+// stripPeriod removes a single trailing period, leaving ellipses alone.
+func stripPeriod(subject string) string {
+	subject = strings.TrimRight(subject, " ")
+	if strings.HasSuffix(subject, ".") && !strings.HasSuffix(subject, "..") {
+		return strings.TrimSuffix(subject, ".")
+	}
+	return subject
+}
+
+// truncateBody trims the body so the whole message stays within about
+// maxTokens (see estimateTokens). The subject is never shortened. Whole lines
+// are kept where possible, otherwise the overflowing line is cut at its last
+// sentence end that fits.
+func truncateBody(msg string, maxTokens int) string {
+	subject, rest := generator.SplitMessage(msg)
+	if maxTokens <= 0 || estimateTokens(msg) <= maxTokens {
+		return msg
+	}
+	budget := maxTokens*4 - len(subject)
+	var kept []string
+	used := 0
+	for _, line := range strings.Split(rest, "\n") {
+		if used+len(line)+1 <= budget {
+			kept = append(kept, line)
+			used += len(line) + 1
+			continue
+		}
+		if cut := lastSentenceEnd(line, budget-used-1); cut > 0 {
+			kept = append(kept, line[:cut])
+		}
+		break
+	}
+	return generator.JoinMessage(subject, strings.TrimRight(strings.Join(kept, "\n"), "\n "))
+}
+
+// lastSentenceEnd returns the index just past the last ". ", "! ", or "? "
+// sentence end within the first limit bytes of s, or 0 if there is none.
+func lastSentenceEnd(s string, limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+	if limit >= len(s) {
+		return len(s)
+	}
+	best := 0
+	for i := 0; i+1 < limit; i++ {
+		if (s[i] == '.' || s[i] == '!' || s[i] == '?') && s[i+1] == ' ' {
+			best = i + 1
+		}
+	}
+	return best
+}
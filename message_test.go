@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestWrapBody(t *testing.T) {
+	tests := []struct {
+		name, msg, want string
+	}{
+		{
+			"prose and bullets",
+			"fix: retry uploads\n\nUploads that time out are retried with a backoff so a slow network no longer fails the run.\n- keeps the last error for the report shown at the end",
+			"fix: retry uploads\n\nUploads that time out are retried with a backoff so a slow network no\nlonger fails the run.\n- keeps the last error for the report shown at the end",
+		},
+		{
+			"long footers",
+			"feat!: drop the v1 API\n\nThe old endpoints are gone.\n\nBREAKING CHANGE: clients of /v1 must move to /v2, which takes the same parameters but returns paged results\nCo-authored-by: Someone With A Rather Long Name <someone.with.a.long.name@example.com>",
+			"feat!: drop the v1 API\n\nThe old endpoints are gone.\n\nBREAKING CHANGE: clients of /v1 must move to /v2, which takes the same\n  parameters but returns paged results\nCo-authored-by: Someone With A Rather Long Name\n  <someone.with.a.long.name@example.com>",
+		},
+		{
+			"trailer-shaped prose before the footers",
+			"docs: explain caching\n\nNote: the cache is keyed by the diff, the model, and every option that changes the prompt.\n\nRefs: #12",
+			"docs: explain caching\n\nNote: the cache is keyed by the diff, the model, and every option that\nchanges the prompt.\n\nRefs: #12",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrapBody(tt.msg, 72); got != tt.want {
+				t.Errorf("wrapBody:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+	"google.golang.org/genai"
+)
+
+// knownModels is the curated fallback list per provider, used when live
+// listing isn't available (no API key, offline, or unsupported provider).
+var knownModels = map[string][]string{
+	"googleai": {
+		"googleai/gemini-3.1-pro-preview",
+		"googleai/gemini-3-flash-preview",
+		"googleai/gemini-3.1-flash-lite-preview",
+		"googleai/gemini-2.5-pro",
+		"googleai/gemini-2.5-flash",
+		"googleai/gemini-2.5-flash-lite",
+	},
+	"openai": {
+		"openai/gpt-4.1",
+		"openai/gpt-4.1-mini",
+		"openai/gpt-4.1-nano",
+		"openai/gpt-4o",
+		"openai/gpt-4o-mini",
+	},
+	"anthropic": {
+		"anthropic/claude-opus-4-1-20250805",
+		"anthropic/claude-sonnet-4-5-20250929",
+		"anthropic/claude-haiku-4-5-20251001",
+	},
+	"ollama": {
+		"ollama/llama3.2",
+		"ollama/qwen2.5-coder",
+		"ollama/mistral",
+	},
+}
+
+// listModels returns the model identifiers for provider, queried live when
+// possible. live reports whether the list came from the provider.
+func listModels(ctx context.Context, provider string) (models []string, live bool, err error) {
+	static := knownModels[provider]
+	if _, ok := generator.LookupProvider(provider); !ok {
+		return nil, false, fmt.Errorf("unknown provider %q (known: %s)", provider, strings.Join(generator.ProviderNames(), ", "))
+	}
+	switch provider {
+	case "googleai":
+		if models, err := listGoogleAIModels(ctx); err == nil && len(models) > 0 {
+			return models, true, nil
+		}
+	case "ollama":
+		if models, err := ollamaModels(ctx); err == nil && len(models) > 0 {
+			return models, true, nil
+		}
+	}
+	return static, false, nil
+}
+
+func listGoogleAIModels(ctx context.Context) ([]string, error) {
+	key := os.Getenv("GEMINI_API_KEY")
+	if key == "" {
+		key = os.Getenv("GOOGLE_API_KEY")
+	}
+	if key == "" {
+		return nil, fmt.Errorf("no API key")
+	}
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{APIKey: key, Backend: genai.BackendGeminiAPI})
+	if err != nil {
+		return nil, err
+	}
+	var models []string
+	for m, err := range client.Models.All(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(m.SupportedActions, "generateContent") {
+			models = append(models, "googleai/"+strings.TrimPrefix(m.Name, "models/"))
+		}
+	}
+	slices.Sort(models)
+	return models, nil
+}
+
+// runModels implements `commit models [provider|all]`.
+func runModels(ctx context.Context, args []string) {
+	providers := []string{"googleai"}
+	if len(args) > 0 {
+		if args[0] == "all" {
+			providers = generator.ProviderNames()
+		} else {
+			providers = args[:1]
+		}
+	}
+	for _, p := range providers {
+		models, live, err := listModels(ctx, p)
+		if err != nil {
+			errorf("%v", err)
+			os.Exit(1)
+		}
+		source := "curated list"
+		if live {
+			source = "live"
+		}
+		fmt.Printf("%s (%s):\n", header(p), source)
+		for _, m := range models {
+			fmt.Println("  " + m)
+		}
+	}
+}
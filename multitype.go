@@ -0,0 +1,25 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// secondaryTypeRe matches the body lines multiTypePrompt asks for.
+var secondaryTypeRe = regexp.MustCompile(`(?m)^(?:[-*] )?Also ([a-z]+):`)
+
+// secondaryTypes lists the types of the "Also <type>:" lines in msg, without
+// repeats.
+func secondaryTypes(msg string) []string {
+	_, body := generator.SplitMessage(msg)
+	var types []string
+	for _, m := range secondaryTypeRe.FindAllStringSubmatch(body, -1) {
+		if t := strings.ToLower(m[1]); !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	return types
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// applyNetwork sets up the config's proxy, CA bundle, and base URLs before
+// any request is made. The provider clients all send through
+// http.DefaultTransport and read their base URL from the environment, so
+// both are set here, and as with applyAPIKeys the environment wins.
+func applyNetwork(cfg Config) error {
+	if cfg.Proxy != "" && os.Getenv("HTTPS_PROXY") == "" && os.Getenv("https_proxy") == "" {
+		if u, err := url.Parse(cfg.Proxy); err != nil || u.Host == "" {
+			return fmt.Errorf("proxy %q isn't a URL such as http://proxy.example.com:8080", cfg.Proxy)
+		}
+		os.Setenv("HTTPS_PROXY", cfg.Proxy)
+		if os.Getenv("HTTP_PROXY") == "" && os.Getenv("http_proxy") == "" {
+			os.Setenv("HTTP_PROXY", cfg.Proxy)
+		}
+	}
+	for provider, base := range cfg.BaseURLs {
+		env := generator.ProviderBaseURLEnv[provider]
+		if env == "" {
+			return fmt.Errorf("base_urls: unknown provider %q", provider)
+		}
+		if os.Getenv(env) == "" && base != "" {
+			os.Setenv(env, base)
+		}
+	}
+	if cfg.CABundle == "" {
+		return nil
+	}
+	pem, err := os.ReadFile(cfg.CABundle)
+	if err != nil {
+		return fmt.Errorf("ca_bundle: %w", err)
+	}
+	// The bundle adds to the system's roots, so public endpoints still work
+	// alongside the gateway it is for.
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("ca_bundle: no PEM certificates in %s", cfg.CABundle)
+	}
+	transport := http.DefaultTransport.(*http.Transport)
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.RootCAs = pool
+	return nil
+}
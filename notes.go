@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// notePrompt replaces the commit message instructions when generating a git
+// note: a longer explanation that lives next to the commit, not in it.
+const notePrompt = "You explain git commits for a git note attached to the commit.\nWrite a detailed but compact explanation of the change: what changed, why, and anything a reviewer or future reader should know (risks, follow-ups, behavior changes).\nUse short paragraphs or \"- \" bullets. No subject line and no type prefix.\nReturn ONLY the note text."
+
+// NoteMode is what --note-ref does when the commit already has a note.
+type NoteMode string
+
+const (
+	NoteAppend  NoteMode = "append"  // add the new text after the existing note
+	NoteReplace NoteMode = "replace" // overwrite the existing note
+)
+
+func parseNoteMode(s string) (NoteMode, error) {
+	switch NoteMode(s) {
+	case NoteAppend, NoteReplace:
+		return NoteMode(s), nil
+	}
+	return "", fmt.Errorf("invalid note mode %q (want append or replace)", s)
+}
+
+// writeNote attaches text to sha under refs/notes/<ref>.
+func writeNote(ref, sha, text string, mode NoteMode) error {
+	f, err := os.CreateTemp("", "commit-note-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	args := []string{"notes", "--ref", ref, "append", "-F", f.Name(), sha}
+	if mode == NoteReplace {
+		args = []string{"notes", "--ref", ref, "add", "-f", "-F", f.Name(), sha}
+	}
+	_, err = runGit(args...)
+	return err
+}
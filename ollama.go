@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// PromptProfile selects how much instruction and context the prompt carries.
+type PromptProfile string
+
+const (
+	ProfileAuto    PromptProfile = "auto"    // compact for Ollama, full otherwise
+	ProfileFull    PromptProfile = "full"    // every rule and all the context
+	ProfileCompact PromptProfile = "compact" // short rules and less context, for small local models
+)
+
+func parsePromptProfile(s string) (PromptProfile, error) {
+	switch p := PromptProfile(strings.ToLower(s)); p {
+	case ProfileAuto, ProfileFull, ProfileCompact:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid prompt profile %q (want auto, full, or compact)", s)
+}
+
+// compactTokenBudget is the --token-budget of the compact profile when none
+// is set: it leaves room for the answer in the 4096-token context Ollama
+// gives a model by default.
+const compactTokenBudget = 3000
+
+// ollamaModels lists the models pulled on the Ollama server, with a
+// ":latest" tag left off as Ollama itself does when matching names.
+func ollamaModels(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, generator.OllamaAddress()+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, err
+	}
+	var models []string
+	for _, m := range tags.Models {
+		models = append(models, "ollama/"+strings.TrimSuffix(m.Name, ":latest"))
+	}
+	slices.Sort(models)
+	return models, nil
+}
+
+// checkOllama makes sure the server is up and has model before any work is
+// sent to it, so a stopped server or a typo fails at once with the fix.
+func checkOllama(ctx context.Context, model string) error {
+	models, err := ollamaModels(ctx)
+	if err != nil {
+		return fmt.Errorf("Ollama isn't reachable at %s (%v); start it with ollama serve, or set OLLAMA_HOST", generator.OllamaAddress(), err)
+	}
+	if !slices.Contains(models, strings.TrimSuffix(model, ":latest")) {
+		name := strings.TrimPrefix(model, "ollama/")
+		return fmt.Errorf("model %s isn't pulled on the Ollama server; run ollama pull %s", name, name)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/atotto/clipboard"
+)
+
+// osc52Sequence is the escape sequence asking the terminal to put text on
+// the system clipboard. Inside tmux or screen it is wrapped so the
+// multiplexer passes it on to the outer terminal.
+func osc52Sequence(text string) string {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	switch {
+	case os.Getenv("TMUX") != "":
+		return "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		return "\x1bP" + seq + "\x1b\\"
+	}
+	return seq
+}
+
+// writeOSC52 sends text to the controlling terminal as OSC 52. Whether the
+// terminal honors it can't be detected; unsupporting terminals ignore it.
+func writeOSC52(text string) error {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		if !isTerminal(os.Stderr) {
+			return errors.New("no terminal to send OSC 52 to")
+		}
+		tty = os.Stderr
+	} else {
+		defer tty.Close()
+	}
+	_, err = fmt.Fprint(tty, osc52Sequence(text))
+	return err
+}
+
+// copyToClipboard puts text on the clipboard: through the terminal with
+// osc52, else with the system clipboard tools. When those are missing in an
+// SSH session, OSC 52 is tried before giving up, since the local terminal is
+// the only clipboard in reach.
+func copyToClipboard(text string, osc52 bool) error {
+	if osc52 {
+		err := writeOSC52(text)
+		if err == nil {
+			return nil
+		}
+		warnf("OSC 52 failed (%v); using the system clipboard.", err)
+	}
+	err := clipboard.WriteAll(text)
+	if err != nil && !osc52 && inSSH() {
+		if writeOSC52(text) == nil {
+			infof("No clipboard tools in this SSH session; sent the message through the terminal (OSC 52) instead.")
+			return nil
+		}
+	}
+	return err
+}
+
+// inSSH reports whether the run is in an SSH session.
+func inSSH() bool {
+	return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+}
+
+// clipboardAvailable reports whether copyToClipboard has a way to reach a
+// clipboard: the system tools (on Linux xclip, xsel, or wl-copy), or OSC 52
+// when asked for or in an SSH session.
+func clipboardAvailable(osc52 bool) bool {
+	return !clipboard.Unsupported || osc52 || inSSH()
+}
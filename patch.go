@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+var (
+	// mboxFromRe starts each patch of a git format-patch mailbox.
+	mboxFromRe = regexp.MustCompile(`(?m)^From [0-9a-f]{40} `)
+	// patchTagRe matches the "[PATCH v2 1/3]" prefix of a mailed subject.
+	patchTagRe = regexp.MustCompile(`^\[[^\]]*\]\s*`)
+)
+
+// readPatchFile builds the prompt context from a patch file, without touching
+// any repository: either git format-patch output (one or more mails) or a
+// plain unified diff. The mails' subjects and authors become the status.
+func readPatchFile(name string) (gitctx.CommitContext, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return gitctx.CommitContext{}, err
+	}
+	gc, err := parsePatch(string(data))
+	if err != nil {
+		return gc, fmt.Errorf("%s %w", name, err)
+	}
+	return gc, nil
+}
+
+// errNoDiff is parsePatch's error for text without a diff in it.
+var errNoDiff = errors.New("contains no diff")
+
+// parsePatch is readPatchFile for patch text already read.
+func parsePatch(text string) (gitctx.CommitContext, error) {
+	text = gitctx.NormalizeNewlines(text)
+
+	var status, diffs []string
+	for _, part := range splitMailbox(text) {
+		header, diff := splitPatch(part)
+		if subject := mailHeader(header, "Subject"); subject != "" {
+			line := "Patch: " + patchTagRe.ReplaceAllString(subject, "")
+			if from := mailHeader(header, "From"); from != "" {
+				line += " (from " + from + ")"
+			}
+			status = append(status, line)
+		}
+		if diff != "" {
+			diffs = append(diffs, diff)
+		}
+	}
+	if len(diffs) == 0 {
+		return gitctx.CommitContext{}, errNoDiff
+	}
+	diff := strings.Join(diffs, "\n")
+	return gitctx.CommitContext{
+		Status:     strings.Join(status, "\n"),
+		Diff:       diff,
+		NameStatus: patchNameStatus(diff),
+	}, nil
+}
+
+// splitMailbox splits format-patch output into its mails. Anything that
+// isn't a mailbox comes back as a single part.
+func splitMailbox(text string) []string {
+	starts := mboxFromRe.FindAllStringIndex(text, -1)
+	if len(starts) == 0 {
+		return []string{text}
+	}
+	var parts []string
+	for i, s := range starts {
+		end := len(text)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		parts = append(parts, text[s[0]:end])
+	}
+	return parts
+}
+
+// splitPatch separates a patch into the part before the diff (mail headers,
+// message, diffstat) and the diff itself, without the format-patch
+// signature. A diff without "diff --git" headers gets them added, so it can
+// be split by file like git's own output.
+func splitPatch(part string) (header, diff string) {
+	lines := strings.Split(part, "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "diff --git ") {
+			start = i
+			break
+		}
+		if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return part, ""
+	}
+	body := lines[start:]
+	for i, line := range body {
+		if line == "-- " {
+			body = body[:i]
+			break
+		}
+	}
+	if !strings.HasPrefix(body[0], "diff --git ") {
+		body = addGitHeaders(body)
+	}
+	return strings.Join(lines[:start], "\n"), strings.TrimSpace(strings.Join(body, "\n"))
+}
+
+// addGitHeaders puts a "diff --git" line in front of every "---"/"+++" pair
+// of a plain unified diff.
+func addGitHeaders(lines []string) []string {
+	var out []string
+	for i, line := range lines {
+		if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			old := patchPath(line[4:])
+			cur := patchPath(lines[i+1][4:])
+			if cur == "/dev/null" {
+				cur = old
+			}
+			if old == "/dev/null" {
+				old = cur
+			}
+			out = append(out, "diff --git a/"+old+" b/"+cur)
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// patchPath strips the a/ or b/ prefix and any timestamp from a ---/+++ path.
+func patchPath(s string) string {
+	s, _, _ = strings.Cut(s, "\t")
+	if s == "/dev/null" {
+		return s
+	}
+	if _, rest, ok := strings.Cut(s, "/"); ok && (strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/")) {
+		return rest
+	}
+	return s
+}
+
+// mailHeader returns the value of a mail header, joining folded lines.
+func mailHeader(header, key string) string {
+	lines := strings.Split(header, "\n")
+	for i, line := range lines {
+		value, ok := strings.CutPrefix(line, key+": ")
+		if !ok {
+			continue
+		}
+		for _, next := range lines[i+1:] {
+			if next == "" || (next[0] != ' ' && next[0] != '\t') {
+				break
+			}
+			value += " " + strings.TrimSpace(next)
+		}
+		return strings.TrimSpace(value)
+	}
+	return ""
+}
+
+// patchNameStatus derives --name-status lines from the file headers of a git
+// diff: added, deleted, renamed, or modified.
+func patchNameStatus(diff string) string {
+	var lines []string
+	for _, f := range gitctx.SplitFiles(diff) {
+		status, path := "M", f.Path
+		var renameFrom string
+		for _, line := range strings.Split(f.Text, "\n") {
+			switch {
+			case strings.HasPrefix(line, "new file mode"):
+				status = "A"
+			case strings.HasPrefix(line, "deleted file mode"):
+				status = "D"
+			case strings.HasPrefix(line, "rename from "):
+				renameFrom = strings.TrimPrefix(line, "rename from ")
+			}
+		}
+		if renameFrom != "" {
+			lines = append(lines, "R100\t"+renameFrom+"\t"+path)
+			continue
+		}
+		lines = append(lines, status+"\t"+path)
+	}
+	return strings.Join(lines, "\n")
+}
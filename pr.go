@@ -0,0 +1,119 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// prFileTokens caps each file's part of a pull request diff unless
+// max_file_tokens is set; a branch tends to touch more than one commit does.
+const prFileTokens = 2000
+
+const prSystemPrompt = `You write GitHub pull request descriptions from a branch's commits and diff.
+Reply with the title on the first line, then a blank line, then the description in markdown:
+- The title is under 72 characters, in the imperative mood, with no trailing period and no type prefix.
+- A "## Summary" section of one to three sentences on what the change does and why.
+- A "## Changes" section listing the notable changes as "- " bullets, most important first.
+Don't invent testing steps, issue numbers, or anything the commits and diff don't show.
+Reply with nothing else: no code fences, no preamble.`
+
+// runPR implements `commit pr [--base BRANCH] [--create [--draft]]`: it
+// describes everything the current branch adds since it forked from base.
+func runPR(args []string, model string, cfg Config) {
+	fs := flag.NewFlagSet("pr", flag.ExitOnError)
+	base := fs.String("base", "", "Branch the pull request merges into (default: origin's default branch, else main or master)")
+	create := fs.Bool("create", false, "Open the pull request with gh pr create")
+	draft := fs.Bool("draft", false, "With --create, open it as a draft")
+	fs.Parse(args)
+
+	if *base == "" {
+		var err error
+		if *base, err = defaultBaseBranch(); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	mergeBase, err := runGit("merge-base", *base, "HEAD")
+	if err != nil {
+		fatalf("No common ancestor of %s and HEAD: %v", *base, err)
+	}
+	commits, err := runGit("log", "--use-mailmap", "--no-merges", "--reverse", "--format=- %s", mergeBase+"..HEAD")
+	if err != nil {
+		fatalf("git log failed: %v", err)
+	}
+	if commits == "" {
+		fatalf("HEAD has no commits that aren't on %s.", *base)
+	}
+
+	gc := collectGitData([]string{"diff", mergeBase, "HEAD"}, 4)
+	// The diff goes to the model with the same protections as a commit's.
+	sensitivePatterns = slices.Concat(defaultSensitivePaths, cfg.SensitivePaths)
+	gc, _ = withholdSensitive(gc)
+	if redactPatterns, err = compileRedactPatterns(cfg.RedactPatterns); err != nil {
+		fatalf("%v", err)
+	}
+	gc, secrets := redactSecrets(gc)
+	if len(secrets) > 0 && cfg.BlockOnSecret {
+		refuseSecrets(secrets, "block_on_secret")
+	} else if len(secrets) > 0 {
+		warnf("Redacted %d secrets from the diff.", len(secrets))
+	}
+	gc.Diff, _ = limitFileTokens(gc.Diff, cmp.Or(cfg.MaxFileTokens, prFileTokens))
+	prompt := fmt.Sprintf("Branch: %s\nBase: %s\n\nCommits, oldest first:\n%s\n\nDiff:\n%s", gc.Branch, *base, commits, gc.Diff)
+
+	opts := generator.Options{Model: model, Timeout: queryTimeout}
+	if err := checkModel(runCtx, model); err != nil {
+		errorf("%v", err)
+		os.Exit(noModelExit())
+	}
+	infof("Describing %s against %s...", gc.Branch, *base)
+	answer, err := newGenerator(runCtx, model).Ask(runCtx, opts, prSystemPrompt, prompt)
+	if err != nil {
+		errorf("Generation failed: %v", err)
+		os.Exit(exitGenerationFailed)
+	}
+	title, body := generator.SplitMessage(answer)
+	title, body = strings.TrimSpace(strings.TrimLeft(title, "# ")), strings.TrimSpace(body)
+	if title == "" {
+		errorf("The model returned no title.")
+		os.Exit(exitGenerationFailed)
+	}
+
+	if !*create {
+		fmt.Println(strings.TrimSpace(title + "\n\n" + body))
+		return
+	}
+	ghArgs := []string{"pr", "create", "--base", strings.TrimPrefix(*base, "origin/"), "--title", title, "--body", body}
+	if *draft {
+		ghArgs = append(ghArgs, "--draft")
+	}
+	cmd := exec.CommandContext(runCtx, "gh", ghArgs...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			fatalf("gh is not installed; run without --create to print the title and description")
+		}
+		fatalf("gh pr create failed: %v", err)
+	}
+}
+
+// defaultBaseBranch returns the branch pull requests usually target: the
+// one origin/HEAD points at, or else a local main or master.
+func defaultBaseBranch() (string, error) {
+	if ref, err := runGit("symbolic-ref", "--short", "refs/remotes/origin/HEAD"); err == nil && ref != "" {
+		return ref, nil
+	}
+	for _, b := range []string{"main", "master"} {
+		if _, err := runGit("rev-parse", "--verify", "--quiet", "refs/heads/"+b); err == nil {
+			return b, nil
+		}
+	}
+	return "", errors.New("can't tell which branch the pull request is for; pass --base")
+}
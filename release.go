@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+var (
+	// breakingFooterRe matches a well-formed breaking change footer.
+	breakingFooterRe = regexp.MustCompile(`(?m)^BREAKING CHANGE: `)
+	// looseBreakingRe matches it and the spellings models use instead:
+	// other cases, a hyphen, a plural, bold, or a dash for the colon.
+	looseBreakingRe = regexp.MustCompile(`(?im)^(?:\*\*)?breaking[ -]changes?(?:\*\*)?[ \t]*[:\-](?:\*\*)?[ \t]*`)
+)
+
+// fixBreakingFooters rewrites near-miss breaking change footers, such as
+// "Breaking change:" or "BREAKING-CHANGES -", to the form the tools parse.
+func fixBreakingFooters(msg string) string {
+	return looseBreakingRe.ReplaceAllString(msg, "BREAKING CHANGE: ")
+}
+
+// releaseProblems returns the ways msg would be misread by t: ignored for
+// not being a Conventional Commit, or a breaking change that isn't seen as
+// one.
+func releaseProblems(msg string, t generator.ReleaseTool) []string {
+	if t == "" {
+		return nil
+	}
+	var problems []string
+	subject, rest := generator.SplitMessage(strings.TrimSpace(msg))
+	m := typePrefixRe.FindStringSubmatch(subject)
+	if m == nil {
+		return []string{fmt.Sprintf("subject is not a Conventional Commit, so %s ignores it", t)}
+	}
+	for _, f := range looseBreakingRe.FindAllString(rest, -1) {
+		if f != "BREAKING CHANGE: " {
+			problems = append(problems, fmt.Sprintf(`footer %q must be spelled "BREAKING CHANGE: "`, strings.TrimSpace(f)))
+			break
+		}
+	}
+	paragraphs := strings.Split(strings.TrimSpace(rest), "\n\n")
+	footer := paragraphs[len(paragraphs)-1]
+	inFooter := breakingFooterRe.MatchString(footer)
+	if !inFooter && breakingFooterRe.MatchString(rest) {
+		problems = append(problems, "BREAKING CHANGE: must be in the last paragraph, after the body")
+	}
+	for _, line := range strings.Split(footer, "\n") {
+		if desc, ok := strings.CutPrefix(line, "BREAKING CHANGE:"); ok && strings.TrimSpace(desc) == "" {
+			problems = append(problems, "BREAKING CHANGE: footer has no description")
+		}
+	}
+	if t == generator.SemanticRelease && m[3] == "!" && !inFooter {
+		problems = append(problems, `subject marks a breaking change with "!", but semantic-release's default preset only reads a "BREAKING CHANGE: " footer`)
+	}
+	return problems
+}
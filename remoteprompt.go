@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// promptCacheTTL is how long a fetched shared prompt is used before it is
+// fetched again.
+const promptCacheTTL = 24 * time.Hour
+
+func promptCachePath(source string) string {
+	dir, _ := os.UserCacheDir()
+	sum := sha256.Sum256([]byte(source))
+	return filepath.Join(dir, "commit", "prompts", hex.EncodeToString(sum[:8])+".txt")
+}
+
+// loadSharedPrompt returns the system prompt published at source, which is
+// either an http(s) URL or "git:<repo>#<path>". A fresh cached copy is used
+// unless refresh is set. If fetching fails the cached copy is used regardless
+// of age, and if there is none an empty string is returned so the caller
+// falls back to the built-in prompt.
+func loadSharedPrompt(source string, refresh bool) string {
+	path := promptCachePath(source)
+	if !refresh {
+		if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) < promptCacheTTL {
+			if data, err := os.ReadFile(path); err == nil {
+				return string(data)
+			}
+		}
+	}
+
+	text, err := fetchPrompt(source)
+	if err == nil && strings.TrimSpace(text) != "" {
+		os.MkdirAll(filepath.Dir(path), 0700)
+		os.WriteFile(path, []byte(text), 0600)
+		return text
+	}
+	if err == nil {
+		err = fmt.Errorf("prompt is empty")
+	}
+
+	if data, readErr := os.ReadFile(path); readErr == nil {
+		warnf("Could not fetch shared prompt (%v); using cached copy.", err)
+		return string(data)
+	}
+	warnf("Could not fetch shared prompt (%v); using built-in prompt.", err)
+	return ""
+}
+
+func fetchPrompt(source string) (string, error) {
+	if spec, ok := strings.CutPrefix(source, "git:"); ok {
+		return fetchGitPrompt(spec)
+	}
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return "", fmt.Errorf("unsupported prompt source %q (want http(s):// or git:<repo>#<path>)", source)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(source)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", source, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return string(data), err
+}
+
+// fetchGitPrompt reads <path> at HEAD of <repo>. Local repositories are read
+// in place; anything else is shallow-cloned into a temporary directory.
+func fetchGitPrompt(spec string) (string, error) {
+	repo, file, ok := strings.Cut(spec, "#")
+	if !ok || repo == "" || file == "" {
+		return "", fmt.Errorf("invalid git prompt source %q (want git:<repo>#<path>)", spec)
+	}
+
+	// git runs at the top level, not where a relative path starts.
+	dir, _ := filepath.Abs(repo)
+	if fi, err := os.Stat(repo); err != nil || !fi.IsDir() {
+		tmp, err := os.MkdirTemp("", "commit-prompt-")
+		if err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(tmp)
+		if out, err := gitCmd("clone", "--quiet", "--depth", "1", repo, tmp).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("git clone %s: %s", repo, strings.TrimSpace(string(out)))
+		}
+		dir = tmp
+	}
+
+	out, err := runGit("-C", dir, "show", "HEAD:"+file)
+	if err != nil {
+		return "", fmt.Errorf("git show HEAD:%s in %s: %w", file, repo, err)
+	}
+	return out, nil
+}
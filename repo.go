@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// repo is the repository the run is in. git runs at its top level, so that
+// the paths of parallel commands agree wherever commit was started; the zero
+// Layout, outside a repository, runs git in the working directory.
+var repo gitctx.Layout
+
+// discoverRepo fills in repo before the first git command that depends on
+// it. GIT_DIR and GIT_WORK_TREE are made absolute, since git no longer runs
+// where they were relative to, and GIT_DIR gets its work tree spelled out,
+// which would otherwise be wherever git runs. Hooks and editors started by
+// git inherit both.
+func discoverRepo() {
+	for _, name := range []string{"GIT_DIR", "GIT_WORK_TREE"} {
+		if p := os.Getenv(name); p != "" && !filepath.IsAbs(p) {
+			if abs, err := filepath.Abs(p); err == nil {
+				os.Setenv(name, abs)
+			}
+		}
+	}
+	var l gitctx.Layout
+	var err error
+	if goGit {
+		l, err = gitctx.DiscoverGoGit(".")
+	} else {
+		l, err = gitctx.Discover(runGit)
+	}
+	if err != nil {
+		debugf("Not in a work tree: %v", err)
+		return
+	}
+	if os.Getenv("GIT_DIR") != "" && os.Getenv("GIT_WORK_TREE") == "" {
+		os.Setenv("GIT_WORK_TREE", l.Top)
+	}
+	repo = l
+	debugf("Repository %s (git directory %s)", repo.Top, repo.GitDir)
+}
+
+// gitPath is `git rev-parse --git-path name` as an absolute path, e.g. the
+// hooks directory that core.hooksPath or a linked worktree moves.
+func gitPath(name string) (string, error) {
+	p, err := runGit("rev-parse", "--git-path", name)
+	if err != nil || filepath.IsAbs(p) {
+		return p, err
+	}
+	return filepath.Abs(filepath.Join(repo.Top, p))
+}
+
+// goGit is set when the changes are read with go-git instead of the git
+// executable; see gitctx.CollectGoGit. Everything else still runs git.
+var goGit bool
+
+// chooseGitBackend sets goGit for --git-backend: auto picks go-git only
+// when there is no git to run.
+func chooseGitBackend(name string) error {
+	switch name {
+	case "auto":
+		_, err := exec.LookPath("git")
+		goGit = err != nil
+		if goGit {
+			debugf("git isn't installed; reading the changes with go-git")
+		}
+	case "exec":
+		goGit = false
+	case "go-git":
+		goGit = true
+	default:
+		return fmt.Errorf("unknown --git-backend %q (want auto, exec, or go-git)", name)
+	}
+	return nil
+}
+
+// hasStaged reports whether the index differs from HEAD. An error counts as
+// staged, so that the diff that follows reports it.
+func hasStaged() bool {
+	if goGit {
+		staged, err := gitctx.HasStagedGoGit(repo.Top)
+		return err != nil || staged
+	}
+	_, err := runGit("diff", "--staged", "--quiet")
+	return err != nil
+}
+
+// pendingContext collects the pending changes of the work tree dir is in:
+// the staged ones if there are any, like a plain run, or else all of them
+// with the untracked files. top is the work tree's top level.
+func pendingContext(ctx context.Context, dir string) (gc gitctx.CommitContext, top string, staged bool, err error) {
+	if goGit {
+		l, err := gitctx.DiscoverGoGit(dir)
+		if err != nil {
+			return gc, "", false, fmt.Errorf("%s isn't in a git work tree: %w", dir, err)
+		}
+		diffArgs := []string{"diff", "HEAD"}
+		if staged, err = gitctx.HasStagedGoGit(l.Top); err != nil || staged {
+			diffArgs, staged = []string{"diff", "--staged"}, true
+		}
+		r := gitctx.Repo{Git: gitctx.GitIn(ctx, l.Top), Debugf: debugf}
+		gc, err = r.CollectGoGit(l.Top, diffArgs)
+		return gc, l.Top, staged, err
+	}
+	l, err := gitctx.Discover(gitctx.GitIn(ctx, dir))
+	if err != nil {
+		return gc, "", false, fmt.Errorf("%s isn't in a git work tree: %w", dir, err)
+	}
+	r := gitctx.Repo{Git: gitctx.GitIn(ctx, l.Top), Debugf: debugf, Untracked: gitctx.UntrackedContent}
+	diffArgs := []string{"diff", "HEAD"}
+	if _, err := r.Git("diff", "--staged", "--quiet"); err != nil {
+		diffArgs, staged, r.Untracked = []string{"diff", "--staged"}, true, gitctx.UntrackedNone
+	}
+	gc, err = r.Collect(diffArgs, 4)
+	return gc, l.Top, staged, err
+}
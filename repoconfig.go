@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// repoConfigName is the per-repository config file, kept in the repository
+// root and usually committed so the team shares it.
+const repoConfigName = ".commitrc"
+
+// repoConfigUnsafeKeys can't be set from .commitrc: they run commands,
+// fetch URLs, hold credentials, or decide where requests with the API key
+// go, which a cloned repository shouldn't get to choose.
+var repoConfigUnsafeKeys = []string{"pre_commit_command", "prompt_url", "tracker_url", "tracker_token", "proxy", "ca_bundle", "base_urls", "api_key_env"}
+
+// withRepoConfig overlays the repository's .commitrc on the config file. It
+// uses the config file's JSON keys; keys it doesn't set keep their value, and
+// maps such as scopes are merged entry by entry.
+func withRepoConfig(c Config) Config {
+	root, err := runGit("rev-parse", "--show-toplevel")
+	if err != nil {
+		return c
+	}
+	path := filepath.Join(root, repoConfigName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c
+	}
+	var keys map[string]json.RawMessage
+	if err == nil {
+		err = json.Unmarshal(data, &keys)
+	}
+	if err != nil {
+		warnf("Ignoring %s: %v", path, err)
+		return c
+	}
+	for k := range keys {
+		if slices.Contains(repoConfigUnsafeKeys, k) {
+			warnf("Ignoring %s in %s; set it in your own config file instead.", k, path)
+			delete(keys, k)
+		}
+	}
+	// Decoding into a copy made through JSON keeps the maps of c, which
+	// json.Unmarshal would otherwise add to in place, untouched.
+	var overlay Config
+	base, _ := json.Marshal(c)
+	json.Unmarshal(base, &overlay)
+	data, _ = json.Marshal(keys)
+	if err := json.Unmarshal(data, &overlay); err != nil {
+		warnf("Ignoring %s: %v", path, err)
+		return c
+	}
+	debugf("Using %s", path)
+	return overlay
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// reposOptions are the flags of a plain run that --repos applies in every
+// repository.
+type reposOptions struct {
+	Commit      bool // commit in all of them without asking, like --commit
+	Concurrency int  // repositories worked on at once
+	Signoff     bool
+	Trailers    []string
+	Force       bool // commit in repositories with unresolved merge conflicts
+}
+
+// repoChange is one repository of a --repos run and the message for it.
+type repoChange struct {
+	Dir, Top string
+	Staged   bool
+	Files    int
+	Conflict bool // unresolved merge conflicts, see hasConflicts
+	Message  string
+	Err      error
+}
+
+// readRepoList gathers the repositories of --repos, a comma-separated list,
+// and --workspace, a file with one directory per line, relative to the
+// file's own directory, and # comments.
+func readRepoList(list, workspace string) ([]string, error) {
+	var dirs []string
+	for _, d := range strings.Split(list, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			dirs = append(dirs, d)
+		}
+	}
+	if workspace != "" {
+		data, err := os.ReadFile(workspace)
+		if err != nil {
+			return nil, err
+		}
+		base := filepath.Dir(workspace)
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(base, line)
+			}
+			dirs = append(dirs, line)
+		}
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no repositories listed")
+	}
+	return dirs, nil
+}
+
+// runRepos implements --repos and --workspace: it describes the pending
+// changes of each repository at once, with one generator for all, shows the
+// messages in a table, and commits in the repositories picked.
+func runRepos(dirs []string, model string, cfg Config, o reposOptions) {
+	sensitivePatterns = slices.Concat(defaultSensitivePaths, cfg.SensitivePaths)
+	var err error
+	if redactPatterns, err = compileRedactPatterns(cfg.RedactPatterns); err != nil {
+		fatalf("%v", err)
+	}
+	if err := checkModel(runCtx, model); err != nil {
+		errorf("%v", err)
+		os.Exit(noModelExit())
+	}
+	g := newGenerator(runCtx, model)
+	infof("Describing the changes of %d repositories with %s...", len(dirs), model)
+
+	changes := make([]repoChange, len(dirs))
+	sem := make(chan struct{}, max(o.Concurrency, 1))
+	var wg sync.WaitGroup
+	for i, dir := range dirs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			changes[i] = describeRepo(g, dir, model, cfg)
+		}()
+	}
+	wg.Wait()
+
+	printRepoTable(os.Stdout, changes)
+	var ready []int
+	failed := false
+	for i, c := range changes {
+		if c.Err != nil {
+			failed = true
+		} else if c.Message != "" {
+			ready = append(ready, i)
+		}
+	}
+	// A repository that failed keeps the exit code from being 0, but the
+	// others can still be committed.
+	defer func() {
+		if failed {
+			os.Exit(1)
+		}
+	}()
+	if len(ready) == 0 {
+		if ciMode && !failed {
+			os.Exit(exitCINoChanges)
+		}
+		return
+	}
+	picked := ready
+	if !o.Commit {
+		if ciMode || !isTerminal(os.Stdin) {
+			infof("Pass --commit to commit in all of them.")
+			return
+		}
+		picked = pickRepos(bufio.NewReader(os.Stdin), ready)
+	}
+	for _, i := range picked {
+		c := changes[i]
+		if c.Conflict && !o.Force {
+			errorf("%s: unresolved merge conflicts; refusing to commit. Resolve them or pass --force.", c.Dir)
+			failed = true
+			continue
+		}
+		msg := c.Message
+		if len(o.Trailers) > 0 {
+			msg = withTrailers(msg, o.Trailers, model)
+		}
+		if err := commitInRepo(c, commitText(msg, cfg.Style), o.Signoff); err != nil {
+			errorf("%s: %v", c.Dir, err)
+			failed = true
+			continue
+		}
+		if !dryCommit {
+			fmt.Println(success("Committed in " + c.Dir + "."))
+		}
+	}
+}
+
+// describeRepo collects one repository's pending changes and generates their
+// message, with the same protections as a plain run's.
+func describeRepo(g *generator.Generator, dir, model string, cfg Config) repoChange {
+	c := repoChange{Dir: dir}
+	var gc gitctx.CommitContext
+	gc, c.Top, c.Staged, c.Err = pendingContext(runCtx, dir)
+	if c.Err != nil || gc.Diff == "" {
+		return c
+	}
+	c.Files = len(gitctx.ParseNameStatus(gc.NameStatus))
+	unmerged, err := gitctx.GitIn(runCtx, c.Top)("diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		debugf("Listing unmerged paths in %s: %v", c.Top, err)
+	}
+	c.Conflict = hasConflicts(unmerged, gc.Diff)
+	gc, _ = withholdSensitive(gc)
+	gc, secrets := redactSecrets(gc)
+	if len(secrets) > 0 && cfg.BlockOnSecret {
+		c.Err = fmt.Errorf("the diff has %d secrets in it and block_on_secret is set", len(secrets))
+		return c
+	}
+	if cfg.MaxFileTokens > 0 {
+		gc.Diff, _ = limitFileTokens(gc.Diff, cfg.MaxFileTokens)
+	}
+	opts := generator.Options{Style: cmp.Or(cfg.Style, generator.StyleConventional), Mood: cfg.Mood, SubjectLimit: cfg.MaxSubjectLength, Model: model, Timeout: queryTimeout, Structured: cfg.StructuredOutput}
+	sg, err := g.Generate(runCtx, opts, gc)
+	if err != nil {
+		c.Err = fmt.Errorf("generation failed: %w", err)
+		return c
+	}
+	c.Message = postProcess{StripPeriod: true}.apply(sg.Message)
+	return c
+}
+
+// printRepoTable lists each repository with the subject of its message, or
+// why it has none.
+func printRepoTable(w io.Writer, changes []repoChange) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\n#\tREPOSITORY\tFILES\tMESSAGE")
+	for i, c := range changes {
+		files, subject := "-", ""
+		switch {
+		case c.Err != nil:
+			subject = warn("error: " + c.Err.Error())
+		case c.Message == "":
+			subject = paint(ansiDim, "no changes")
+		default:
+			subject, _ = generator.SplitMessage(c.Message)
+			files = strconv.Itoa(c.Files)
+			if c.Staged {
+				files += " staged"
+			}
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", i+1, c.Dir, files, subject)
+	}
+	tw.Flush()
+}
+
+// pickRepos asks which of the ready repositories to commit in: all, none,
+// or some by number.
+func pickRepos(reader *bufio.Reader, ready []int) []int {
+	for {
+		fmt.Print("\nCommit in which repositories? [a]ll, numbers such as 1,3, or Enter for none: ")
+		input, _ := reader.ReadString('\n')
+		input = strings.ToLower(strings.TrimSpace(input))
+		switch input {
+		case "":
+			return nil
+		case "a", "all":
+			return ready
+		}
+		var picked []int
+		ok := true
+		for _, f := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' }) {
+			n, err := strconv.Atoi(f)
+			if err != nil || !slices.Contains(ready, n-1) {
+				ok = false
+				break
+			}
+			picked = append(picked, n-1)
+		}
+		if ok {
+			return picked
+		}
+		fmt.Println(warn("Enter a, numbers of repositories with a message, or nothing."))
+	}
+}
+
+// commitInRepo commits c's changes with msg: the index as it is, or all of
+// the work tree when nothing was staged, as a plain run does.
+func commitInRepo(c repoChange, msg string, signoff bool) error {
+	sign := slices.Clone(commitSignArgs)
+	if signoff {
+		sign = append(sign, "--signoff")
+	}
+	if dryCommit {
+		if !c.Staged {
+			previewGit("-C", c.Top, "add", ".")
+		}
+		previewGit(slices.Concat([]string{"-C", c.Top, "commit", "-F", "-"}, sign)...)
+		fmt.Println("Message:\n" + indent(msg))
+		return nil
+	}
+	git := func(args ...string) error {
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(runCtx, gitctx.Path(), args...)
+		cmd.Dir = c.Top
+		cmd.Stdout, cmd.Stderr = os.Stdout, io.MultiWriter(os.Stderr, &stderr)
+		return gitctx.CommandError(cmd.Run(), stderr.String())
+	}
+	if !c.Staged {
+		if err := git("add", "."); err != nil {
+			return fmt.Errorf("git add failed: %w", err)
+		}
+	}
+	f, err := os.CreateTemp("", "commit-msg-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(msg); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return signingError(git(slices.Concat([]string{"commit", "-F", f.Name()}, sign)...))
+}
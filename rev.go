@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// resolveRev resolves rev to a full commit SHA and reports whether it is the
+// current HEAD, which is the only commit that can be amended in place.
+func resolveRev(rev string) (sha string, isHead bool, err error) {
+	sha, err = runGit("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", false, err
+	} else if err != nil || sha == "" {
+		return "", false, fmt.Errorf("unknown revision %q", rev)
+	}
+	head, err := runGit("rev-parse", "HEAD")
+	if err != nil {
+		return "", false, fmt.Errorf("git rev-parse HEAD failed: %w", err)
+	}
+	return sha, sha == head, nil
+}
+
+// remoteBranchesContaining lists the remote-tracking branches that already
+// have sha, i.e. where it has been pushed.
+func remoteBranchesContaining(sha string) ([]string, error) {
+	out, err := runGit("branch", "-r", "--contains", sha, "--format=%(refname:short)")
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+var errReviewNotTerminal = errors.New("--review needs an interactive terminal")
+
+// reviewMessage is the --review loop: the message is shown and the user
+// accepts it (a), edits it in their editor (e), regenerates it with optional
+// extra instructions (r), or quits (q). Enter alone asks again, so a stray
+// keypress can't commit. accepted is false on quit.
+func reviewMessage(msg string, reader *bufio.Reader, regen func(instructions string) (string, error)) (result string, accepted bool) {
+	for {
+		fmt.Print("\n[a]ccept and commit, [e]dit, [r]egenerate, [q]uit: ")
+		input, err := reader.ReadString('\n')
+		if err != nil && input == "" {
+			return msg, false
+		}
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "a", "accept":
+			return msg, true
+		case "e", "edit":
+			edited, err := editMessage(msg)
+			if err != nil {
+				fmt.Println(warn(fmt.Sprintf("Editing failed: %v", err)))
+				continue
+			}
+			if edited == "" {
+				fmt.Println(warn("The edited message is empty; keeping the previous one."))
+				continue
+			}
+			msg = edited
+		case "r", "regenerate":
+			fmt.Print("Extra instructions (optional): ")
+			instructions, _ := reader.ReadString('\n')
+			fmt.Println("Generating commit message...")
+			next, err := regen(strings.TrimSpace(instructions))
+			if err != nil {
+				fmt.Println(warn(fmt.Sprintf("Regeneration failed: %v", err)))
+				continue
+			}
+			msg = next
+		case "q", "quit":
+			return msg, false
+		default:
+			continue
+		}
+		fmt.Printf("\n%s\n", colorMessage(msg))
+	}
+}
+
+// editMessage opens msg in git's editor (GIT_EDITOR, core.editor, VISUAL,
+// then EDITOR) and returns the result with comment lines stripped.
+func editMessage(msg string) (string, error) {
+	editor, err := runGit("var", "GIT_EDITOR")
+	if err != nil {
+		return "", fmt.Errorf("no editor configured: %w", err)
+	}
+	f, err := os.CreateTemp("", "COMMIT_EDITMSG-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(msg + "\n\n# Lines starting with '#' are ignored. Save and close the editor to continue.\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	cmd := editorCommand(editor, f.Name(), shellPath(gitctx.Path()))
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w", editor, err)
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return stripComments(string(data)), nil
+}
+
+// editorCommand runs git's editor on path. git var gives a shell snippet,
+// e.g. "code --wait", so it runs through sh like git itself does; without
+// a shell the snippet is split into words, quotes grouping them.
+func editorCommand(editor, path, sh string) *exec.Cmd {
+	if sh != "" {
+		return exec.Command(sh, "-c", editor+` "$1"`, editor, path)
+	}
+	args := splitCommandLine(editor)
+	if len(args) == 0 {
+		args = []string{editor}
+	}
+	return exec.Command(args[0], append(args[1:], path)...)
+}
+
+// shellPath finds sh: on PATH, or on native Windows where it usually isn't,
+// in the Git for Windows installation git runs from (git.exe is in cmd or
+// mingw64/bin, sh.exe in bin and usr/bin). It returns "" when there is none.
+func shellPath(git string) string {
+	if sh, err := exec.LookPath("sh"); err == nil {
+		return sh
+	}
+	for _, c := range shellCandidates(git) {
+		if fi, err := os.Stat(c); err == nil && !fi.IsDir() {
+			return c
+		}
+	}
+	return ""
+}
+
+func shellCandidates(git string) []string {
+	if !filepath.IsAbs(git) {
+		return nil
+	}
+	var candidates []string
+	dir := filepath.Dir(git)
+	for _, root := range []string{filepath.Dir(dir), filepath.Dir(filepath.Dir(dir))} {
+		candidates = append(candidates, filepath.Join(root, "bin", "sh.exe"), filepath.Join(root, "usr", "bin", "sh.exe"))
+	}
+	return candidates
+}
+
+// splitCommandLine splits s into words at spaces and tabs outside single or
+// double quotes. Backslashes are kept as they are, since they separate
+// Windows paths.
+func splitCommandLine(s string) []string {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"vim", []string{"vim"}},
+		{"code --wait", []string{"code", "--wait"}},
+		{"  nano\t-w  ", []string{"nano", "-w"}},
+		{`"C:\Program Files\Notepad++\notepad++.exe" -multiInst -nosession`, []string{`C:\Program Files\Notepad++\notepad++.exe`, "-multiInst", "-nosession"}},
+		{`C:\Windows\notepad.exe`, []string{`C:\Windows\notepad.exe`}},
+		{`emacs -nw --eval '(setq x "y")'`, []string{"emacs", "-nw", "--eval", `(setq x "y")`}},
+		{`"" x`, []string{"", "x"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := splitCommandLine(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("splitCommandLine(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEditorCommand(t *testing.T) {
+	cmd := editorCommand("code --wait", "msg.txt", "/bin/sh")
+	if want := []string{"/bin/sh", "-c", `code --wait "$1"`, "code --wait", "msg.txt"}; !slices.Equal(cmd.Args, want) {
+		t.Errorf("with a shell: %q, want %q", cmd.Args, want)
+	}
+	cmd = editorCommand(`"C:\Program Files\Vim\gvim.exe" -f`, "msg.txt", "")
+	if want := []string{`C:\Program Files\Vim\gvim.exe`, "-f", "msg.txt"}; !slices.Equal(cmd.Args, want) {
+		t.Errorf("without a shell: %q, want %q", cmd.Args, want)
+	}
+}
+
+func TestShellCandidates(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator)+"opt", "Git")
+	for _, git := range []string{filepath.Join(root, "cmd", "git.exe"), filepath.Join(root, "mingw64", "bin", "git.exe")} {
+		got := shellCandidates(git)
+		if !slices.Contains(got, filepath.Join(root, "bin", "sh.exe")) || !slices.Contains(got, filepath.Join(root, "usr", "bin", "sh.exe")) {
+			t.Errorf("shellCandidates(%q) = %q, want Git's bin and usr/bin", git, got)
+		}
+	}
+	if got := shellCandidates("git"); got != nil {
+		t.Errorf("shellCandidates(\"git\") = %q, want none for a bare name", got)
+	}
+}
+
+func TestReviewMessage(t *testing.T) {
+	regen := func(instructions string) (string, error) { return "fix: regenerated " + instructions, nil }
+	tests := []struct {
+		input    string
+		want     string
+		accepted bool
+	}{
+		{"a\n", "fix: first", true},
+		{"\n\nq\n", "fix: first", false},
+		{"\n", "fix: first", false}, // input ends before an answer
+		{"r\nshorter\na\n", "fix: regenerated shorter", true},
+		{"x\nq\n", "fix: first", false},
+	}
+	for _, tt := range tests {
+		got, accepted := reviewMessage("fix: first", bufio.NewReader(strings.NewReader(tt.input)), regen)
+		if got != tt.want || accepted != tt.accepted {
+			t.Errorf("input %q: got %q, %v; want %q, %v", tt.input, got, accepted, tt.want, tt.accepted)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// rewriteDir holds the messages and the todo list of `commit rewrite`,
+// under the git directory.
+const rewriteDir = "commit-rewrite"
+
+// runRewrite implements `commit rewrite [--base BRANCH] [--apply]`: it
+// writes a new message for each commit the current branch adds since it
+// forked from base, from that commit's own diff, and turns them into a git
+// rebase -i todo list that amends each commit in turn.
+func runRewrite(args []string, model string, cfg Config) {
+	fs := flag.NewFlagSet("rewrite", flag.ExitOnError)
+	base := fs.String("base", "", "Branch the current one forked from (default: origin's default branch, else main or master)")
+	apply := fs.Bool("apply", false, "Run the rebase with the new messages instead of printing its todo list")
+	force := fs.Bool("force", false, "Rewrite commits that are already on a remote branch")
+	fs.Parse(args)
+
+	if *base == "" {
+		var err error
+		if *base, err = defaultBaseBranch(); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	mergeBase, err := runGit("merge-base", *base, "HEAD")
+	if err != nil {
+		fatalf("No common ancestor of %s and HEAD: %v", *base, err)
+	}
+	span := mergeBase + "..HEAD"
+	if merges, err := runGit("rev-list", "--merges", span); err != nil {
+		gitFatalf("git rev-list failed: %v", err)
+	} else if merges != "" {
+		fatalf("The branch has merge commits since %s; rewrite only works on a linear history.", *base)
+	}
+	revs, err := runGit("rev-list", "--reverse", span)
+	if err != nil {
+		gitFatalf("git rev-list failed: %v", err)
+	}
+	if revs == "" {
+		fatalf("HEAD has no commits that aren't on %s.", *base)
+	}
+	shas := strings.Split(revs, "\n")
+	if remotes, err := remoteBranchesContaining(shas[0]); err == nil && len(remotes) > 0 && !*force {
+		errorf("%s is already on %s; rewriting the branch rewrites published history. Pass --force to rewrite it anyway.", shas[0][:12], strings.Join(remotes, ", "))
+		os.Exit(1)
+	}
+
+	// The diffs go to the model with the same protections as a commit's.
+	sensitivePatterns = slices.Concat(defaultSensitivePaths, cfg.SensitivePaths)
+	if redactPatterns, err = compileRedactPatterns(cfg.RedactPatterns); err != nil {
+		fatalf("%v", err)
+	}
+	if err := checkModel(runCtx, model); err != nil {
+		errorf("%v", err)
+		os.Exit(noModelExit())
+	}
+	// Messages of an earlier run are replaced, not kept next to these.
+	dir := filepath.Join(repo.GitDir, rewriteDir)
+	os.RemoveAll(dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		fatalf("%v", err)
+	}
+
+	g := newGenerator(runCtx, model)
+	post := postProcess{StripPeriod: true}
+	var todo strings.Builder
+	for i, sha := range shas {
+		subject, err := runGit("log", "-1", "--format=%s", sha)
+		if err != nil {
+			gitFatalf("git log failed: %v", err)
+		}
+		infof("Rewording %d of %d: %s %s", i+1, len(shas), sha[:12], subject)
+		gc := collectGitData([]string{"show", "--format=", sha}, 4)
+		gc, _ = withholdSensitive(gc)
+		gc, secrets := redactSecrets(gc)
+		if len(secrets) > 0 && cfg.BlockOnSecret {
+			refuseSecrets(secrets, "block_on_secret")
+		}
+		if cfg.MaxFileTokens > 0 {
+			gc.Diff, _ = limitFileTokens(gc.Diff, cfg.MaxFileTokens)
+		}
+		opts := generator.Options{Style: cmp.Or(cfg.Style, generator.StyleConventional), Mood: cfg.Mood, SubjectLimit: cfg.MaxSubjectLength, Model: model, Timeout: queryTimeout, OldSubject: subject}
+		sg, err := g.Generate(runCtx, opts, gc)
+		if err != nil {
+			errorf("Generation failed for %s: %v", sha[:12], err)
+			os.Exit(exitGenerationFailed)
+		}
+		msg := post.apply(sg.Message)
+		// Trailers such as Signed-off-by belong to the commit, not to its
+		// old wording.
+		if trailers, err := runGit("log", "-1", "--format=%(trailers:unfold)", sha); err == nil && trailers != "" {
+			msg += "\n\n" + trailers
+		}
+		path := filepath.Join(dir, sha+".txt")
+		if err := os.WriteFile(path, []byte(msg+"\n"), 0600); err != nil {
+			fatalf("%v", err)
+		}
+		newSubject, _ := generator.SplitMessage(msg)
+		fmt.Fprintf(os.Stderr, "  %s\n  %s\n", paint(ansiDim, "- "+subject), success("+ "+newSubject))
+		fmt.Fprintf(&todo, "pick %s %s\nexec git commit --amend --only --allow-empty --cleanup=whitespace -F %s\n", sha[:12], subject, shellQuote(path))
+	}
+
+	todoPath := filepath.Join(dir, "git-rebase-todo")
+	if err := os.WriteFile(todoPath, []byte(todo.String()), 0600); err != nil {
+		fatalf("%v", err)
+	}
+	if !*apply {
+		fmt.Print(todo.String())
+		infof("The messages are in %s; edit them if you like, then reword the commits with: GIT_SEQUENCE_EDITOR=%s git rebase -i %s", dir, shellQuote("cp "+shellQuote(todoPath)), mergeBase[:12])
+		return
+	}
+	// git rebase runs the sequence editor on its own todo list, which cp
+	// replaces with this one.
+	cmd := gitCmd("rebase", "-i", mergeBase)
+	cmd.Env = append(os.Environ(), "GIT_SEQUENCE_EDITOR=cp "+shellQuote(todoPath))
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		gitFatalf("git rebase failed: %v; git rebase --abort puts the branch back", err)
+	}
+	infof("Reworded %d commits.", len(shas))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// ScopeSource is where a changed file's scope comes from when no pattern in
+// the scope map covers it.
+type ScopeSource string
+
+const (
+	ScopeFromMap       ScopeSource = "map"       // nowhere: only the scope map
+	ScopeFromDirectory ScopeSource = "directory" // the file's top-level directory
+	ScopeFromPackage   ScopeSource = "package"   // the workspace package the file belongs to
+)
+
+func parseScopeSource(s string) (ScopeSource, error) {
+	switch src := ScopeSource(strings.ToLower(s)); src {
+	case ScopeFromMap, ScopeFromDirectory, ScopeFromPackage:
+		return src, nil
+	}
+	return "", fmt.Errorf("invalid scope source %q (want map, directory, or package)", s)
+}
+
+// packageManifests mark the root of a workspace package.
+var packageManifests = []string{"go.mod", "package.json", "Cargo.toml", "pyproject.toml"}
+
+// packageScope returns the name of the package containing p (relative to
+// root): the nearest directory above it with a manifest, named after the
+// directory, or for package.json after its name without the npm scope. A
+// manifest in the repository root is the whole repository, not a scope.
+func packageScope(root, p string, cache map[string]string) string {
+	var seen []string
+	scope := ""
+	for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if s, ok := cache[dir]; ok {
+			scope = s
+			break
+		}
+		seen = append(seen, dir)
+		if name, ok := packageName(filepath.Join(root, filepath.FromSlash(dir))); ok {
+			scope = name
+			break
+		}
+	}
+	for _, dir := range seen {
+		cache[dir] = scope
+	}
+	return scope
+}
+
+func packageName(dir string) (string, bool) {
+	for _, m := range packageManifests {
+		data, err := os.ReadFile(filepath.Join(dir, m))
+		if err != nil {
+			continue
+		}
+		if m == "package.json" {
+			var pkg struct {
+				Name string `json:"name"`
+			}
+			if json.Unmarshal(data, &pkg) == nil && pkg.Name != "" {
+				return path.Base(pkg.Name), true
+			}
+		}
+		return filepath.Base(dir), true
+	}
+	return "", false
+}
+
+// scopesFor returns the scopes of the changed files according to scopeMap
+// (pattern -> scope), sorted. When several patterns match a file the longest
+// one wins, so "services/auth/api/**" can override "services/auth/**". Files
+// no pattern covers get their scope from from; files in the repository root
+// have none.
+func scopesFor(scopeMap map[string]string, from ScopeSource, nameStatus string) []string {
+	if len(scopeMap) == 0 && (from == ScopeFromMap || from == "") {
+		return nil
+	}
+	root := ""
+	if from == ScopeFromPackage {
+		var err error
+		if root, err = runGit("rev-parse", "--show-toplevel"); err != nil {
+			debugf("No scopes from packages: %v", err)
+			from = ScopeFromMap
+		}
+	}
+	cache := map[string]string{}
+	var scopes []string
+	for _, c := range gitctx.ParseNameStatus(nameStatus) {
+		best := ""
+		for pattern := range scopeMap {
+			if len(pattern) > len(best) && gitctx.MatchPath(pattern, c.Path) {
+				best = pattern
+			}
+		}
+		scope := scopeMap[best]
+		switch {
+		case best != "":
+		case from == ScopeFromDirectory:
+			if dir, _, ok := strings.Cut(c.Path, "/"); ok {
+				scope = dir
+			}
+		case from == ScopeFromPackage:
+			scope = packageScope(root, c.Path, cache)
+		}
+		if scope != "" && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	slices.Sort(scopes)
+	return scopes
+}
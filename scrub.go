@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	// absPathRe matches absolute paths under the usual system and home roots,
+	// and Windows drive paths. Bare "/x/y" is left alone, since URL paths and
+	// routes look the same.
+	absPathRe = regexp.MustCompile("(?:^|[\\s(\"'`=])((?:/(?:home|Users|root|tmp|var|etc|opt|usr|srv|mnt|private|Volumes)/|[A-Za-z]:\\\\)(?:[^\\s\"'`)]*[^\\s\"'`).,;:])?)")
+	// hexTokenRe matches runs of hex digits long enough to be a key or hash.
+	hexTokenRe = regexp.MustCompile(`\b[0-9a-fA-F]{32,}\b`)
+	// base64TokenRe matches long base64 or base64url runs. "/" is left out so
+	// that file paths aren't taken for one.
+	base64TokenRe = regexp.MustCompile(`[A-Za-z0-9+_-]{32,}={0,2}`)
+)
+
+// minTokenEntropy is the bits per character above which a base64-looking run
+// counts as a secret. Long identifiers stay well below it.
+const minTokenEntropy = 4.2
+
+// scrubber removes from a generated message what should never end up in a
+// commit: absolute paths, secret-looking tokens, and matches of the config's
+// redact_patterns. The model sees the diff only, but it may still make these
+// up or echo them from a hunk.
+type scrubber struct {
+	Root     string // repository root; paths inside it are made relative
+	Patterns []*regexp.Regexp
+}
+
+// compileRedactPatterns compiles the config's redact_patterns.
+func compileRedactPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact_patterns entry %q: %w", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// scrub returns msg with everything unwanted replaced, and what kinds of
+// things it replaced.
+func (s scrubber) scrub(msg string) (string, []string) {
+	var found []string
+	note := func(kind string) {
+		if !slices.Contains(found, kind) {
+			found = append(found, kind)
+		}
+	}
+	for _, re := range s.Patterns {
+		if re.MatchString(msg) {
+			msg = re.ReplaceAllString(msg, "[redacted]")
+			note("redact_patterns matches")
+		}
+	}
+	msg = replaceSubmatch(absPathRe, msg, func(p string) string {
+		note("absolute paths")
+		if s.Root != "" {
+			if rel, err := filepath.Rel(s.Root, p); err == nil && !strings.HasPrefix(rel, "..") {
+				return filepath.ToSlash(rel)
+			}
+		}
+		return "[path]"
+	})
+	msg = hexTokenRe.ReplaceAllStringFunc(msg, func(tok string) string {
+		// Full commit and object IDs are fine to mention.
+		if (len(tok) == 40 || len(tok) == 64) && isObject(tok) {
+			return tok
+		}
+		note("hex tokens")
+		return "[redacted]"
+	})
+	msg = base64TokenRe.ReplaceAllStringFunc(msg, func(tok string) string {
+		if !looksRandom(tok) {
+			return tok
+		}
+		note("base64 tokens")
+		return "[redacted]"
+	})
+	return msg, found
+}
+
+// replaceSubmatch replaces the first group of every match of re.
+func replaceSubmatch(re *regexp.Regexp, s string, repl func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(s[last:m[2]])
+		b.WriteString(repl(s[m[2]:m[3]]))
+		last = m[3]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// isObject reports whether sha names an object in the repository.
+func isObject(sha string) bool {
+	_, err := runGit("cat-file", "-e", sha+"^{object}")
+	return err == nil
+}
+
+// looksRandom reports whether tok mixes letters and digits with the entropy
+// of an encoded key rather than a word or identifier.
+func looksRandom(tok string) bool {
+	if !strings.ContainsAny(tok, "0123456789") || strings.ToLower(tok) == tok || strings.ToUpper(tok) == tok {
+		return false
+	}
+	counts := map[rune]int{}
+	for _, r := range tok {
+		counts[r]++
+	}
+	var bits float64
+	for _, n := range counts {
+		p := float64(n) / float64(len(tok))
+		bits -= p * math.Log2(p)
+	}
+	return bits >= minTokenEntropy
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// secretPattern is a kind of secret redactSecrets finds in diffs. With a
+// group, only the group is the secret, e.g. the value of an assignment.
+type secretPattern struct {
+	Kind string
+	Re   *regexp.Regexp
+}
+
+var secretPatterns = []secretPattern{
+	{"AWS access key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"AWS secret key", regexp.MustCompile(`(?i)aws.{0,20}secret.{0,20}?[=:]\s*["']?([A-Za-z0-9/+]{40})\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abeprs]-[A-Za-z0-9-]{10,}`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"Stripe key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{24,}`)},
+	{"JWT", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{"password", regexp.MustCompile(`(?i)(?:password|passwd|pwd|secret|api_?key|access_?token|auth_?token)["']?\s*[:=]\s*["']([^"'\s]{8,})["']`)},
+}
+
+// privateKeyRe matches the first line of a PEM private key; the lines after
+// it are redacted up to the END line.
+var privateKeyRe = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----`)
+
+// redactPatterns are the config's redact_patterns, set once in main; they are
+// redacted from diffs as well as from generated messages.
+var redactPatterns []*regexp.Regexp
+
+// blockSecrets is --block-on-secret or block_on_secret, set once in main for
+// the diffs --split gathers on its own.
+var blockSecrets bool
+
+// secretFinding is a diff line redactSecrets changed.
+type secretFinding struct {
+	Path    string
+	Line    int  // in the new file, or in the old one for a removed line
+	Removed bool // the secret is on a removed line
+	Kind    string
+	Text    string // the line as redacted
+}
+
+func (f secretFinding) String() string {
+	loc := f.Path + ":" + strconv.Itoa(f.Line)
+	if f.Removed {
+		loc += " (removed)"
+	}
+	return fmt.Sprintf("%s: %s: %s", loc, f.Kind, strings.TrimSpace(f.Text))
+}
+
+// refuseSecrets ends the run instead of sending a diff with secrets, listing
+// where they are. option names the setting that asked for it.
+func refuseSecrets(findings []secretFinding, option string) {
+	errorf("The diff contains secrets. Refusing to send it anywhere (%s):", option)
+	for _, f := range findings {
+		fmt.Fprintln(os.Stderr, "  "+f.String())
+	}
+	os.Exit(1)
+}
+
+// redactSecrets replaces the secrets in gc's diffs with "[redacted]" before
+// they are sent anywhere: the built-in secretPatterns, PEM private key
+// blocks, and redactPatterns. Lines keep their place, so hunk headers stay
+// right. It returns what it found in gc.Diff.
+func redactSecrets(gc gitctx.CommitContext) (gitctx.CommitContext, []secretFinding) {
+	var findings []secretFinding
+	gc.Diff, findings = redactDiff(gc.Diff)
+	gc.DiffNoWS, _ = redactDiff(gc.DiffNoWS)
+	return gc, findings
+}
+
+func redactDiff(diff string) (string, []secretFinding) {
+	var findings []secretFinding
+	var path string
+	var oldLine, newLine int
+	inHunk, inKey := false, false
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			inHunk, inKey = false, false
+			continue
+		case strings.HasPrefix(line, "@@"):
+			oldLine, newLine = hunkStart(line)
+			inHunk, inKey = true, false
+			continue
+		case !inHunk:
+			if name, ok := strings.CutPrefix(line, "+++ "); ok {
+				path = strings.TrimPrefix(name, "b/")
+			}
+			continue
+		case line == "" || strings.HasPrefix(line, "\\"):
+			continue // "\ No newline at end of file"
+		}
+
+		at, removed := newLine, strings.HasPrefix(line, "-")
+		switch line[0] {
+		case '-':
+			at = oldLine
+			oldLine++
+		case '+':
+			newLine++
+		default:
+			oldLine++
+			newLine++
+		}
+		prefix, text := line[:1], line[1:]
+
+		var kind string
+		switch {
+		case inKey:
+			inKey = !strings.Contains(text, "-----END ")
+			if inKey {
+				lines[i] = prefix + "[redacted]"
+			}
+			continue
+		case privateKeyRe.MatchString(text):
+			inKey, kind = true, "private key"
+		default:
+			if text, kind = redactLine(text); kind != "" {
+				lines[i] = prefix + text
+			}
+		}
+		if kind != "" {
+			findings = append(findings, secretFinding{Path: path, Line: at, Removed: removed, Kind: kind, Text: lines[i]})
+		}
+	}
+	return strings.Join(lines, "\n"), findings
+}
+
+// redactLine redacts the secrets in one line of a file and names the first
+// kind it found.
+func redactLine(text string) (string, string) {
+	kind := ""
+	for _, p := range secretPatterns {
+		if !p.Re.MatchString(text) {
+			continue
+		}
+		if p.Re.NumSubexp() > 0 {
+			text = replaceSubmatch(p.Re, text, func(string) string { return "[redacted]" })
+		} else {
+			text = p.Re.ReplaceAllString(text, "[redacted]")
+		}
+		if kind == "" {
+			kind = p.Kind
+		}
+	}
+	for _, re := range redactPatterns {
+		if re.MatchString(text) {
+			text = re.ReplaceAllString(text, "[redacted]")
+			if kind == "" {
+				kind = "redact_patterns match"
+			}
+		}
+	}
+	return text, kind
+}
+
+// hunkStartRe reads the first old and new line numbers of a hunk header.
+var hunkStartRe = regexp.MustCompile(`^@@+ -(\d+)(?:,\d+)? \+(\d+)`)
+
+func hunkStart(header string) (oldLine, newLine int) {
+	m := hunkStartRe.FindStringSubmatch(header)
+	if m == nil {
+		return 0, 0
+	}
+	oldLine, _ = strconv.Atoi(m[1])
+	newLine, _ = strconv.Atoi(m[2])
+	return oldLine, newLine
+}
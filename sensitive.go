@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// defaultSensitivePaths are files whose contents are never sent to a model:
+// environment files, private keys, and credential stores. Patterns without
+// a "/" match the base name anywhere in the tree.
+var defaultSensitivePaths = []string{
+	".env", ".env.*", "*.env",
+	"id_rsa", "id_rsa.*", "id_dsa", "id_ecdsa", "id_ed25519", "id_ed25519.*",
+	"*.pem", "*.key", "*.p12", "*.pfx", "*.jks", "*.keystore",
+	"credentials", "credentials.json", "service-account*.json", "secrets.*",
+	".netrc", ".pgpass", ".npmrc", ".pypirc", ".htpasswd",
+}
+
+// sensitivePatterns is defaultSensitivePaths plus the config's
+// sensitive_paths, set once in main.
+var sensitivePatterns = defaultSensitivePaths
+
+// isSensitive reports whether p matches one of the patterns: by base name for
+// patterns without a "/", else like a scope pattern.
+func isSensitive(p string, patterns []string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		if !strings.Contains(pattern, "/") {
+			ok, _ := path.Match(pattern, path.Base(p))
+			return ok
+		}
+		return gitctx.MatchPath(pattern, p)
+	})
+}
+
+// withholdSensitive replaces the diffs of sensitive files with a note, so the
+// model still learns that they changed but never sees their contents. It
+// returns the paths withheld.
+func withholdSensitive(gc gitctx.CommitContext) (gitctx.CommitContext, []string) {
+	var withheld []string
+	strip := func(diff string) string {
+		var b strings.Builder
+		for _, f := range gitctx.SplitFiles(diff) {
+			if f.Path == "" || !isSensitive(f.Path, sensitivePatterns) {
+				b.WriteString(f.Text)
+				continue
+			}
+			header, _, _ := strings.Cut(f.Text, "\n")
+			b.WriteString(header + "\n(contents withheld: sensitive file)\n")
+			if !slices.Contains(withheld, f.Path) {
+				withheld = append(withheld, f.Path)
+			}
+		}
+		return b.String()
+	}
+	gc.Diff, gc.DiffNoWS = strip(gc.Diff), strip(gc.DiffNoWS)
+	return gc, withheld
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// serveAddr is where `commit serve` listens unless --addr is given: the
+// loopback interface only, since anything that reaches it can spend the
+// API key.
+const serveAddr = "127.0.0.1:7744"
+
+// serveRequest is the body of POST /generate. Repo is a directory in a work
+// tree whose pending changes are described, staged ones if there are any;
+// Diff is a unified diff or format-patch text described instead. Style and
+// Model override the config for this request.
+type serveRequest struct {
+	Repo  string `json:"repo"`
+	Diff  string `json:"diff"`
+	Style string `json:"style"`
+	Model string `json:"model"`
+}
+
+// server answers the requests of `commit serve` and keeps one generator per
+// model, so genkit and the provider client are set up once, not per request.
+type server struct {
+	cfg   Config
+	model string // default model, resolved at startup
+
+	mu         sync.Mutex
+	generators map[string]*generator.Generator
+}
+
+// runServe implements `commit serve [--addr HOST:PORT]`: a JSON API on
+// localhost for editor plugins, with POST /generate and GET /health.
+func runServe(args []string, model string, cfg Config) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", serveAddr, "Address to listen on; keep it on localhost")
+	fs.Parse(args)
+
+	sensitivePatterns = slices.Concat(defaultSensitivePaths, cfg.SensitivePaths)
+	var err error
+	if redactPatterns, err = compileRedactPatterns(cfg.RedactPatterns); err != nil {
+		fatalf("%v", err)
+	}
+	s := &server{cfg: cfg, model: model, generators: map[string]*generator.Generator{}}
+	// Warm up the default model before the first request.
+	if _, err := s.generator(runCtx, model); err != nil {
+		fatalf("%v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /generate", s.handleGenerate)
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		writeServeJSON(w, http.StatusOK, map[string]any{"ok": true, "model": s.model})
+	})
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if host, _, _ := net.SplitHostPort(*addr); host != "127.0.0.1" && host != "::1" && host != "localhost" {
+		warnf("Listening on %s, beyond this machine; anyone who reaches it can use your API key.", *addr)
+	}
+	infof("Serving commit messages from %s on http://%s (POST /generate); Ctrl-C stops.", model, ln.Addr())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-runCtx.Done()
+		srv.Close()
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatalf("%v", err)
+	}
+}
+
+// generator returns the kept generator for model, setting one up the first
+// time once checkModel finds it usable.
+func (s *server) generator(ctx context.Context, model string) (*generator.Generator, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.generators[model]
+	if !ok {
+		if err := checkModel(ctx, model); err != nil {
+			return nil, err
+		}
+		g = newGenerator(runCtx, model)
+		s.generators[model] = g
+	}
+	return g, nil
+}
+
+func (s *server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	// A web page can post to localhost too; browsers send an Origin header,
+	// and a JSON content type can't be sent across origins without a
+	// preflight, which is never answered.
+	if r.Header.Get("Origin") != "" {
+		writeServeError(w, http.StatusForbidden, errors.New("requests from web pages aren't accepted"))
+		return
+	}
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+		writeServeError(w, http.StatusUnsupportedMediaType, errors.New("send the request as application/json"))
+		return
+	}
+	var req serveRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<20)).Decode(&req); err != nil {
+		writeServeError(w, http.StatusBadRequest, fmt.Errorf("reading the request: %w", err))
+		return
+	}
+	ctx := r.Context()
+	if queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 3*queryTimeout)
+		defer cancel()
+	}
+	gc, err := requestContext(ctx, req)
+	if err != nil {
+		writeServeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if gc.Diff == "" {
+		writeServeError(w, http.StatusUnprocessableEntity, errors.New("there are no changes to describe"))
+		return
+	}
+	gc, _ = withholdSensitive(gc)
+	gc, secrets := redactSecrets(gc)
+	if len(secrets) > 0 && s.cfg.BlockOnSecret {
+		writeServeError(w, http.StatusUnprocessableEntity, fmt.Errorf("the diff has %d secrets in it and block_on_secret is set", len(secrets)))
+		return
+	}
+	if s.cfg.MaxFileTokens > 0 {
+		gc.Diff, _ = limitFileTokens(gc.Diff, s.cfg.MaxFileTokens)
+	}
+
+	model := s.model
+	if req.Model != "" {
+		if model, _, err = generator.ResolveModel(generator.ProviderAuto, req.Model, true); err != nil {
+			writeServeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	style := cmp.Or(s.cfg.Style, generator.StyleConventional)
+	if req.Style != "" {
+		if style, err = generator.ParseStyle(req.Style); err != nil {
+			writeServeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	g, err := s.generator(ctx, model)
+	if err != nil {
+		writeServeError(w, http.StatusBadRequest, err)
+		return
+	}
+	opts := generator.Options{Style: style, Mood: s.cfg.Mood, SubjectLimit: s.cfg.MaxSubjectLength, Model: model, Timeout: queryTimeout, Structured: s.cfg.StructuredOutput}
+	sg, err := g.Generate(ctx, opts, gc)
+	if err != nil {
+		writeServeError(w, http.StatusBadGateway, fmt.Errorf("generation failed: %w", err))
+		return
+	}
+	out := structuredMessage(postProcess{StripPeriod: true}.apply(sg.Message))
+	out.Usage = usageOf(model, opts, gc, sg)
+	writeServeJSON(w, http.StatusOK, out)
+}
+
+// requestContext collects the change a request describes: its diff, or the
+// pending changes of its repository.
+func requestContext(ctx context.Context, req serveRequest) (gitctx.CommitContext, error) {
+	switch {
+	case req.Diff != "" && req.Repo != "":
+		return gitctx.CommitContext{}, errors.New("send either repo or diff, not both")
+	case req.Diff != "":
+		return parsePatch(req.Diff)
+	case req.Repo == "":
+		return gitctx.CommitContext{}, errors.New("send repo, a directory in a git work tree, or diff")
+	}
+	gc, _, _, err := pendingContext(ctx, req.Repo)
+	return gc, err
+}
+
+func writeServeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeServeError answers with {"error": "..."}, and logs it too.
+func writeServeError(w http.ResponseWriter, status int, err error) {
+	warnf("%v", err)
+	writeServeJSON(w, status, map[string]string{"error": err.Error()})
+}
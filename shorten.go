@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// maxShortenAttempts bounds how often the model is asked to shorten a subject.
+const maxShortenAttempts = 3
+
+// shortenSubject asks the model to rewrite subject to fit within limit
+// characters while keeping its meaning and type prefix.
+func shortenSubject(ctx context.Context, g *generator.Generator, opts generator.Options, subject string, limit int) (string, error) {
+	system := fmt.Sprintf("You shorten git commit subject lines.\nRewrite the subject to at most %d characters, preserving its meaning, its type(scope): prefix if present, and any bracketed tags.\nReturn ONLY the new subject line.", limit)
+	answer, err := g.Ask(ctx, opts, system, subject)
+	if err != nil {
+		return "", err
+	}
+	short, _ := generator.SplitMessage(answer)
+	return strings.TrimSpace(short), nil
+}
+
+// offerShorten asks whether an over-long subject should be shortened by the
+// model, retrying until it fits or maxShortenAttempts is reached. The body is
+// never changed.
+func offerShorten(ctx context.Context, g *generator.Generator, opts generator.Options, post postProcess, msg string, reader *bufio.Reader) string {
+	subject, rest := generator.SplitMessage(msg)
+	n := displayWidth(subject)
+	if n <= maxSubjectLen {
+		return msg
+	}
+	fmt.Printf("\nSubject is %d characters (limit %d). Shorten it? [Y/n]: ", n, maxSubjectLen)
+	input, _ := reader.ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(input)); a == "n" || a == "no" {
+		return msg
+	}
+
+	for attempt := 1; attempt <= maxShortenAttempts; attempt++ {
+		short, err := shortenSubject(ctx, g, opts, subject, maxSubjectLen)
+		if err != nil {
+			fmt.Println(warn(fmt.Sprintf("Shortening failed: %v", err)))
+			break
+		}
+		if short == "" {
+			continue
+		}
+		short = stripPeriodIf(applySubjectCase(short, post.Case), post.StripPeriod)
+		subject = short
+		fmt.Printf("  %s\n", colorMessage(subject))
+		if displayWidth(subject) <= maxSubjectLen {
+			break
+		}
+	}
+	return generator.JoinMessage(subject, rest)
+}
+
+func stripPeriodIf(subject string, strip bool) string {
+	if strip {
+		return stripPeriod(subject)
+	}
+	return subject
+}
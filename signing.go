@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// commitSignArgs are added to every git commit the tool makes: -S for
+// --sign, --no-gpg-sign for --no-sign. Empty leaves commit.gpgsign in charge.
+var commitSignArgs []string
+
+// signArgs maps the --sign and --no-sign flags to git commit options.
+func signArgs(sign, noSign bool) ([]string, error) {
+	switch {
+	case sign && noSign:
+		return nil, fmt.Errorf("--sign and --no-sign are mutually exclusive")
+	case sign:
+		return []string{"-S"}, nil
+	case noSign:
+		return []string{"--no-gpg-sign"}, nil
+	}
+	return nil, nil
+}
+
+// signingError explains a commit that failed because it could not be signed;
+// other errors are returned unchanged.
+func signingError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if strings.Contains(msg, "failed to sign") || strings.Contains(msg, "gpg failed") || strings.Contains(msg, "cannot run gpg") || strings.Contains(msg, "ssh-keygen") {
+		return fmt.Errorf("signing the commit failed: %w\n(check user.signingkey, gpg.format, and gpg.program, or pass --no-sign)", err)
+	}
+	return err
+}
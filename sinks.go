@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// outputSink is one destination for a message that isn't committed. Several
+// can be active at once.
+type outputSink interface {
+	Write(msg string) error
+}
+
+// stdoutSink prints the bare message, for scripts and pipes.
+type stdoutSink struct {
+	W io.Writer // os.Stdout when nil; --quiet keeps the real stdout here
+}
+
+func (s stdoutSink) Write(msg string) error {
+	w := s.W
+	if w == nil {
+		w = os.Stdout
+	}
+	_, err := fmt.Fprintln(w, msg)
+	return err
+}
+
+// clipboardSink copies the message in the configured clip format.
+type clipboardSink struct {
+	Format ClipFormat
+	OSC52  bool
+}
+
+// Write prints the message instead when copying fails, so a generated
+// message is never lost to a missing clipboard.
+func (s clipboardSink) Write(msg string) error {
+	if err := copyToClipboard(formatForClipboard(msg, s.Format), s.OSC52); err != nil {
+		warnf("Failed to copy to clipboard (%v); printing the message instead.", err)
+		return stdoutSink{}.Write(msg)
+	}
+	fmt.Println("\n" + success("Commit message copied to clipboard!"))
+	return nil
+}
+
+// fileSink writes the message to Path, or to .git/COMMIT_EDITMSG when
+// empty, where git commit -eF picks it up. Comment lines already in the file,
+// such as the instructions git puts in a prepare-commit-msg hook's file, are
+// kept below the message.
+type fileSink struct {
+	Path string
+}
+
+func (s fileSink) Write(msg string) error {
+	path := s.Path
+	if path == "" {
+		var err error
+		if path, err = gitPath("COMMIT_EDITMSG"); err != nil {
+			return fmt.Errorf("Failed to locate COMMIT_EDITMSG: %w", err)
+		}
+	}
+	var comments []string
+	if data, err := os.ReadFile(path); err == nil {
+		for _, line := range strings.Split(gitctx.NormalizeNewlines(string(data)), "\n") {
+			if strings.HasPrefix(line, "#") {
+				comments = append(comments, line)
+			}
+		}
+	}
+	text := msg + "\n"
+	if len(comments) > 0 {
+		text += "\n" + strings.Join(comments, "\n") + "\n"
+	}
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		return fmt.Errorf("Failed to write %s: %w", path, err)
+	}
+	fmt.Println(success(fmt.Sprintf("Commit message written to %s (git commit -eF %s).", path, path)))
+	return nil
+}
+
+// outputSinks lists the sinks chosen with --stdout, --clipboard,
+// --write-editmsg, and --output-file, in that order; none chosen means the
+// clipboard alone. explicit reports whether any was chosen, which replaces
+// the configured action.
+func outputSinks(toStdout, toClipboard, toEditMsg bool, outputFile string, clip clipboardSink, stdout io.Writer) (sinks []outputSink, explicit bool) {
+	if toStdout {
+		sinks = append(sinks, stdoutSink{W: stdout})
+	}
+	if toClipboard {
+		sinks = append(sinks, clip)
+	}
+	if toEditMsg {
+		sinks = append(sinks, fileSink{})
+	}
+	if outputFile != "" {
+		sinks = append(sinks, fileSink{Path: outputFile})
+	}
+	if len(sinks) == 0 {
+		return []outputSink{clip}, false
+	}
+	return sinks, true
+}
+
+// withoutClipboard drops the clipboard from sinks for --no-clipboard or a
+// system without one, printing the message when nothing else is left.
+func withoutClipboard(sinks []outputSink, stdout io.Writer) []outputSink {
+	sinks = slices.DeleteFunc(slices.Clone(sinks), func(s outputSink) bool {
+		_, ok := s.(clipboardSink)
+		return ok
+	})
+	if len(sinks) == 0 {
+		return []outputSink{stdoutSink{W: stdout}}
+	}
+	return sinks
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// SplitMode is how --split groups the changes into commits.
+type SplitMode string
+
+const (
+	SplitByDirectory SplitMode = "directory" // whole files, by top-level directory
+	SplitByModel     SplitMode = "model"     // single hunks, grouped by the model
+)
+
+func parseSplitMode(s string) (SplitMode, error) {
+	switch m := SplitMode(strings.ToLower(s)); m {
+	case SplitByDirectory, SplitByModel:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid split mode %q (want directory or model)", s)
+}
+
+// cluster is a group of changed files that are committed together.
+type cluster struct {
+	Name    string
+	Changes []gitctx.FileChange
+	// Patch, when set, holds the hunks of Changes to commit; the rest of
+	// those files stays uncommitted.
+	Patch string
+}
+
+// paths returns every path the cluster touches, including rename sources.
+func (c cluster) paths() []string {
+	var paths []string
+	for _, ch := range c.Changes {
+		if ch.OldPath != "" {
+			paths = append(paths, ch.OldPath)
+		}
+		paths = append(paths, ch.Path)
+	}
+	return paths
+}
+
+// clusterKey picks the group a path belongs to: documentation goes together,
+// everything else is grouped by its top-level directory, and files at the
+// repository root form their own group.
+func clusterKey(p string) string {
+	if slices.Contains(generator.DocExts, strings.ToLower(path.Ext(p))) || strings.HasPrefix(p, "docs/") {
+		return "docs"
+	}
+	if dir, _, ok := strings.Cut(p, "/"); ok {
+		return dir
+	}
+	return "root"
+}
+
+// clusterChanges groups changes into clusters, ordered by name so the plan is
+// deterministic.
+func clusterChanges(changes []gitctx.FileChange) []cluster {
+	byKey := map[string]*cluster{}
+	for _, ch := range changes {
+		key := clusterKey(ch.Path)
+		c := byKey[key]
+		if c == nil {
+			c = &cluster{Name: key}
+			byKey[key] = c
+		}
+		c.Changes = append(c.Changes, ch)
+	}
+	var clusters []cluster
+	for _, c := range byKey {
+		clusters = append(clusters, *c)
+	}
+	slices.SortFunc(clusters, func(a, b cluster) int { return strings.Compare(a.Name, b.Name) })
+	return clusters
+}
+
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`*?[]#~=%|&;<>()") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// splitUnit is one piece of the diff --split-by model groups: a hunk, or a
+// whole file when it has no hunks (binary files, mode changes) or its
+// contents are kept from the model.
+type splitUnit struct {
+	File   int // index of the file it belongs to
+	Path   string
+	Header string // the file's diff header, which every patch needs
+	Text   string // the hunk, or the rest of the file's diff when Whole
+	Whole  bool
+}
+
+// splitUnits cuts a diff into splitUnits, in diff order.
+func splitUnits(diff string) []splitUnit {
+	var units []splitUnit
+	for i, f := range gitctx.SplitFiles(diff) {
+		header, hunks := splitHunks(f.Text)
+		if len(hunks) == 0 || isSensitive(f.Path, sensitivePatterns) || isSensitive(f.Path, excludePatterns) {
+			units = append(units, splitUnit{File: i, Path: f.Path, Header: header, Text: strings.Join(hunks, ""), Whole: true})
+			continue
+		}
+		for _, h := range hunks {
+			units = append(units, splitUnit{File: i, Path: f.Path, Header: header, Text: h})
+		}
+	}
+	return units
+}
+
+// redactUnits returns units with their secrets redacted, for showing them to
+// the model; the patches committed are cut from the originals. With
+// --block-on-secret it ends the run instead.
+func redactUnits(units []splitUnit) []splitUnit {
+	shown := slices.Clone(units)
+	var secrets []secretFinding
+	for i, u := range units {
+		if u.Whole {
+			continue
+		}
+		// The header gives the findings their path; redaction keeps it as is.
+		redacted, found := redactDiff(u.Header + u.Text)
+		shown[i].Text = strings.TrimPrefix(redacted, u.Header)
+		secrets = append(secrets, found...)
+	}
+	if len(secrets) > 0 && blockSecrets {
+		refuseSecrets(secrets, "--block-on-secret")
+	}
+	return shown
+}
+
+// maxUnitLines is how much of each hunk the model sees when grouping them.
+const maxUnitLines = 40
+
+const splitSystemPrompt = `You split a set of changes into logically separate commits.
+Each numbered item is one hunk of a file's diff, or a whole file.
+Group the items so each group is one self-contained change, such as a fix, a refactor, or a feature, and keep items that depend on each other together.
+Reply with one line per commit, in the order they should be committed: a short name for the group, a colon, and its item numbers separated by commas, e.g. "retry fix: 1, 4, 5".
+Use every item exactly once. Reply with nothing else.`
+
+// unitsPrompt lists the units for splitSystemPrompt, numbered from 1, with
+// long hunks cut short.
+func unitsPrompt(units []splitUnit) string {
+	var b strings.Builder
+	for i, u := range units {
+		switch {
+		case u.Whole:
+			fmt.Fprintf(&b, "[%d] %s (whole file, contents not shown)\n\n", i+1, u.Path)
+		default:
+			lines := strings.SplitAfter(strings.TrimRight(u.Text, "\n"), "\n")
+			if len(lines) > maxUnitLines {
+				lines = append(lines[:maxUnitLines], fmt.Sprintf("\n… (%d more lines)", len(lines)-maxUnitLines))
+			}
+			fmt.Fprintf(&b, "[%d] %s\n%s\n\n", i+1, u.Path, strings.Join(lines, ""))
+		}
+	}
+	return b.String()
+}
+
+// parseUnitGroups reads the model's "name: 1, 4, 5" lines into groups of
+// unit indexes. Numbers out of range or already used are ignored, and units
+// the model left out form a last group of their own.
+func parseUnitGroups(answer string, n int) (names []string, groups [][]int) {
+	used := make([]bool, n)
+	for _, line := range strings.Split(answer, "\n") {
+		i := strings.LastIndex(line, ":")
+		if i < 0 {
+			continue
+		}
+		var group []int
+		for _, f := range strings.FieldsFunc(line[i+1:], func(r rune) bool { return r == ',' || r == ' ' }) {
+			k, err := strconv.Atoi(strings.Trim(f, "[]#."))
+			if err != nil || k < 1 || k > n || used[k-1] {
+				continue
+			}
+			used[k-1] = true
+			group = append(group, k-1)
+		}
+		if len(group) > 0 {
+			names = append(names, strings.Trim(line[:i], " -*`\""))
+			groups = append(groups, group)
+		}
+	}
+	var rest []int
+	for k, u := range used {
+		if !u {
+			rest = append(rest, k)
+		}
+	}
+	if len(groups) > 0 && len(rest) > 0 {
+		names = append(names, "other changes")
+		groups = append(groups, rest)
+	}
+	return names, groups
+}
+
+// modelClusters asks the model to group the hunks of the change diffArgs
+// selects into commits. Each cluster carries the patch of its hunks.
+func modelClusters(ctx context.Context, g *generator.Generator, opts generator.Options, diffArgs []string) ([]cluster, error) {
+	// Without rename detection every hunk applies on its own; --binary
+	// keeps binary files committable.
+	diff, err := runGit(slices.Concat(diffArgs, []string{"--no-renames", "--binary", "--no-ext-diff", "--submodule=short"})...)
+	if err != nil {
+		return nil, err
+	}
+	nameStatus, err := runGit(slices.Concat(diffArgs, []string{"--no-renames", "--name-status"})...)
+	if err != nil {
+		return nil, err
+	}
+	statusOf := map[string]string{}
+	for _, ch := range gitctx.ParseNameStatus(nameStatus) {
+		statusOf[ch.Path] = ch.Status
+	}
+
+	units := splitUnits(diff)
+	if len(units) < 2 {
+		return nil, nil
+	}
+	answer, err := g.Ask(ctx, opts, splitSystemPrompt, unitsPrompt(redactUnits(units)))
+	if err != nil {
+		return nil, err
+	}
+	debugf("Hunk groups:\n%s", answer)
+	names, groups := parseUnitGroups(answer, len(units))
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("model answered %q, not a list of groups", answer)
+	}
+
+	clusters := make([]cluster, len(groups))
+	for i, group := range groups {
+		slices.Sort(group)
+		c := cluster{Name: names[i]}
+		var patch strings.Builder
+		last := -1
+		for _, k := range group {
+			u := units[k]
+			if u.File != last {
+				c.Changes = append(c.Changes, gitctx.FileChange{Status: statusOf[u.Path], Path: u.Path})
+				patch.WriteString(u.Header)
+				last = u.File
+			}
+			patch.WriteString(u.Text)
+		}
+		c.Patch = strings.TrimRight(patch.String(), "\n") + "\n"
+		clusters[i] = c
+	}
+	return clusters, nil
+}
+
+// redactClusterSecrets redacts the diff of one cluster, or ends the run with
+// --block-on-secret when it has secrets.
+func redactClusterSecrets(gc gitctx.CommitContext) gitctx.CommitContext {
+	gc, secrets := redactSecrets(gc)
+	if len(secrets) > 0 && blockSecrets {
+		refuseSecrets(secrets, "--block-on-secret")
+	}
+	return gc
+}
+
+// nameStatusOf formats changes as --name-status output.
+func nameStatusOf(changes []gitctx.FileChange) string {
+	var lines []string
+	for _, ch := range changes {
+		lines = append(lines, ch.Status+"\t"+ch.Path)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitStep is one commit of a split plan.
+type splitStep struct {
+	Cluster cluster
+	Message string
+}
+
+// runSplit generates one message per cluster of changed files, prints the
+// resulting plan, and optionally executes it. At most concurrency clusters,
+// each running git and the model, are worked on at a time. With auto the plan
+// is committed step by step without asking, unless confirm asks before each
+// commit. With conflicts, unresolved merge conflicts, the plan is only
+// printed.
+func runSplit(ctx context.Context, g *generator.Generator, opts generator.Options, post postProcess, style generator.Style, mode SplitMode, diffArgs []string, gc gitctx.CommitContext, reader *bufio.Reader, concurrency int, auto, confirm, conflicts bool) {
+	clusters := clusterChanges(gitctx.ParseNameStatus(gc.NameStatus))
+	if mode == SplitByModel {
+		fmt.Println("Grouping the hunks into commits...")
+		mc, err := modelClusters(ctx, g, opts, diffArgs)
+		switch {
+		case err != nil:
+			warnf("Could not group the hunks (%v); grouping files by directory instead.", err)
+		case mc == nil:
+			clusters = nil
+		default:
+			clusters = mc
+		}
+	}
+	if len(clusters) < 2 {
+		fmt.Println("Changes form a single group; nothing to split.")
+		return
+	}
+
+	fmt.Printf("Generating %d commit messages...", len(clusters))
+	steps := make([]splitStep, len(clusters))
+	errs := make([]error, len(clusters))
+	done := make(chan struct{})
+	sem := make(chan struct{}, max(concurrency, 1))
+	for i, c := range clusters {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			cgc := gc
+			if c.Patch != "" {
+				cgc.Diff, cgc.NameStatus = c.Patch, nameStatusOf(c.Changes)
+				cgc, _ = withholdSensitive(cgc)
+				cgc = redactClusterSecrets(cgc)
+				cgc, _ = excludeFiles(cgc, excludePatterns)
+				sg, err := g.Generate(ctx, opts, cgc)
+				steps[i] = splitStep{Cluster: c, Message: post.apply(sg.Message)}
+				errs[i] = err
+				return
+			}
+			base := slices.Concat(diffArgs, []string{"-M", "-C"})
+			pathspec := append([]string{"--"}, c.paths()...)
+			var err error
+			if cgc.Diff, err = runGit(slices.Concat(base, pathspec)...); err != nil {
+				errs[i] = err
+				return
+			}
+			cgc, _ = withholdSensitive(cgc)
+			cgc = redactClusterSecrets(cgc)
+			if cgc.NameStatus, err = runGit(slices.Concat(base, []string{"--name-status"}, pathspec)...); err != nil {
+				errs[i] = err
+				return
+			}
+			cgc, _ = excludeFiles(cgc, excludePatterns)
+			sg, err := g.Generate(ctx, opts, cgc)
+			steps[i] = splitStep{Cluster: c, Message: post.apply(sg.Message)}
+			errs[i] = err
+		}()
+	}
+	for range clusters {
+		<-done
+	}
+	fmt.Println()
+
+	for i, err := range errs {
+		if err != nil {
+			errorf("Generation failed for %s: %v", clusters[i].Name, err)
+			os.Exit(exitGenerationFailed)
+		}
+	}
+
+	if slices.Contains(diffArgs, "--staged") {
+		withStagedPatches(steps)
+	}
+	if auto {
+		commitSteps(steps, style, reader, confirm)
+		return
+	}
+
+	fmt.Println("\n" + header("Suggested commits:"))
+	for i, st := range steps {
+		if st.Cluster.Patch != "" {
+			fmt.Printf("\n# %d) %s\n", i+1, colorMessage(st.Message))
+			hunks := "1 hunk"
+			if n := strings.Count("\n"+st.Cluster.Patch, "\n@@"); n != 1 {
+				hunks = fmt.Sprintf("%d hunks", n)
+			}
+			fmt.Printf("#    %s of %s\n", hunks, strings.Join(st.Cluster.paths(), ", "))
+			continue
+		}
+		var quoted []string
+		for _, p := range st.Cluster.paths() {
+			quoted = append(quoted, shellQuote(p))
+		}
+		files := strings.Join(quoted, " ")
+		fmt.Printf("\n# %d) %s\n", i+1, colorMessage(st.Message))
+		fmt.Printf("git add -- %s && %s -- %s\n", files, formatForClipboard(st.Message, ClipFormatCommand), files)
+	}
+
+	if conflicts {
+		warnf("\nUnresolved merge conflicts detected; not offering to run the plan. Resolve them or pass --force.")
+		return
+	}
+	fmt.Print("\nRun this plan now? [y/N]: ")
+	input, _ := reader.ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(input)); a != "y" && a != "yes" {
+		return
+	}
+	clearIndexForPatches(steps)
+	for _, st := range steps {
+		commitStep(st, style)
+	}
+}
+
+// commitSteps commits each step of a split plan in turn, printing its
+// message first. With confirm each commit is asked for: n skips the step and
+// q stops, leaving the remaining changes uncommitted.
+func commitSteps(steps []splitStep, style generator.Style, reader *bufio.Reader, confirm bool) {
+	clearIndexForPatches(steps)
+	committed := 0
+	for i, st := range steps {
+		fmt.Printf("\n%s %s\n%s\n", header(fmt.Sprintf("%d/%d", i+1, len(steps))), st.Cluster.Name, colorMessage(st.Message))
+		if confirm {
+			fmt.Print("Commit this? [Y/n/q]: ")
+			input, _ := reader.ReadString('\n')
+			switch strings.ToLower(strings.TrimSpace(input)) {
+			case "n", "no":
+				continue
+			case "q", "quit":
+				fmt.Printf("\nStopped after %d of %d commits.\n", committed, len(steps))
+				return
+			}
+		}
+		commitStep(st, style)
+		committed++
+	}
+	fmt.Println("\n" + success(fmt.Sprintf("Created %d of %d commits.", committed, len(steps))))
+}
+
+// withStagedPatches gives each step of a plan for the staged changes the
+// staged diff of its files as its patch, so that its commit holds what was
+// staged for them and nothing of the working tree, as with hunk patches.
+func withStagedPatches(steps []splitStep) {
+	for i, st := range steps {
+		if st.Cluster.Patch != "" {
+			continue
+		}
+		patch, err := runGit(slices.Concat([]string{"diff", "--staged", "--binary", "--no-renames", "--"}, st.Cluster.paths())...)
+		if err != nil {
+			gitFatalf("git diff failed: %v", err)
+		}
+		steps[i].Cluster.Patch = strings.TrimRight(patch, "\n") + "\n"
+	}
+}
+
+// clearIndexForPatches unstages everything before a plan of hunk patches
+// runs, so each commit holds exactly its hunks. The working tree is left
+// alone, so hunks of skipped steps stay there as unstaged changes.
+func clearIndexForPatches(steps []splitStep) {
+	if len(steps) == 0 || steps[0].Cluster.Patch == "" {
+		return
+	}
+	if _, err := runGit("reset", "-q"); err != nil {
+		gitFatalf("git reset failed: %v", err)
+	}
+}
+
+// commitStep stages and commits the files of one step, and nothing else.
+// A step with a patch stages just its hunks.
+func commitStep(st splitStep, style generator.Style) {
+	if st.Cluster.Patch != "" {
+		cmd := gitCmd("apply", "--cached", "-")
+		cmd.Stdin = strings.NewReader(st.Cluster.Patch)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			gitFatalf("git apply --cached failed: %v", gitctx.CommandError(err, string(out)))
+		}
+		if err := commitWithMessage(commitText(st.Message, style)); err != nil {
+			gitFatalf("git commit failed: %v", err)
+		}
+		return
+	}
+	paths := st.Cluster.paths()
+	if _, err := runGit(append([]string{"add", "--"}, paths...)...); err != nil {
+		gitFatalf("git add failed: %v", err)
+	}
+	if err := commitWithMessage(commitText(st.Message, style), append([]string{"--"}, paths...)...); err != nil {
+		gitFatalf("git commit failed: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+func TestClusterChanges(t *testing.T) {
+	tests := []struct {
+		name       string
+		nameStatus string
+		want       []string // per cluster: "name: paths..."
+	}{
+		{
+			name:       "by top-level directory",
+			nameStatus: "M\tapi/server.go\nM\tweb/app.js\nA\tapi/routes.go",
+			want:       []string{"api: api/server.go api/routes.go", "web: web/app.js"},
+		},
+		{
+			name:       "root files",
+			nameStatus: "M\tmain.go\nM\tgo.mod\nM\tpkg/util.go",
+			want:       []string{"pkg: pkg/util.go", "root: main.go go.mod"},
+		},
+		{
+			name:       "nested directories",
+			nameStatus: "M\tinternal/auth/token.go\nM\tinternal/db/pool.go\nD\tcmd/tool/main.go",
+			want:       []string{"cmd: cmd/tool/main.go", "internal: internal/auth/token.go internal/db/pool.go"},
+		},
+		{
+			name:       "documentation",
+			nameStatus: "M\tREADME.md\nM\tapi/NOTES.txt\nA\tdocs/guide/setup.html\nM\tapi/server.go",
+			want:       []string{"api: api/server.go", "docs: README.md api/NOTES.txt docs/guide/setup.html"},
+		},
+		{
+			name:       "renames go by their new path",
+			nameStatus: "R100\tcmd/old.go\tinternal/new.go\nM\tinternal/other.go\nR090\tmain.go\tcli/main.go",
+			want:       []string{"cli: main.go cli/main.go", "internal: cmd/old.go internal/new.go internal/other.go"},
+		},
+		{
+			name:       "single group",
+			nameStatus: "M\tapi/a.go\nM\tapi/b.go",
+			want:       []string{"api: api/a.go api/b.go"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range clusterChanges(gitctx.ParseNameStatus(tt.nameStatus)) {
+				got = append(got, fmt.Sprintf("%s: %s", c.Name, strings.Join(c.paths(), " ")))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("clusters:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
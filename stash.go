@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+var stashIndexRe = regexp.MustCompile(`^\d+$`)
+
+// resolveStash checks that ref names an existing stash entry and returns it
+// in stash@{N} form. A bare number N is taken as stash@{N}.
+func resolveStash(ref string) (string, error) {
+	if stashIndexRe.MatchString(ref) {
+		ref = "stash@{" + ref + "}"
+	}
+	out, err := runGit("stash", "list", "--format=%gd")
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(strings.Split(out, "\n"), ref) {
+		if out == "" {
+			return "", fmt.Errorf("no stash entries")
+		}
+		return "", fmt.Errorf("%s is not a stash entry (see git stash list)", ref)
+	}
+	return ref, nil
+}
+
+// stashPush saves the changes as a stash entry named by the message subject;
+// staged saves only the index, like git stash push --staged, and paths limits
+// it to those files.
+func stashPush(msg string, staged bool, paths []string) error {
+	subject, _ := generator.SplitMessage(msg)
+	args := []string{"stash", "push", "-m", strings.TrimSpace(subject)}
+	if staged {
+		args = append(args, "--staged")
+	}
+	if len(paths) > 0 {
+		args = slices.Concat(args, []string{"--"}, paths)
+	}
+	_, err := runGit(args...)
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// maxStyleGuideTokens bounds how much of a --style-guide file goes into the
+// prompt.
+const maxStyleGuideTokens = 1000
+
+// loadStyleGuide reads a team's commit conventions. In a Markdown document
+// such as CONTRIBUTING.md only the sections whose heading mentions commits
+// are kept, when there are any, and the result is cut at a paragraph
+// boundary to fit maxStyleGuideTokens.
+func loadStyleGuide(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	text := gitctx.NormalizeNewlines(string(data))
+	if sections := commitSections(text); sections != "" {
+		text = sections
+	}
+	return truncateParagraphs(strings.TrimSpace(text), maxStyleGuideTokens*4), nil
+}
+
+// commitSections returns the Markdown sections whose heading contains
+// "commit", each running until the next heading of the same or higher level.
+// Lines in fenced code blocks are never taken for headings.
+func commitSections(text string) string {
+	var b strings.Builder
+	keepLevel := 0 // level of the heading being kept, 0 when not keeping
+	inFence := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "```") {
+			inFence = !inFence
+		}
+		if level := headingLevel(line); level > 0 && !inFence {
+			if keepLevel > 0 && level <= keepLevel {
+				keepLevel = 0
+			}
+			if keepLevel == 0 && strings.Contains(strings.ToLower(line), "commit") {
+				keepLevel = level
+			}
+		}
+		if keepLevel > 0 {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+func headingLevel(line string) int {
+	n := len(line) - len(strings.TrimLeft(line, "#"))
+	if n == 0 || n > 6 || !strings.HasPrefix(line[n:], " ") {
+		return 0
+	}
+	return n
+}
+
+// truncateParagraphs keeps whole paragraphs of text up to limit bytes,
+// falling back to a hard cut when the first paragraph alone is too long.
+func truncateParagraphs(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	cut := strings.LastIndex(text[:limit], "\n\n")
+	if cut <= 0 {
+		cut = limit
+	}
+	return strings.TrimSpace(text[:cut]) + "\n[style guide truncated]"
+}
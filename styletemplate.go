@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// styleTemplatePlaceholders are the fields a --style-template fills in.
+var styleTemplatePlaceholders = []string{"{type}", "{scope}", "{subject}", "{body}"}
+
+// placeholderRe matches anything written like a placeholder, so misspelled
+// ones such as {scop} are caught.
+var placeholderRe = regexp.MustCompile(`\{[A-Za-z_][A-Za-z0-9_-]*\}`)
+
+// brokenPlaceholderRe matches a placeholder missing one of its braces, such
+// as "{scope" or "subject}", in a template whose placeholders are filled in.
+var brokenPlaceholderRe = regexp.MustCompile(`\{[A-Za-z_][A-Za-z0-9_-]*([^A-Za-z0-9_}-]|$)|(^|[^{A-Za-z0-9_-])[A-Za-z_][A-Za-z0-9_-]*\}`)
+
+// renderStyleTemplate fills in the placeholders of tmpl from fields, keyed by
+// name without braces. Missing fields render empty.
+func renderStyleTemplate(tmpl string, fields map[string]string) string {
+	return placeholderRe.ReplaceAllStringFunc(tmpl, func(p string) string {
+		return fields[strings.Trim(p, "{}")]
+	})
+}
+
+// loadStyleTemplate reads a user-defined message format such as
+// "{type}({scope}): {subject}\n\n{body}". A file without any placeholder is
+// almost certainly the wrong file, and one with a placeholder that isn't
+// known would reach the model as literal text. The template is also rendered
+// with every field empty, where only its literal text is left, and a
+// placeholder with a brace missing shows.
+func loadStyleTemplate(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	tmpl := strings.TrimSpace(gitctx.NormalizeNewlines(string(data)))
+	var unknown []string
+	for _, p := range placeholderRe.FindAllString(tmpl, -1) {
+		if !slices.Contains(styleTemplatePlaceholders, p) && !slices.Contains(unknown, p) {
+			unknown = append(unknown, p)
+		}
+	}
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("%s has unknown placeholders %s (the known ones are %s)", path, strings.Join(unknown, ", "), strings.Join(styleTemplatePlaceholders, ", "))
+	}
+	if !slices.ContainsFunc(styleTemplatePlaceholders, func(p string) bool { return strings.Contains(tmpl, p) }) {
+		return "", fmt.Errorf("%s uses none of the placeholders %s", path, strings.Join(styleTemplatePlaceholders, ", "))
+	}
+	for i, line := range strings.Split(renderStyleTemplate(tmpl, nil), "\n") {
+		if m := brokenPlaceholderRe.FindString(line); m != "" {
+			return "", fmt.Errorf("%s: line %d has a placeholder with a brace missing near %q", path, i+1, strings.TrimSpace(m))
+		}
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadStyleTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		err     string
+	}{
+		{"valid", "{type}({scope}): {subject}\r\n\r\nWhy: {body}\r\n", "{type}({scope}): {subject}\n\nWhy: {body}", ""},
+		{"subject only", "[{subject}]", "[{subject}]", ""},
+		{"other braces", "{subject} {1} { body }", "{subject} {1} { body }", ""},
+		{"misspelled", "{type}({scop}): {subject} {scop}", "", "unknown placeholders {scop} "},
+		{"several unknown", "{kind}: {subject} ({ticket})", "", "unknown placeholders {kind}, {ticket} "},
+		{"no placeholders", "just text", "", "uses none of the placeholders"},
+		{"unclosed", "{type}({scope): {subject}", "", "line 1 has a placeholder with a brace missing near \"{scope)\""},
+		{"unopened", "{type}: {subject}\n\nsubject}", "", "line 3 has a placeholder with a brace missing near \"subject}\""},
+		{"unclosed at the end", "{type}: {subject}\n\n{body", "", "line 3 has a placeholder with a brace missing near \"{body\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "format.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := loadStyleTemplate(path)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("error = %v, want one containing %q", err, tt.err)
+			case got != tt.want:
+				t.Errorf("template = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderStyleTemplate(t *testing.T) {
+	tmpl := "{type}({scope}): {subject}\n\nWhy: {body}"
+	if got, want := renderStyleTemplate(tmpl, map[string]string{"type": "fix", "scope": "api", "subject": "handle nil", "body": "it crashed"}), "fix(api): handle nil\n\nWhy: it crashed"; got != want {
+		t.Errorf("renderStyleTemplate = %q, want %q", got, want)
+	}
+	if got, want := renderStyleTemplate(tmpl, nil), "(): \n\nWhy: "; got != want {
+		t.Errorf("renderStyleTemplate with no fields = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// TicketPosition is where a ticket ID found by ticket_pattern goes.
+type TicketPosition string
+
+const (
+	TicketInFooter  TicketPosition = "footer"  // a "Refs: <id>" trailer
+	TicketInSubject TicketPosition = "subject" // before the description, after any type prefix
+)
+
+func parseTicketPosition(s string) (TicketPosition, error) {
+	switch p := TicketPosition(strings.ToLower(s)); p {
+	case TicketInFooter, TicketInSubject:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid ticket position %q (want footer or subject)", s)
+}
+
+// ticketRef is a compiled ticket_pattern and where its match is written.
+type ticketRef struct {
+	Pattern  *regexp.Regexp
+	Position TicketPosition
+}
+
+// newTicketRef compiles the config's ticket_pattern; a nil ticketRef means
+// none is set.
+func newTicketRef(pattern, position string) (*ticketRef, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ticket_pattern: %v", err)
+	}
+	pos, err := parseTicketPosition(cmp.Or(position, string(TicketInFooter)))
+	if err != nil {
+		return nil, err
+	}
+	return &ticketRef{Pattern: re, Position: pos}, nil
+}
+
+// find returns the ticket ID in branch: the pattern's first group when it
+// has one, else the whole match.
+func (t *ticketRef) find(branch string) string {
+	m := t.Pattern.FindStringSubmatch(branch)
+	switch {
+	case m == nil:
+		return ""
+	case len(m) > 1:
+		return m[1]
+	}
+	return m[0]
+}
+
+// apply writes the ticket ID branch names into msg, unless msg mentions it
+// already.
+func (t *ticketRef) apply(msg, branch string) string {
+	id := t.find(branch)
+	if id == "" || strings.Contains(msg, id) {
+		return msg
+	}
+	if t.Position == TicketInFooter {
+		return appendTrailer(msg, "Refs", id)
+	}
+	subject, rest := generator.SplitMessage(msg)
+	at := 0
+	if loc := typePrefixRe.FindStringIndex(subject); loc != nil {
+		at = loc[1]
+	}
+	desc := strings.TrimLeft(subject[at:], " ")
+	subject = strings.TrimRight(subject[:at], " ")
+	if subject != "" {
+		subject += " "
+	}
+	return generator.JoinMessage(subject+id+" "+desc, rest)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	jiraKeyRe     = regexp.MustCompile(`\b([A-Z][A-Z0-9]+-\d+)\b`)
+	issueNumberRe = regexp.MustCompile(`(?:^|[/_-])(?:issue-|gh-|#)?(\d+)(?:[/_-]|$)`)
+	githubRepoRe  = regexp.MustCompile(`github\.com[:/]([^/]+)/([^/]+?)(?:\.git)?/?$`)
+)
+
+// maxTicketDescription caps how much of a ticket description goes into the
+// prompt.
+const maxTicketDescription = 500
+
+// ticketFromBranch finds a ticket identifier in a branch name: a Jira key
+// such as "PROJ-123" for jira, an issue number such as "123-fix-login" or
+// "issue-123" for github.
+func ticketFromBranch(tracker, branch string) string {
+	switch tracker {
+	case "jira":
+		if m := jiraKeyRe.FindStringSubmatch(strings.ToUpper(branch)); m != nil {
+			return m[1]
+		}
+	case "github":
+		if m := issueNumberRe.FindStringSubmatch(branch); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// fetchTicket returns "<id>: <title>" plus the start of the description for
+// the ticket named by branch, or "" when the branch names none.
+func fetchTicket(cfg Config, branch string) (string, error) {
+	id := ticketFromBranch(cfg.Tracker, branch)
+	if id == "" {
+		return "", nil
+	}
+	var title, description string
+	var err error
+	switch cfg.Tracker {
+	case "jira":
+		title, description, err = fetchJiraIssue(cfg, id)
+	case "github":
+		title, description, err = fetchGitHubIssue(cfg, id)
+	default:
+		return "", fmt.Errorf("unknown tracker %q (want jira or github)", cfg.Tracker)
+	}
+	if err != nil {
+		return "", fmt.Errorf("ticket %s: %w", id, err)
+	}
+	description = strings.TrimSpace(description)
+	if len(description) > maxTicketDescription {
+		description = description[:maxTicketDescription] + "..."
+	}
+	ticket := id + ": " + strings.TrimSpace(title)
+	if description != "" {
+		ticket += "\n" + description
+	}
+	return ticket, nil
+}
+
+func fetchJiraIssue(cfg Config, key string) (title, description string, err error) {
+	if cfg.TrackerURL == "" {
+		return "", "", fmt.Errorf("tracker_url is not set")
+	}
+	url := strings.TrimRight(cfg.TrackerURL, "/") + "/rest/api/2/issue/" + key + "?fields=summary,description"
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	token := cfg.TrackerToken
+	if token == "" {
+		token = os.Getenv("JIRA_TOKEN")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := githubClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", "", fmt.Errorf("GET %s: %s: %s", url, resp.Status, strings.TrimSpace(string(body)))
+	}
+	var issue struct {
+		Fields struct {
+			Summary     string `json:"summary"`
+			Description string `json:"description"`
+		} `json:"fields"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&issue); err != nil {
+		return "", "", err
+	}
+	return issue.Fields.Summary, issue.Fields.Description, nil
+}
+
+// fetchGitHubIssue reads an issue from the repository in tracker_url
+// ("owner/repo" or its GitHub URL), or from the origin remote when unset.
+func fetchGitHubIssue(cfg Config, number string) (title, description string, err error) {
+	repo := strings.TrimPrefix(strings.TrimPrefix(cfg.TrackerURL, "https://"), "github.com/")
+	if repo == "" {
+		origin, err := runGit("remote", "get-url", "origin")
+		if err != nil {
+			return "", "", fmt.Errorf("tracker_url is not set and there is no origin remote")
+		}
+		m := githubRepoRe.FindStringSubmatch(strings.TrimSpace(origin))
+		if m == nil {
+			return "", "", fmt.Errorf("tracker_url is not set and origin is not a GitHub repository")
+		}
+		repo = m[1] + "/" + m[2]
+	}
+	var issue struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+	}
+	if _, err := githubGet("https://api.github.com/repos/"+strings.Trim(repo, "/")+"/issues/"+number, &issue); err != nil {
+		return "", "", err
+	}
+	return issue.Title, issue.Body, nil
+}
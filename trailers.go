@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseTrailers checks the --trailer and trailers lines, "Key: value" each,
+// as git commit --trailer takes them; "Key=value" is accepted too.
+func parseTrailers(lines []string) ([]string, error) {
+	var trailers []string
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if key, value, ok := strings.Cut(l, "="); ok && !strings.Contains(key, ":") {
+			l = strings.TrimSpace(key) + ": " + strings.TrimSpace(value)
+		}
+		if !trailerLineRe.MatchString(l) {
+			return nil, fmt.Errorf("trailer %q isn't \"Key: value\", such as \"Co-authored-by: Name <name@example.com>\"", l)
+		}
+		trailers = append(trailers, l)
+	}
+	return trailers, nil
+}
+
+// withTrailers appends each trailer that msg doesn't already have, with
+// {model} replaced by the model that wrote the message, for provenance
+// trailers such as "Generated-by: commit ({model})".
+func withTrailers(msg string, trailers []string, model string) string {
+	for _, t := range trailers {
+		t = strings.ReplaceAll(t, "{model}", model)
+		if !hasLine(msg, t) {
+			msg = appendTrailerLine(msg, t)
+		}
+	}
+	return msg
+}
+
+func hasLine(text, line string) bool {
+	for _, l := range strings.Split(text, "\n") {
+		if strings.TrimSpace(l) == line {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// tuiResult is how the user left the review screen.
+type tuiResult int
+
+const (
+	tuiCancel tuiResult = iota
+	tuiAccept           // use the configured action
+	tuiCommit           // commit regardless of the configured action
+)
+
+var errNotTerminal = errors.New("--tui needs an interactive terminal")
+
+type regenDoneMsg struct {
+	message string
+	err     error
+}
+
+type tuiModel struct {
+	summary string
+	area    textarea.Model
+	regen   func() (string, error)
+	busy    bool
+	err     error
+	result  tuiResult
+}
+
+func (m tuiModel) Init() tea.Cmd { return textarea.Blink }
+
+func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		if msg.Width > 20 {
+			m.area.SetWidth(msg.Width - 2)
+		}
+	case regenDoneMsg:
+		m.busy = false
+		m.err = msg.err
+		if msg.err == nil {
+			m.area.SetValue(msg.message)
+		}
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			m.result = tuiCancel
+			return m, tea.Quit
+		case "ctrl+s":
+			m.result = tuiAccept
+			return m, tea.Quit
+		case "ctrl+g":
+			m.result = tuiCommit
+			return m, tea.Quit
+		case "ctrl+r":
+			if m.busy {
+				return m, nil
+			}
+			m.busy, m.err = true, nil
+			regen := m.regen
+			return m, func() tea.Msg {
+				message, err := regen()
+				return regenDoneMsg{message, err}
+			}
+		}
+	}
+	var cmd tea.Cmd
+	m.area, cmd = m.area.Update(msg)
+	return m, cmd
+}
+
+func (m tuiModel) View() string {
+	var b strings.Builder
+	b.WriteString(header("Changes") + "\n" + m.summary + "\n\n")
+	b.WriteString(header("Commit message") + "\n" + m.area.View() + "\n\n")
+	switch {
+	case m.busy:
+		b.WriteString(warn("Regenerating...") + "\n")
+	case m.err != nil:
+		b.WriteString(failure("Regenerate failed: "+m.err.Error()) + "\n")
+	}
+	b.WriteString(paint(ansiDim, "ctrl+r regenerate • ctrl+s accept • ctrl+g commit • esc cancel"))
+	return b.String()
+}
+
+// diffSummary lists the changed files with their status and line counts, at
+// most limit of them.
+func diffSummary(gc gitctx.CommitContext, limit int) string {
+	changed := map[string]int{}
+	for _, f := range gitctx.SplitFiles(gc.Diff) {
+		changed[f.Path] = f.Changed
+	}
+	changes := gitctx.ParseNameStatus(gc.NameStatus)
+	var lines []string
+	for i, c := range changes {
+		if i == limit {
+			lines = append(lines, fmt.Sprintf("  ... and %d more", len(changes)-limit))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("  %-4s %s (%d lines)", c.Status, c.Path, changed[c.Path]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runTUI shows the message in an editable text area next to a summary of the
+// changes. It returns the edited message and how the screen was left.
+func runTUI(gc gitctx.CommitContext, message string, regen func() (string, error)) (string, tuiResult, error) {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return "", tuiCancel, errNotTerminal
+	}
+	area := textarea.New()
+	area.SetValue(message)
+	area.SetWidth(72)
+	area.SetHeight(10)
+	area.CharLimit = 0
+	area.ShowLineNumbers = false
+	area.Focus()
+
+	final, err := tea.NewProgram(tuiModel{summary: diffSummary(gc, 10), area: area, regen: regen}).Run()
+	if err != nil {
+		return "", tuiCancel, err
+	}
+	m := final.(tuiModel)
+	return strings.TrimSpace(m.area.Value()), m.result, nil
+}
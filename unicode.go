@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+
+	"github.com/muhammedsamal/commit/generator"
+)
+
+// asciiReplacer maps typographic characters models like to emit onto their
+// ASCII equivalents. Only characters with an unambiguous ASCII spelling are
+// listed; accented letters, CJK, emoji and the like are left alone.
+var asciiReplacer = strings.NewReplacer(
+	// quotes and primes
+	"‘", "'", "’", "'", "‚", "'", "‛", "'", "′", "'",
+	"“", `"`, "”", `"`, "„", `"`, "‟", `"`, "″", `"`,
+	// hyphens, dashes and minus
+	"\u2010", "-", "\u2011", "-", "\u2012", "-", "\u2013", "-", "\u2014", "-", "\u2015", "-", "\u2212", "-",
+	// ellipsis
+	"…", "...",
+	// non-breaking and fixed-width spaces
+	"\u00a0", " ", "\u2002", " ", "\u2003", " ", "\u2004", " ", "\u2005", " ", "\u2006", " ",
+	"\u2007", " ", "\u2008", " ", "\u2009", " ", "\u200a", " ", "\u202f", " ", "\u205f", " ",
+	// zero-width characters and the byte order mark
+	"\u200b", "", "\u200c", "", "\u200d", "", "\u2060", "", "\ufeff", "",
+)
+
+// normalizeUnicode rewrites the subject and/or the body with asciiReplacer.
+func normalizeUnicode(msg string, subject, body bool) string {
+	s, rest := generator.SplitMessage(msg)
+	if subject {
+		s = asciiReplacer.Replace(s)
+	}
+	if body {
+		rest = asciiReplacer.Replace(rest)
+	}
+	return generator.JoinMessage(s, rest)
+}
+
+// displayWidth is how many columns s takes in a terminal or git log: East
+// Asian wide and fullwidth characters, such as kanji, take two. Subject
+// length limits are checked against it.
+func displayWidth(s string) int {
+	n := utf8.RuneCountInString(s)
+	for _, r := range s {
+		if k := width.LookupRune(r).Kind(); k == width.EastAsianWide || k == width.EastAsianFullwidth {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestNormalizeUnicode(t *testing.T) {
+	tests := []struct {
+		name          string
+		msg           string
+		subject, body bool
+		want          string
+	}{
+		{"smart quotes", "fix: don’t drop “quoted” args", true, false, `fix: don't drop "quoted" args`},
+		{"low and reversed quotes", "docs: „this‟ and ‚that‛", true, false, `docs: "this" and 'that'`},
+		{"primes", "feat: accept 5′ and 10″", true, false, `feat: accept 5' and 10"`},
+		{"non-breaking spaces", "fix: trim\u00a0input\u202fnow", true, false, "fix: trim input now"},
+		{"dashes and minus", "feat: add a–b — and −1", true, false, "feat: add a-b - and -1"},
+		{"non-breaking hyphen", "fix: re\u2011enable retries", true, false, "fix: re-enable retries"},
+		{"ellipsis", "wip: more to come…", true, false, "wip: more to come..."},
+		{"zero-width and BOM", "\ufefffix: split\u200b on\u200d commas", true, false, "fix: split on commas"},
+		{"accents and emoji kept", "feat: add café menu ✨ 日本", true, false, "feat: add café menu ✨ 日本"},
+		{"body left alone", "fix: don’t crash\n\nIt’s “fixed” now…", true, false, "fix: don't crash\n\nIt’s “fixed” now…"},
+		{"body normalized when asked", "fix: don’t crash\n\nIt’s “fixed” now…", true, true, "fix: don't crash\n\nIt's \"fixed\" now..."},
+		{"subject left alone", "fix: don’t crash\n\nIt’s fixed", false, true, "fix: don’t crash\n\nIt's fixed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeUnicode(tt.msg, tt.subject, tt.body); got != tt.want {
+				t.Errorf("normalizeUnicode(%q, %v, %v) = %q, want %q", tt.msg, tt.subject, tt.body, got, tt.want)
+			}
+		})
+	}
+}
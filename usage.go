@@ -0,0 +1,89 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// expectedOutputTokens is the answer size --max-cost assumes before a
+// request is sent, unless --max-message-tokens sets one.
+const expectedOutputTokens = 100
+
+// usageJSON is the token usage and rough cost of a run's generation in the
+// --json output. CostUSD is missing when the model's price is unknown.
+type usageJSON struct {
+	InputTokens  int      `json:"input_tokens"`
+	OutputTokens int      `json:"output_tokens"`
+	Estimated    bool     `json:"estimated,omitempty"` // counted from the text, see estimateTokens
+	CostUSD      *float64 `json:"cost_usd,omitempty"`
+}
+
+// usageOf adds up the tokens the suggestions took. Providers that report no
+// usage, such as Ollama, get an estimate from the prompt and the answers.
+func usageOf(model string, opts generator.Options, gc gitctx.CommitContext, suggestions ...generator.Suggestion) *usageJSON {
+	u := &usageJSON{}
+	var prompt int
+	for _, sg := range suggestions {
+		if sg.Usage.InputTokens > 0 {
+			u.InputTokens += sg.Usage.InputTokens
+			u.OutputTokens += sg.Usage.OutputTokens
+			continue
+		}
+		if prompt == 0 {
+			prompt = estimateTokens(generator.SystemPrompt(opts) + generator.UserPrompt(opts, gc))
+		}
+		u.InputTokens += prompt * max(sg.Attempts, 1)
+		u.OutputTokens += estimateTokens(sg.Message)
+		u.Estimated = true
+	}
+	if cost, ok := estimateCost(model, u.InputTokens, u.OutputTokens); ok {
+		u.CostUSD = &cost
+	}
+	return u
+}
+
+// String reports u in one line, e.g. "~1200 input and ~20 output tokens,
+// about $0.0004".
+func (u usageJSON) String() string {
+	approx := ""
+	if u.Estimated {
+		approx = "~"
+	}
+	s := fmt.Sprintf("%s%d input and %s%d output tokens", approx, u.InputTokens, approx, u.OutputTokens)
+	switch {
+	case u.CostUSD == nil:
+	case *u.CostUSD == 0:
+		s += ", free"
+	default:
+		s += fmt.Sprintf(", about $%.4f", *u.CostUSD)
+	}
+	return s
+}
+
+// checkPromptBudget refuses a generation whose prompt exceeds --max-tokens or
+// whose rough cost exceeds --max-cost, before anything is sent. calls is how
+// many times the prompt goes out, e.g. once per --interactive candidate.
+func checkPromptBudget(model string, opts generator.Options, gc gitctx.CommitContext, calls, maxTokens int, maxCost float64) error {
+	if maxTokens <= 0 && maxCost <= 0 {
+		return nil
+	}
+	tokens := estimateTokens(generator.SystemPrompt(opts) + generator.UserPrompt(opts, gc))
+	if maxTokens > 0 && tokens > maxTokens {
+		return fmt.Errorf("the prompt is about %d tokens, over --max-tokens %d; trim it with --token-budget %d, --max-files, or --stat-only", tokens, maxTokens, maxTokens)
+	}
+	if maxCost <= 0 {
+		return nil
+	}
+	cost, ok := estimateCost(model, tokens*calls, cmp.Or(opts.MaxTokens, expectedOutputTokens)*calls)
+	if !ok {
+		warnf("No price is known for %s, so --max-cost can't be checked.", model)
+		return nil
+	}
+	if cost > maxCost {
+		return fmt.Errorf("the request would cost about $%.4f, over --max-cost $%g; trim the prompt with --token-budget, --max-files, or --stat-only", cost, maxCost)
+	}
+	return nil
+}
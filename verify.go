@@ -0,0 +1,95 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// maxVerifyRetries bounds how often a message that fails --verify is
+// regenerated before it is kept with a warning.
+const maxVerifyRetries = 2
+
+const verifyPrompt = "You check git commit messages against the changes they describe.\nReply YES if everything the message claims is supported by the changes below. Otherwise reply NO: followed by the claim that is not supported, in one short line."
+
+var (
+	// fileMentionRe matches words that look like file names or paths.
+	fileMentionRe = regexp.MustCompile(`[\w./-]*\w\.([a-zA-Z][a-zA-Z0-9]{0,4})\b`)
+	// fileMentionExts are the extensions that make such a word a file name
+	// rather than an abbreviation or a version.
+	fileMentionExts = []string{"go", "js", "jsx", "ts", "tsx", "py", "rb", "rs", "java", "kt", "c", "h", "cc", "cpp", "hpp", "cs", "swift", "php", "sh", "md", "json", "yaml", "yml", "toml", "sql", "html", "css", "scss", "proto", "txt", "mod", "sum", "lock"}
+)
+
+// unknownFiles returns the file names msg mentions that none of the changed
+// files match, by path or by base name.
+func unknownFiles(msg string, changes []gitctx.FileChange) []string {
+	var paths []string
+	for _, c := range changes {
+		paths = append(paths, c.Path)
+		if c.OldPath != "" {
+			paths = append(paths, c.OldPath)
+		}
+	}
+	var unknown []string
+	for _, m := range fileMentionRe.FindAllStringSubmatch(msg, -1) {
+		name := strings.TrimPrefix(m[0], "./")
+		if !slices.Contains(fileMentionExts, strings.ToLower(m[1])) || slices.Contains(unknown, name) {
+			continue
+		}
+		known := slices.ContainsFunc(paths, func(p string) bool {
+			return p == name || strings.HasSuffix(p, "/"+name) || path.Base(p) == path.Base(name)
+		})
+		if !known {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+// verifyMessage checks that msg is supported by the changes: the files it
+// names must be among the changed ones, and the model must agree that it
+// describes the diff. It returns why the message was rejected, or "".
+func verifyMessage(ctx context.Context, g *generator.Generator, opts generator.Options, gc gitctx.CommitContext, msg string) (string, error) {
+	if unknown := unknownFiles(msg, gitctx.ParseNameStatus(gc.NameStatus)); len(unknown) > 0 {
+		return "mentions files that didn't change: " + strings.Join(unknown, ", "), nil
+	}
+	answer, err := g.Ask(ctx, opts, verifyPrompt, generator.UserPrompt(opts, gc)+"\nCommit message to check:\n"+msg+"\n")
+	if err != nil {
+		return "", err
+	}
+	if verdict, reason, _ := strings.Cut(answer, ":"); strings.EqualFold(strings.TrimSpace(verdict), "no") {
+		return cmp.Or(strings.TrimSpace(reason), "the model found it unsupported"), nil
+	}
+	return "", nil
+}
+
+// generateVerified is generator.Generate for --verify: a message that fails
+// verifyMessage is regenerated up to maxVerifyRetries times, after which the
+// last one is kept with a warning. A failing check doesn't stop generation.
+func generateVerified(ctx context.Context, g *generator.Generator, opts generator.Options, gc gitctx.CommitContext) (generator.Suggestion, error) {
+	for attempt := 0; ; attempt++ {
+		sg, err := g.Generate(ctx, opts, gc)
+		if err != nil {
+			return sg, err
+		}
+		reason, err := verifyMessage(ctx, g, opts, gc, sg.Message)
+		switch {
+		case err != nil:
+			warnf("Could not verify the message (%v); keeping it.", err)
+			return sg, nil
+		case reason == "":
+			debugf("Message verified against the diff")
+			return sg, nil
+		case attempt == maxVerifyRetries:
+			warnf("The message may not match the diff: %s", reason)
+			return sg, nil
+		}
+		warnf("Rejected a message that doesn't match the diff (%s); regenerating.", reason)
+	}
+}
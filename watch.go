@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/muhammedsamal/commit/generator"
+	"github.com/muhammedsamal/commit/gitctx"
+)
+
+// watchDebounce is how long the tree has to stay quiet after a change before
+// the message is regenerated, so a save-all or a formatter run costs one call.
+const watchDebounce = 750 * time.Millisecond
+
+// runWatch prints a message for the current changes, then regenerates it
+// whenever files in the working tree or the index change, until interrupted.
+// refresh gathers the changes again and returns them with the options that
+// depend on them. Unchanged prompts are skipped and the message cache is
+// used, so only real edits reach the model. A file, when given, always holds
+// the latest message, or nothing while there are no changes.
+func runWatch(ctx context.Context, g *generator.Generator, opts generator.Options, post postProcess, gc gitctx.CommitContext, refresh func() (gitctx.CommitContext, generator.Options), file string) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	if repo.Top == "" {
+		fatalf("--watch needs a work tree to watch")
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		fatalf("Failed to watch files: %v", err)
+	}
+	defer w.Close()
+	for _, dir := range watchDirs(repo.Top, repo.GitDir) {
+		if err := w.Add(dir); err != nil {
+			debugf("Not watching %s: %v", dir, err)
+		}
+	}
+
+	var lastKey string
+	show := func() {
+		if gc.Diff == "" {
+			fmt.Println(paint(ansiDim, "No changes."))
+			writeWatchFile(file, "")
+			lastKey = ""
+			return
+		}
+		key := generationCacheKey(opts, gc)
+		if key == lastKey {
+			return
+		}
+		lastKey = key
+		sg, ok := loadCachedMessage(key)
+		if !ok {
+			if sg, err = g.Generate(ctx, opts, gc); err != nil {
+				if ctx.Err() == nil {
+					errorf("Generation failed: %v", err)
+				}
+				lastKey = "" // try again on the next change
+				return
+			}
+			storeCachedMessage(key, sg)
+		}
+		msg := post.apply(sg.Message)
+		fmt.Printf("\n%s\n%s\n", paint(ansiDim, time.Now().Format("15:04:05")), colorMessage(msg))
+		writeWatchFile(file, msg+"\n")
+	}
+
+	fmt.Println(header("Watching for changes (Ctrl+C to stop)..."))
+	show()
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println()
+			return
+		case err := <-w.Errors:
+			warnf("Watch error: %v", err)
+		case ev := <-w.Events:
+			if !relevantEvent(repo.Top, repo.GitDir, ev) {
+				continue
+			}
+			// New directories are watched too; fsnotify isn't recursive.
+			if ev.Has(fsnotify.Create) {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					w.Add(ev.Name)
+				}
+			}
+			debounce = time.After(watchDebounce)
+		case <-debounce:
+			debounce = nil
+			gc, opts = refresh()
+			show()
+		}
+	}
+}
+
+// writeWatchFile replaces file with text, if there is a file, through a
+// rename so that a reader never sees half a message.
+func writeWatchFile(file, text string) {
+	if file == "" {
+		return
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, []byte(text), 0644); err != nil {
+		warnf("Failed to write %s: %v", file, err)
+		return
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		warnf("Failed to write %s: %v", file, err)
+	}
+}
+
+// watchDirs lists the top level, the git directory (for index updates), and
+// every directory holding a tracked or untracked, not ignored file.
+func watchDirs(top, gitDir string) []string {
+	dirs := []string{top, gitDir}
+	seen := map[string]bool{top: true}
+	out, err := runGit("-C", top, "ls-files", "--cached", "--others", "--exclude-standard")
+	if err != nil {
+		return dirs
+	}
+	for _, f := range strings.Split(out, "\n") {
+		for dir := filepath.Dir(filepath.Join(top, f)); !seen[dir] && strings.HasPrefix(dir, top); dir = filepath.Dir(dir) {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// relevantEvent drops metadata-only events and everything in the git
+// directory except the index, which changes when files are staged. A linked
+// worktree's git directory is outside top, and its .git is a file.
+func relevantEvent(top, gitDir string, ev fsnotify.Event) bool {
+	if ev.Op == fsnotify.Chmod {
+		return false
+	}
+	if rel, err := filepath.Rel(gitDir, ev.Name); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return rel == "index"
+	}
+	rel, err := filepath.Rel(top, ev.Name)
+	return err != nil || filepath.ToSlash(rel) != ".git"
+}